@@ -0,0 +1,634 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//maxAdminRequest bounds how much of a request body we'll read
+const maxAdminRequest = 64 * 1024
+
+//AdminConfiguration Configures the optional admin API and dashboard
+type AdminConfiguration struct {
+	//Listen is the address to serve on, eg 127.0.0.1:8080. The admin server isn't started if unset
+	Listen string `json:"Listen"`
+	//Token must be sent as a bearer token, or as the password when signing in to the dashboard
+	Token string `json:"Token"`
+}
+
+//AdminServer Serves a REST API and dashboard over the data held by our handlers
+//Everything is run on the owning handler's goroutine, through the same functions as its chat commands
+type AdminServer struct {
+	token     string
+	releases  *ReleaseHandler
+	reminders *ReminderHandler
+	images    *ImageHandler
+	reactions *ReactionHandler
+}
+
+//adminChannel Is one channel's data, as the API shows it
+type adminChannel struct {
+	ChannelID string          `json:"channelID"`
+	GuildID   string          `json:"guildID,omitempty"`
+	Releases  []adminRelease  `json:"releases,omitempty"`
+	Reminders []adminReminder `json:"reminders,omitempty"`
+	Images    []imageData     `json:"images,omitempty"`
+}
+
+//adminRelease Is a release, with the ID chat commands use for it
+type adminRelease struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ReleaseDate string `json:"releaseDate"`
+}
+
+//adminReminder Is a reminder, with the ID chat commands use for it
+type adminReminder struct {
+	ID int `json:"id"`
+	Reminder
+}
+
+//adminError Is an error the API reports, with the status to report it with
+type adminError struct {
+	status  int
+	message string
+}
+
+func (ae adminError) Error() string {
+	return ae.message
+}
+
+func notFound(err error) error {
+	return adminError{status: http.StatusNotFound, message: err.Error()}
+}
+
+func badRequest(err error) error {
+	return adminError{status: http.StatusBadRequest, message: err.Error()}
+}
+
+//NewAdminServer creates a server over whichever of our handlers are running
+func NewAdminServer(token string, running []MessageHandler) *AdminServer {
+	as := &AdminServer{token: token}
+	for _, handler := range running {
+		switch h := handler.(type) {
+		case *ReleaseHandler:
+			as.releases = h
+		case *ReminderHandler:
+			as.reminders = h
+		case *ImageHandler:
+			as.images = h
+		case *ReactionHandler:
+			as.reactions = h
+		}
+	}
+
+	return as
+}
+
+//serveAdmin starts the admin server if configured, returning it so it can be closed, or nil
+func serveAdmin(config AdminConfiguration, running []MessageHandler) *http.Server {
+	if config.Listen == "" {
+		return nil
+	}
+	if config.Token == "" {
+		logger.Error("Not starting the admin server, it needs a Token")
+		return nil
+	}
+
+	logger.Redact(config.Token)
+	return serveHTTP("admin", config.Listen, NewAdminServer(config.Token, running))
+}
+
+//ServeHTTP checks the request is signed in, then passes it to the dashboard or API
+func (as *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !as.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="DisKhard"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/" {
+		as.dashboard(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAdminRequest)
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+	var result interface{}
+	var err error
+	switch path[0] {
+	case "releases":
+		result, err = as.releasesAPI(r, path[1:])
+	case "reminders":
+		result, err = as.remindersAPI(r, path[1:])
+	case "images":
+		result, err = as.imagesAPI(r, path[1:])
+	case "reactions":
+		result, err = as.reactionsAPI(r, path[1:])
+	case "audit":
+		result, err = as.auditAPI(r, path[1:])
+	default:
+		err = notFound(errors.New("unknown resource " + path[0]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if ae, ok := err.(adminError); ok {
+			status = ae.status
+		} else if err == errHandlerStopped {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+//authorized accepts the token as a bearer token, or as a basic auth password for browsers
+func (as *AdminServer) authorized(r *http.Request) bool {
+	given := ""
+	if _, password, ok := r.BasicAuth(); ok {
+		given = password
+	} else if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		given = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(as.token)) == 1
+}
+
+//route checks the request's method and how many path segments it has, and whether the handler is running
+func route(r *http.Request, path []string, handler MessageHandler, handlerRunning bool) (string, error) {
+	if !handlerRunning {
+		return "", errHandlerStopped
+	}
+	//Disabled handlers still run work sent to them, but their data may be in a bad way
+	if supervisor.Disabled(handler.GetName()) {
+		return "", errHandlerStopped
+	}
+
+	return r.Method + " " + strconv.Itoa(len(path)), nil
+}
+
+//adminAuditMessage stands in for a chat message when auditing changes made through the API
+func adminAuditMessage(r *http.Request, channelID string, guildID string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: channelID,
+		GuildID:   guildID,
+		Content:   r.Method + " " + r.URL.Path,
+		Author:    &discordgo.User{Username: "admin API"},
+	}}
+}
+
+func methodNotAllowed(r *http.Request) error {
+	return adminError{status: http.StatusMethodNotAllowed, message: r.Method + " isn't supported here"}
+}
+
+//decodeBody reads a json body. Browsers resend basic auth on requests from other sites, but can only send
+//json from another site's page if we'd answer a CORS preflight, which we never do, so other types are refused
+func decodeBody(r *http.Request, v interface{}) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return adminError{status: http.StatusUnsupportedMediaType, message: "request bodies must be sent as application/json"}
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest(err)
+	}
+
+	return nil
+}
+
+func pathIndex(segment string) (int, error) {
+	index, err := strconv.Atoi(segment)
+	if err != nil {
+		return 0, badRequest(errors.New("IDs are numbers, not " + segment))
+	}
+
+	return index, nil
+}
+
+//releasesAPI serves /api/releases, /api/releases/{channel} and /api/releases/{channel}/{id}
+func (as *AdminServer) releasesAPI(r *http.Request, path []string) (interface{}, error) {
+	rh := as.releases
+	shape, err := route(r, path, rh, rh != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	var failed error
+	switch shape {
+	case "GET 0", "GET 1":
+		err = rh.do(func() { result = as.releaseChannels(path) })
+	case "POST 1":
+		var body struct {
+			Name        string `json:"name"`
+			ReleaseDate string `json:"releaseDate"`
+			GuildID     string `json:"guildID"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		if body.Name == "" || body.ReleaseDate == "" {
+			return nil, badRequest(errors.New("releases need a name and a releaseDate, eg 10/20/35 or Q42035"))
+		}
+		message := adminAuditMessage(r, path[0], "")
+		err = rh.doAs(message, func() {
+			//The channel's own guild wins, the body's only helps with channels discord can't tell us about
+			message.GuildID = rh.channelGuild(path[0])
+			if message.GuildID == "" {
+				message.GuildID = body.GuildID
+			}
+			if _, failed = rh.addRelease(path[0], message.GuildID, body.ReleaseDate, body.Name); failed == nil {
+				result = as.releaseChannels(path)
+			}
+		})
+	case "PUT 2":
+		var body struct {
+			ReleaseDate string `json:"releaseDate"`
+		}
+		index, badIndex := pathIndex(path[1])
+		if badIndex != nil {
+			return nil, badIndex
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		message := adminAuditMessage(r, path[0], "")
+		err = rh.doAs(message, func() {
+			message.GuildID = rh.channelGuild(path[0])
+			if _, failed = rh.editRelease(path[0], index, body.ReleaseDate); failed == nil {
+				result = as.releaseChannels(path[:1])
+			}
+		})
+	case "DELETE 2":
+		index, badIndex := pathIndex(path[1])
+		if badIndex != nil {
+			return nil, badIndex
+		}
+		message := adminAuditMessage(r, path[0], "")
+		err = rh.doAs(message, func() {
+			message.GuildID = rh.channelGuild(path[0])
+			if _, failed = rh.deleteRelease(path[0], index); failed == nil {
+				result = as.releaseChannels(path[:1])
+			}
+		})
+	default:
+		return nil, methodNotAllowed(r)
+	}
+
+	if failed == errNoReleases || failed == errInvalidRelease {
+		return nil, notFound(failed)
+	} else if failed != nil {
+		return nil, badRequest(failed)
+	}
+	return result, err
+}
+
+//releaseChannels lists the releases of every channel, or just the one in the path. Run on the release handler
+func (as *AdminServer) releaseChannels(path []string) []adminChannel {
+	channels := make([]adminChannel, 0)
+	for channelID, channelData := range as.releases.releases {
+		if len(path) > 0 && channelID != path[0] {
+			continue
+		}
+
+		channel := adminChannel{ChannelID: channelID, GuildID: channelData.GuildID, Releases: make([]adminRelease, 0)}
+		for x, release := range channelData.Releases {
+			channel.Releases = append(channel.Releases, adminRelease{ID: x, Name: release.Name, ReleaseDate: release.ReleaseDate})
+		}
+		channels = append(channels, channel)
+	}
+
+	return sortChannels(channels)
+}
+
+//remindersAPI serves /api/reminders, /api/reminders/{channel} and /api/reminders/{channel}/{id}
+func (as *AdminServer) remindersAPI(r *http.Request, path []string) (interface{}, error) {
+	rh := as.reminders
+	shape, err := route(r, path, rh, rh != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	var failed error
+	var missing error
+	switch shape {
+	case "GET 0", "GET 1":
+		err = rh.do(func() { result = as.reminderChannels(path) })
+	case "POST 1":
+		var body Reminder
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		err = rh.doAs(adminAuditMessage(r, path[0], channelGuild(rh.session, path[0])), func() {
+			if failed = rh.addReminder(path[0], "", &body); failed == nil {
+				result = as.reminderChannels(path)
+			}
+		})
+	case "PUT 2":
+		var body Reminder
+		index, badIndex := pathIndex(path[1])
+		if badIndex != nil {
+			return nil, badIndex
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		err = rh.doAs(adminAuditMessage(r, path[0], channelGuild(rh.session, path[0])), func() {
+			if _, missing = rh.reminder(path[0], index); missing != nil {
+				return
+			}
+			if _, failed = rh.editReminder(path[0], index, body); failed == nil {
+				result = as.reminderChannels(path[:1])
+			}
+		})
+	case "DELETE 2":
+		index, badIndex := pathIndex(path[1])
+		if badIndex != nil {
+			return nil, badIndex
+		}
+		err = rh.doAs(adminAuditMessage(r, path[0], channelGuild(rh.session, path[0])), func() {
+			if _, missing = rh.deleteReminder(path[0], index); missing == nil {
+				result = as.reminderChannels(path[:1])
+			}
+		})
+	default:
+		return nil, methodNotAllowed(r)
+	}
+
+	if missing != nil {
+		return nil, notFound(missing)
+	} else if failed != nil {
+		return nil, badRequest(failed)
+	}
+	return result, err
+}
+
+//reminderChannels lists the reminders of every channel, or just the one in the path. Run on the reminder handler
+func (as *AdminServer) reminderChannels(path []string) []adminChannel {
+	channels := make([]adminChannel, 0)
+	for channelID, channelData := range as.reminders.channelReminders {
+		if len(path) > 0 && channelID != path[0] {
+			continue
+		}
+
+		channel := adminChannel{ChannelID: channelID, Reminders: make([]adminReminder, 0)}
+		for x, reminder := range channelData.Reminders {
+			channel.Reminders = append(channel.Reminders, adminReminder{ID: x, Reminder: *reminder})
+		}
+		channels = append(channels, channel)
+	}
+
+	return sortChannels(channels)
+}
+
+//imagesAPI serves /api/images, /api/images/{channel} and /api/images/{channel}/{dir}
+func (as *AdminServer) imagesAPI(r *http.Request, path []string) (interface{}, error) {
+	ih := as.images
+	shape, err := route(r, path, ih, ih != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	var failed error
+	var missing error
+	switch shape {
+	case "GET 0", "GET 1":
+		err = ih.do(func() { result = as.imageChannels(path) })
+	case "POST 1":
+		var body imageData
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		err = ih.doAs(adminAuditMessage(r, path[0], channelGuild(ih.session, path[0])), func() {
+			if failed = ih.addBlock(path[0], &body); failed == nil {
+				result = as.imageChannels(path)
+			}
+		})
+	case "PUT 2":
+		var body imageData
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		err = ih.doAs(adminAuditMessage(r, path[0], channelGuild(ih.session, path[0])), func() {
+			if _, missing = ih.block(path[0], path[1]); missing != nil {
+				return
+			}
+			if _, failed = ih.editBlock(path[0], path[1], body); failed == nil {
+				result = as.imageChannels(path[:1])
+			}
+		})
+	case "DELETE 2":
+		err = ih.doAs(adminAuditMessage(r, path[0], channelGuild(ih.session, path[0])), func() {
+			if missing = ih.removeBlock(path[0], path[1]); missing == nil {
+				result = as.imageChannels(path[:1])
+			}
+		})
+	default:
+		return nil, methodNotAllowed(r)
+	}
+
+	if missing != nil {
+		return nil, notFound(missing)
+	} else if failed != nil {
+		return nil, badRequest(failed)
+	}
+	return result, err
+}
+
+//imageChannels lists the image blocks of every channel, or just the one in the path. Run on the image handler
+func (as *AdminServer) imageChannels(path []string) []adminChannel {
+	channels := make([]adminChannel, 0)
+	for channelID, channelData := range as.images.imageMap {
+		if len(path) > 0 && channelID != path[0] {
+			continue
+		}
+
+		channel := adminChannel{ChannelID: channelID, Images: make([]imageData, 0)}
+		for _, block := range channelData.ImageData {
+			channel.Images = append(channel.Images, *block)
+		}
+		channels = append(channels, channel)
+	}
+
+	return sortChannels(channels)
+}
+
+//reactionsAPI serves /api/reactions and /api/reactions/{trigger}
+func (as *AdminServer) reactionsAPI(r *http.Request, path []string) (interface{}, error) {
+	rh := as.reactions
+	shape, err := route(r, path, rh, rh != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	var failed error
+	var missing error
+	switch shape {
+	case "GET 0":
+		err = rh.do(func() { result = rh.reactions() })
+	case "POST 0":
+		var body reactionData
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		err = rh.doAs(adminAuditMessage(r, "", ""), func() {
+			if failed = rh.setReaction(body); failed == nil {
+				result = rh.reactions()
+			}
+		})
+	case "PUT 1":
+		var body reactionData
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		body.TriggerWord = path[0]
+		err = rh.doAs(adminAuditMessage(r, "", ""), func() {
+			if _, ok := rh.reactionMap[path[0]]; !ok {
+				missing = errors.New("no reaction for " + path[0])
+				return
+			}
+			if failed = rh.setReaction(body); failed == nil {
+				result = rh.reactions()
+			}
+		})
+	case "DELETE 1":
+		err = rh.doAs(adminAuditMessage(r, "", ""), func() {
+			if missing = rh.removeReaction(path[0]); missing == nil {
+				result = rh.reactions()
+			}
+		})
+	default:
+		return nil, methodNotAllowed(r)
+	}
+
+	if missing != nil {
+		return nil, notFound(missing)
+	} else if failed != nil {
+		return nil, badRequest(failed)
+	}
+	return result, err
+}
+
+//auditAPI serves /api/audit, optionally filtered with ?guild=, ?filter= and ?period= like the /audit command
+func (as *AdminServer) auditAPI(r *http.Request, path []string) (interface{}, error) {
+	if r.Method != http.MethodGet || len(path) != 0 {
+		return nil, methodNotAllowed(r)
+	}
+	if auditLog == nil {
+		return nil, errHandlerStopped
+	}
+
+	params := r.URL.Query()
+	query, err := newAuditQuery(params.Get("guild"), params.Get("filter"), params.Get("period"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	query.allGuilds = params.Get("guild") == ""
+
+	return auditLog.Query(query.matches)
+}
+
+func sortChannels(channels []adminChannel) []adminChannel {
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ChannelID < channels[j].ChannelID })
+	return channels
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DisKhard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #5865f2; color: white; }
+</style>
+</head>
+<body>
+<h1>DisKhard</h1>
+<p>Changes can be made through the API under <code>/api/</code>, see the README.</p>
+
+<h2>Releases</h2>
+<table>
+<tr><th>Channel</th><th>ID</th><th>Name</th><th>Release date</th></tr>
+{{range .Releases}}{{$channel := .ChannelID}}{{range .Releases}}<tr><td>{{$channel}}</td><td>{{.ID}}</td><td>{{.Name}}</td><td>{{.ReleaseDate}}</td></tr>
+{{end}}{{else}}<tr><td colspan="4">No tracked releases</td></tr>
+{{end}}</table>
+
+<h2>Reminders</h2>
+<table>
+<tr><th>Channel</th><th>ID</th><th>Name</th><th>Time</th><th>Days</th><th>Time zone</th><th>Notifies</th></tr>
+{{range .Reminders}}{{$channel := .ChannelID}}{{range .Reminders}}<tr><td>{{$channel}}</td><td>{{.ID}}</td><td>{{.Name}}</td><td>{{.Hour}}:{{printf "%02d" .Minute}}</td><td>{{.Days}}</td><td>{{.TimeZone}}</td><td>{{len .Notifyees}}</td></tr>
+{{end}}{{else}}<tr><td colspan="7">No reminders</td></tr>
+{{end}}</table>
+
+<h2>Image blocks</h2>
+<table>
+<tr><th>Channel</th><th>Directory</th><th>Page</th><th>Per post</th><th>Schedule</th><th>Hour</th><th>Time zone</th><th>Repeat</th></tr>
+{{range .Images}}{{$channel := .ChannelID}}{{range .Images}}<tr><td>{{$channel}}</td><td>{{.Dir}}</td><td>{{.Current}}</td><td>{{.Multiplier}}</td><td>{{.Schedule}}</td><td>{{.Hour}}</td><td>{{.TimeZone}}</td><td>{{.Repeat}}</td></tr>
+{{end}}{{else}}<tr><td colspan="8">No image blocks</td></tr>
+{{end}}</table>
+
+<h2>Reactions</h2>
+<table>
+<tr><th>Trigger word</th><th>Reaction</th></tr>
+{{range .Reactions}}<tr><td>{{.TriggerWord}}</td><td>{{.Reaction}}</td></tr>
+{{else}}<tr><td colspan="2">No reactions</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+//dashboard renders everything we hold as tables. Handlers which aren't running are left empty
+func (as *AdminServer) dashboard(w http.ResponseWriter, r *http.Request) {
+	var page struct {
+		Releases  []adminChannel
+		Reminders []adminChannel
+		Images    []adminChannel
+		Reactions []reactionData
+	}
+
+	if as.releases != nil {
+		as.releases.do(func() { page.Releases = as.releaseChannels(nil) })
+	}
+	if as.reminders != nil {
+		as.reminders.do(func() { page.Reminders = as.reminderChannels(nil) })
+	}
+	if as.images != nil {
+		as.images.do(func() { page.Images = as.imageChannels(nil) })
+	}
+	if as.reactions != nil {
+		as.reactions.do(func() { page.Reactions = as.reactions.reactions() })
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		logger.Error("Error rendering dashboard", "error", err)
+	}
+}
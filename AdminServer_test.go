@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestAdminServer(t *testing.T, running ...MessageHandler) *httptest.Server {
+	for _, handler := range running {
+		newHandlerHarness(t, handler)
+	}
+
+	server := httptest.NewServer(NewAdminServer("letmein", running))
+	t.Cleanup(server.Close)
+	return server
+}
+
+//adminRequest makes a signed in request, returning the status and body
+func adminRequest(t *testing.T, server *httptest.Server, method string, path string, body string) (int, string) {
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer letmein")
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+
+	return response.StatusCode, string(data)
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	useTempDir(t)
+	server := newTestAdminServer(t, NewReleaseHandler(&FakeSession{}, NewJSONFileStore(".")))
+
+	response, err := server.Client().Get(server.URL + "/api/releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("expected to be asked to sign in, got %d", response.StatusCode)
+	}
+
+	request, _ := http.NewRequest("GET", server.URL+"/api/releases", nil)
+	request.SetBasicAuth("admin", "wrong")
+	response, err = server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the wrong password to be refused, got %d", response.StatusCode)
+	}
+
+	//A form posted from another site, with the browser's saved sign in
+	request, _ = http.NewRequest("POST", server.URL+"/api/releases/chan", strings.NewReader(`{"name": "Spam", "releaseDate": "10/20/35"}`))
+	request.SetBasicAuth("admin", "letmein")
+	request.Header.Set("Content-Type", "text/plain")
+	response, err = server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a body which isn't json to be refused, got %d", response.StatusCode)
+	}
+}
+
+func TestAdminManagesReleases(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	server := newTestAdminServer(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	added, edited := daysAhead(30).Format("01/02/06"), daysAhead(60)
+	status, body := adminRequest(t, server, "POST", "/api/releases/chan", `{"name": "Persona 8", "releaseDate": "`+added+`", "guildID": "guild"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected the release to be created, got %d %s", status, body)
+	}
+	assertContains(t, body, `"releases":[{"id":0,"name":"Persona 8","releaseDate":"`+added+`"}]`)
+	if session.Count("pin") != 1 {
+		t.Errorf("expected the release summary to be pinned, got %d pins", session.Count("pin"))
+	}
+
+	status, body = adminRequest(t, server, "PUT", "/api/releases/chan/0", `{"releaseDate": "`+edited.Format("01/02/06")+`"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected the release to be edited, got %d %s", status, body)
+	}
+	assertContains(t, body, `"releaseDate":"`+edited.Format("01/02/06")+`"`)
+	assertContains(t, session.Calls()[len(session.Calls())-1].Content, edited.Format("01-02-2006")+" Persona 8 [0]")
+
+	if status, body = adminRequest(t, server, "DELETE", "/api/releases/chan/3", ""); status != http.StatusNotFound {
+		t.Errorf("expected a missing release to be reported, got %d %s", status, body)
+	}
+	if status, body = adminRequest(t, server, "DELETE", "/api/releases/chan/0", ""); status != http.StatusOK {
+		t.Errorf("expected the release to be deleted, got %d %s", status, body)
+	}
+
+	saved, err := ioutil.ReadFile(releaseDocument + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "Persona 8") {
+		t.Errorf("expected the deletion to be saved, got %s", saved)
+	}
+}
+
+func TestAdminValidatesReminders(t *testing.T) {
+	useTempDir(t)
+	server := newTestAdminServer(t, NewReminderHandler(&FakeSession{}, NewJSONFileStore(".")))
+
+	status, body := adminRequest(t, server, "POST", "/api/reminders/chan", `{"name": "Standup", "hour": 25, "minute": 0, "days": [1]}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected a bad hour to be refused, got %d %s", status, body)
+	}
+	assertContains(t, body, "Hour must be between 0 and 23")
+
+	status, body = adminRequest(t, server, "POST", "/api/reminders/chan", `{"name": "Standup", "hour": 9, "minute": 30, "days": [1, 5], "timeZone": "Europe/London"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected the reminder to be created, got %d %s", status, body)
+	}
+
+	status, body = adminRequest(t, server, "PUT", "/api/reminders/chan/0", `{"name": "Retro", "hour": 16, "minute": 0, "days": [5]}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected the reminder to be edited, got %d %s", status, body)
+	}
+
+	var channels []adminChannel
+	status, body = adminRequest(t, server, "GET", "/api/reminders", "")
+	if err := json.Unmarshal([]byte(body), &channels); err != nil || len(channels) != 1 || len(channels[0].Reminders) != 1 {
+		t.Fatalf("expected one channel with one reminder, got %d %s", status, body)
+	}
+	if reminder := channels[0].Reminders[0]; reminder.Name != "Retro" || reminder.Hour != 16 || reminder.TimeZone != "" {
+		t.Errorf("unexpected reminder %+v", reminder)
+	}
+}
+
+func TestAdminRefusesImageBlocksOutsideReader(t *testing.T) {
+	useTempDir(t)
+	server := newTestAdminServer(t, NewImageHandler(&FakeSession{}, NewJSONFileStore(".")))
+
+	status, body := adminRequest(t, server, "POST", "/api/images/chan", `{"dir": "../secrets", "schedule": "daily", "hour": 9, "multiplier": 1}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected the directory to be refused, got %d %s", status, body)
+	}
+}
+
+func TestAdminManagesReactionsAndDashboard(t *testing.T) {
+	useTempDir(t)
+	server := newTestAdminServer(t, NewReactionHandler(&FakeSession{}, NewJSONFileStore(".")), NewReleaseHandler(&FakeSession{}, NewJSONFileStore(".")))
+
+	status, body := adminRequest(t, server, "POST", "/api/reactions", `{"TriggerWord": "hello", "Reaction": "👋"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected the reaction to be added, got %d %s", status, body)
+	}
+	adminRequest(t, server, "POST", "/api/releases/chan", `{"name": "<b>Bold</b>", "releaseDate": "`+daysAhead(30).Format("01/02/06")+`"}`)
+
+	status, body = adminRequest(t, server, "GET", "/", "")
+	if status != http.StatusOK {
+		t.Fatalf("expected the dashboard, got %d", status)
+	}
+	assertContains(t, body, "<td>hello</td><td>👋</td>")
+	assertContains(t, body, "&lt;b&gt;Bold&lt;/b&gt;")
+
+	if status, body = adminRequest(t, server, "DELETE", "/api/reactions/hello", ""); status != http.StatusOK || body != "[]\n" {
+		t.Errorf("expected the reaction to be removed, got %d %s", status, body)
+	}
+	if status, _ = adminRequest(t, server, "GET", "/api/images", ""); status != http.StatusServiceUnavailable {
+		t.Errorf("expected handlers which aren't running to be unavailable, got %d", status)
+	}
+}
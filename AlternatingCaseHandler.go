@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"regexp"
+	"strings"
 	"unicode"
 
 	"github.com/bwmarrin/discordgo"
@@ -9,24 +11,20 @@ import (
 
 //AlternatingCaseHandler Echoes messages to stdout
 type AlternatingCaseHandler struct {
+	handlerLoop
+	session Session
+}
+
+//NewAlternatingCaseHandler creates a handler which responds through the provided session
+func NewAlternatingCaseHandler(session Session) *AlternatingCaseHandler {
+	return &AlternatingCaseHandler{session: session}
 }
 
 const acCommand = "/ac "
 
 //Init Handles setting up our channel listener
-func (ach *AlternatingCaseHandler) Init(m chan *discordgo.MessageCreate) {
-	go func() {
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					ach.handleMessage(message)
-				} else {
-					return
-				}
-			}
-		}
-	}()
+func (ach *AlternatingCaseHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	ach.listen(ctx, ach.GetName(), m, ach.handleMessage)
 }
 
 //GetName returns the name of this handler
@@ -40,11 +38,22 @@ func (ach *AlternatingCaseHandler) handleMessage(m *discordgo.MessageCreate) {
 	if match == true {
 		//Alternate case the important bits
 		sliced := []rune(m.Content[4:len(m.Content)])
-		MessageSender.SendMessage(m.ChannelID, ach.alternateCase(sliced))
-		MessageSender.DeleteMessage(m.ChannelID, m.ID)
+		ach.session.SendMessage(m.ChannelID, ach.alternateCase(sliced))
+		ach.session.DeleteMessage(m.ChannelID, m.ID)
 	}
 }
 
+//Commands returns the commands owned by this handler
+func (ach *AlternatingCaseHandler) Commands() []Command {
+	return []Command{{
+		Prefix:      strings.TrimSpace(acCommand),
+		Description: "Alternate Case - aLtErNaTeS yOuR tExT",
+		Options: []CommandOption{
+			{Name: "text", Description: "Text to alternate", Type: discordgo.ApplicationCommandOptionString, Required: true},
+		},
+	}}
+}
+
 //Help Gets info about this handler
 func (ach *AlternatingCaseHandler) Help() string {
 	return acCommand + ": Alternate Case - takes input string and aLtErNaTeS iT!"
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//AuditHandler lets guild admins see who changed what with commands, or export it
+type AuditHandler struct {
+	handlerLoop
+	session Session
+	entries *AuditLog
+}
+
+const auditCommand = "/audit"
+
+//maxAuditListed bounds how many entries are listed in chat, the rest can be exported
+const maxAuditListed = 100
+
+//NewAuditHandler creates a handler which reads back the provided audit log
+func NewAuditHandler(session Session, entries *AuditLog) *AuditHandler {
+	return &AuditHandler{session: session, entries: entries}
+}
+
+//Init spins up our channel handling
+func (ah *AuditHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	ah.listen(ctx, ah.GetName(), m, ah.handleMessage)
+}
+
+//GetName returns our name
+func (ah *AuditHandler) GetName() string {
+	return "Audit Handler"
+}
+
+//Commands returns the commands owned by this handler
+func (ah *AuditHandler) Commands() []Command {
+	return []Command{{
+		Prefix:      auditCommand,
+		Description: "See who changed what with commands",
+		Capability:  "audit.view",
+		Options: []CommandOption{
+			{Name: "filter", Description: "Command, handler or @user, eg rw", Type: discordgo.ApplicationCommandOptionString},
+			{Name: "period", Description: "How far back to look, eg 12h or 7d (the default)", Type: discordgo.ApplicationCommandOptionString},
+			{Name: "as", Description: "List the changes here, or export them as a file", Type: discordgo.ApplicationCommandOptionString, Choices: []string{"list", "export"}},
+		},
+	}}
+}
+
+//Help Gets info about this handler
+func (ah *AuditHandler) Help() string {
+	return auditCommand + " : Audit - See who changed what with commands, eg " + auditCommand + " rw 7d, or add export for a file"
+}
+
+func (ah *AuditHandler) handleMessage(m *discordgo.MessageCreate) {
+	fields := strings.Fields(m.Content)
+	if len(fields) == 0 || fields[0] != auditCommand {
+		return
+	}
+
+	//Arguments can come in any order, as slash commands leave out the ones not given
+	export := false
+	filter := ""
+	period := ""
+	for _, field := range fields[1:] {
+		if field == "export" || field == "list" {
+			export = field == "export"
+		} else if _, ok := parsePeriod(field); ok && period == "" {
+			period = field
+		} else if filter == "" {
+			filter = field
+		} else {
+			ah.session.SendMessage(m.ChannelID, localizeCommands(m.GuildID, "Usage: "+auditCommand+" [command|handler|@user] [period, eg 7d] [export]"))
+			return
+		}
+	}
+
+	query, err := newAuditQuery(m.GuildID, filter, period)
+	if err != nil {
+		ah.session.SendMessage(m.ChannelID, err.Error())
+		return
+	}
+	//Only owners can look outside a guild, and they're after everything
+	query.allGuilds = m.GuildID == ""
+
+	entries, err := ah.entries.Query(query.matches)
+	if err != nil {
+		ah.log.Error("Error reading audit log", "error", err)
+		ah.session.SendMessage(m.ChannelID, "Error reading audit log: "+err.Error())
+		return
+	}
+	if period == "" {
+		period = defaultAuditPeriod
+	}
+	if len(entries) == 0 {
+		ah.session.SendMessage(m.ChannelID, "No changes in the last "+period+describeFilter(filter))
+		return
+	}
+
+	if export {
+		ah.export(m.ChannelID, entries)
+	} else {
+		ah.list(m, entries, period, filter)
+	}
+}
+
+func describeFilter(filter string) string {
+	if filter == "" {
+		return ""
+	}
+
+	return " matching " + filter
+}
+
+//list shows the newest entries, newest first
+func (ah *AuditHandler) list(m *discordgo.MessageCreate, entries []auditEntry, period string, filter string) {
+	location := userLocation(m.GuildID, m.Author.ID)
+	embed := newEmbed("Audit log")
+	embed.Description = strconv.Itoa(len(entries)) + " changes in the last " + period + describeFilter(filter)
+	if len(entries) > maxAuditListed {
+		embed.Description += ", showing the latest " + strconv.Itoa(maxAuditListed) + ". Add export to get them all"
+		entries = entries[len(entries)-maxAuditListed:]
+	}
+
+	for x := len(entries) - 1; x >= 0; x-- {
+		entry := entries[x]
+		name := entry.Time.In(location).Format("Jan 2 15:04 MST") + " " + entry.Handler
+		value := entry.summary() + "\n`" + strings.Replace(entry.Command, "`", "'", -1) + "`"
+		embed.Fields = append(embed.Fields, embedField(name, value, false))
+	}
+
+	_, _ = sendPaged(ah.session, m.ChannelID, embed)
+}
+
+//export uploads the entries as json lines, in the same format as the audit log itself
+func (ah *AuditHandler) export(channelID string, entries []auditEntry) {
+	dir, err := ioutil.TempDir("", "diskhard-audit")
+	if err != nil {
+		ah.log.Error("Error exporting audit log", "error", err)
+		ah.session.SendMessage(channelID, "Error exporting audit log: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.jsonl")
+	file, err := os.Create(path)
+	if err == nil {
+		encoder := json.NewEncoder(file)
+		for _, entry := range entries {
+			if err = encoder.Encode(entry); err != nil {
+				break
+			}
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		ah.log.Error("Error exporting audit log", "error", err)
+		ah.session.SendMessage(channelID, "Error exporting audit log: "+err.Error())
+		return
+	}
+
+	//Waits until it's uploaded, so the file is still there
+	if err := ah.session.SendFile(channelID, path); err != nil {
+		ah.log.Error("Error uploading audit log", "error", err)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//auditFile is where the audit log is kept, in the data directory whichever storage backend is used
+const auditFile = "audit.jsonl"
+
+//maxAuditLine bounds how long an audit entry can be, before/after values included, when reading the log back
+const maxAuditLine = 1024 * 1024
+
+//auditEntry Is a single change made by a command, or through the admin API
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	GuildID   string    `json:"guildID,omitempty"`
+	ChannelID string    `json:"channelID,omitempty"`
+	UserID    string    `json:"userID,omitempty"`
+	//User is the name of whoever made the change, eg admin API for changes made there
+	User    string `json:"user"`
+	Handler string `json:"handler"`
+	Action  string `json:"action"`
+	//Command is the message which made the change, with our own command prefix, or the admin API request
+	Command string          `json:"command"`
+	Before  json.RawMessage `json:"before,omitempty"`
+	After   json.RawMessage `json:"after,omitempty"`
+}
+
+//AuditLog Records who changed what, appending each change as a line of json so entries are never rewritten
+//Changes in a guild are mirrored to its mod log channel, if it's set one
+type AuditLog struct {
+	mutex   sync.Mutex
+	path    string
+	session Session
+}
+
+var auditLog *AuditLog
+
+//NewAuditLog creates a log appending to the file, and mirroring through the session
+func NewAuditLog(session Session, path string) *AuditLog {
+	return &AuditLog{path: path, session: session}
+}
+
+//recordAudit notes a change made by the message in the audit log, if there is one. Changes the bot makes itself aren't recorded
+func recordAudit(handler string, m *discordgo.MessageCreate, action string, before interface{}, after interface{}) {
+	if auditLog == nil || m == nil {
+		return
+	}
+
+	entry := auditEntry{
+		Time:      time.Now().UTC(),
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Handler:   handler,
+		Action:    action,
+		Command:   m.Content,
+		Before:    auditValue(before),
+		After:     auditValue(after),
+	}
+	if m.Author != nil {
+		entry.UserID = m.Author.ID
+		entry.User = m.Author.Username
+	}
+
+	if err := auditLog.Record(entry); err != nil {
+		logger.Error("Error recording audit entry", "handler", handler, "action", action, "error", err)
+	}
+}
+
+//auditValue encodes the value as it was when the change was made, as it may well change again later
+func auditValue(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		encoded, _ = json.Marshal(err.Error())
+	}
+
+	return encoded
+}
+
+//Record appends the entry to the log, then mirrors it to the guild's mod log
+func (al *AuditLog) Record(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	file, err := os.OpenFile(al.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if guildSettingsStore != nil && entry.GuildID != "" {
+		if modLog := guildSettingsStore.ModLog(entry.GuildID); modLog != "" {
+			//Queued, so a slow mod log doesn't hold up the handler
+			queueOutbound(al.session, Outbound{
+				ChannelID: modLog,
+				Complex:   &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{entry.embed()}},
+				Priority:  LowPriority,
+			})
+		}
+	}
+
+	return nil
+}
+
+//Query reads back the entries which match, oldest first. Lines which can't be read are skipped
+func (al *AuditLog) Query(matches func(auditEntry) bool) ([]auditEntry, error) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	entries := make([]auditEntry, 0)
+	file, err := os.Open(al.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+	for line := 1; scanner.Scan(); line++ {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logger.Warn("Skipping unreadable audit entry", "path", al.path, "line", line, "error", err)
+			continue
+		}
+		if matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+//who names whoever made the change, mentioning them where we can
+func (ae auditEntry) who() string {
+	if ae.UserID != "" {
+		return "<@" + ae.UserID + ">"
+	}
+
+	return ae.User
+}
+
+//describeValue shows a before or after value, or none if there wasn't one
+func describeValue(value json.RawMessage) string {
+	if len(value) == 0 || string(value) == "null" {
+		return "none"
+	}
+
+	return "`" + strings.Replace(string(value), "`", "'", -1) + "`"
+}
+
+//summary describes the entry on a single line
+func (ae auditEntry) summary() string {
+	summary := ae.who() + " " + ae.Action + ", " + describeValue(ae.Before) + " → " + describeValue(ae.After)
+	if ae.ChannelID != "" {
+		summary += " in <#" + ae.ChannelID + ">"
+	}
+
+	return summary
+}
+
+func (ae auditEntry) embed() *discordgo.MessageEmbed {
+	embed := newEmbed(ae.Handler + ": " + ae.Action)
+	embed.Timestamp = ae.Time.Format(time.RFC3339)
+	embed.Fields = append(embed.Fields, embedField("By", ae.who(), true))
+	if ae.ChannelID != "" {
+		embed.Fields = append(embed.Fields, embedField("Channel", "<#"+ae.ChannelID+">", true))
+	}
+	embed.Fields = append(embed.Fields,
+		embedField("Command", "`"+strings.Replace(ae.Command, "`", "'", -1)+"`", false),
+		embedField("Before", describeValue(ae.Before), false),
+		embedField("After", describeValue(ae.After), false),
+	)
+
+	return embed
+}
+
+//defaultAuditPeriod is how far back we look when not told otherwise
+const defaultAuditPeriod = "7d"
+
+var userMentionMatcher = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+//auditQuery Picks out audit entries made since a time, in a guild unless allGuilds is set,
+//and by a user, or handler however its name or command was written, if filtered
+type auditQuery struct {
+	guildID   string
+	allGuilds bool
+	since     time.Time
+	userID    string
+	handler   string
+	prefix    string
+}
+
+//newAuditQuery looks back over the period, eg 7d, defaulting to defaultAuditPeriod
+//The filter is a user mention, a command like rw, or a handler like release
+func newAuditQuery(guildID string, filter string, period string) (auditQuery, error) {
+	if period == "" {
+		period = defaultAuditPeriod
+	}
+	duration, ok := parsePeriod(period)
+	if !ok {
+		return auditQuery{}, errors.New(period + " isn't a period, try eg 12h, 7d or 2w")
+	}
+
+	query := auditQuery{guildID: guildID, since: time.Now().Add(-duration)}
+	if match := userMentionMatcher.FindStringSubmatch(filter); match != nil {
+		query.userID = match[1]
+	} else if filter != "" {
+		//Commands find their handler, so changes made through the admin API match too
+		query.prefix = commandPrefix + strings.TrimPrefix(filter, commandPrefix)
+		query.handler = handlerKey(filter)
+		if commandRouter != nil {
+			if _, handler, ok := commandRouter.Lookup(query.prefix); ok {
+				query.handler = handlerKey(handler.GetName())
+			}
+		}
+	}
+
+	return query, nil
+}
+
+//matches checks the entry is one the query is after
+func (aq auditQuery) matches(entry auditEntry) bool {
+	if entry.Time.Before(aq.since) || (!aq.allGuilds && entry.GuildID != aq.guildID) {
+		return false
+	}
+	if aq.userID != "" {
+		return entry.UserID == aq.userID
+	}
+	if aq.handler != "" {
+		fields := strings.Fields(entry.Command)
+		return handlerKey(entry.Handler) == aq.handler || (len(fields) > 0 && fields[0] == aq.prefix)
+	}
+
+	return true
+}
+
+var periodMatcher = regexp.MustCompile(`^(\d+)([mhdw])$`)
+
+//parsePeriod reads how far back to look, eg 30m, 12h, 7d or 2w
+func parsePeriod(period string) (time.Duration, bool) {
+	match := periodMatcher.FindStringSubmatch(period)
+	if match == nil {
+		return 0, false
+	}
+
+	count, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[match[2]]
+	return time.Duration(count) * unit, true
+}
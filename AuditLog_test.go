@@ -0,0 +1,174 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useTestAuditLog(t *testing.T, session Session) *AuditLog {
+	log := NewAuditLog(session, auditFile)
+	auditLog = log
+	t.Cleanup(func() { auditLog = nil })
+	return log
+}
+
+//exportingSession Keeps the contents of uploaded files, which are removed once they're sent
+type exportingSession struct {
+	*FakeSession
+	uploaded string
+}
+
+func (es *exportingSession) SendFile(channelID string, filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	es.uploaded = string(data)
+	return err
+}
+
+func TestReleaseChangesAreAudited(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	entries := useTestAuditLog(t, session)
+	h := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	h.Say("chan", "adder", "/rw add "+daysAhead(30).Format("01/02/06")+" Persona 8")
+	h.Say("chan", "deleter", "/rw delete 0")
+	h.Say("chan", "reader", "/rw list")
+
+	recorded, err := entries.Query(func(auditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("expected the add and delete to be recorded, got %+v", recorded)
+	}
+	deleted := recorded[1]
+	if deleted.UserID != "deleter" || deleted.Action != "delete" || deleted.Command != "/rw delete 0" || deleted.Handler != "Release Handler" {
+		t.Errorf("expected who deleted the release, and how, got %+v", deleted)
+	}
+	assertContains(t, string(deleted.Before), "Persona 8")
+	if deleted.After != nil {
+		t.Errorf("expected nothing after a delete, got %s", deleted.After)
+	}
+}
+
+func TestAuditCommandFiltersAndExports(t *testing.T) {
+	useTempDir(t)
+	session := &exportingSession{FakeSession: &FakeSession{}}
+	entries := useTestAuditLog(t, session)
+	entries.Record(auditEntry{Time: time.Now().Add(-48 * time.Hour), UserID: "old", Handler: "Release Handler", Action: "add", Command: "/rw add 1/1/35 Old"})
+	recordAudit("Release Handler", guildMessage("", "/rw delete 1"), "delete", releaseData{Name: "Recent"}, nil)
+	settingsChange := guildMessage("", "/settings prefix !")
+	settingsChange.Author.ID = "42"
+	recordAudit("Settings Handler", settingsChange, "prefix", "/", "!")
+	h := newHandlerHarness(t, NewAuditHandler(session, entries))
+
+	h.Say("chan", "owner", "/audit rw 1d")
+	listed := session.LastSent("chan")
+	assertContains(t, listed, "1 changes in the last 1d matching rw")
+	assertContains(t, listed, "Recent")
+	if strings.Contains(listed, "Old") || strings.Contains(listed, "prefix") {
+		t.Errorf("expected only recent release changes, got %s", listed)
+	}
+
+	h.Say("chan", "owner", "/audit <@42> export 3d")
+	assertContains(t, session.uploaded, `"command":"/settings prefix !"`)
+	if strings.Contains(session.uploaded, "/rw") {
+		t.Errorf("expected only the user's changes to be exported, got %s", session.uploaded)
+	}
+
+	h.Say("chan", "owner", "/audit rw 7x")
+	assertContains(t, session.LastSent("chan"), "Usage: /audit")
+	h.Say("chan", "owner", "/audit fortune")
+	assertContains(t, session.LastSent("chan"), "No changes in the last 7d matching fortune")
+}
+
+func TestAuditMirrorsToModLog(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	useTestAuditLog(t, session)
+	settings := NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC)
+	guildSettingsStore = settings
+	t.Cleanup(func() { guildSettingsStore = nil })
+
+	if err := settings.SetModLog("guild", "#mods"); err == nil {
+		t.Error("expected a channel name rather than mention to be refused")
+	}
+	if err := settings.SetModLog("guild", "<#123>"); err != nil {
+		t.Fatal(err)
+	}
+
+	recordAudit("Reminder Handler", guildMessage("guild", "/rm tz 0 Asia/Tokyo"), "tz", "", "Asia/Tokyo")
+	recordAudit("Reminder Handler", guildMessage("elsewhere", "/rm tz 0 UTC"), "tz", "", "UTC")
+	mirrored := session.LastSent("123")
+	assertContains(t, mirrored, "Reminder Handler: tz")
+	assertContains(t, mirrored, "Asia/Tokyo")
+	if len(session.Sent("123")) != 1 {
+		t.Errorf("expected only the guild's changes to be mirrored, got %v", session.Sent("123"))
+	}
+}
+
+func TestAdminChangesAreAudited(t *testing.T) {
+	useTempDir(t)
+	entries := useTestAuditLog(t, &FakeSession{})
+	server := newTestAdminServer(t, NewReactionHandler(&FakeSession{}, NewJSONFileStore(".")))
+
+	adminRequest(t, server, "POST", "/api/reactions", `{"TriggerWord": "hello", "Reaction": "👋"}`)
+	status, body := adminRequest(t, server, "GET", "/api/audit?filter=reaction", "")
+	if status != 200 {
+		t.Fatalf("expected the audit log, got %d %s", status, body)
+	}
+	assertContains(t, body, `"user":"admin API"`)
+	assertContains(t, body, `"command":"POST /api/reactions"`)
+
+	recorded, _ := entries.Query(func(auditEntry) bool { return true })
+	if len(recorded) != 1 || recorded[0].Before != nil {
+		t.Errorf("expected a single new reaction, got %+v", recorded)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for period, expected := range map[string]time.Duration{"30m": 30 * time.Minute, "12h": 12 * time.Hour, "7d": 7 * 24 * time.Hour, "2w": 14 * 24 * time.Hour} {
+		if parsed, ok := parsePeriod(period); !ok || parsed != expected {
+			t.Errorf("expected %s to be %s, got %s", period, expected, parsed)
+		}
+	}
+	if _, ok := parsePeriod("7"); ok {
+		t.Error("expected a period without a unit to be refused")
+	}
+}
+
+func TestAdminChangesAreAuditedInTheChannelsGuild(t *testing.T) {
+	useTempDir(t)
+	entries := useTestAuditLog(t, &FakeSession{})
+	session := &FakeSession{channelGuilds: map[string]string{"chan": "guild"}}
+	server := newTestAdminServer(t, NewReleaseHandler(session, NewJSONFileStore(".")), NewReminderHandler(session, NewJSONFileStore(".")))
+
+	added := daysAhead(30).Format("01/02/06")
+	adminRequest(t, server, "POST", "/api/releases/chan", `{"name": "Persona 8", "releaseDate": "`+added+`", "guildID": "elsewhere"}`)
+	adminRequest(t, server, "POST", "/api/releases/lost", `{"name": "Persona 8", "releaseDate": "`+added+`", "guildID": "stored"}`)
+	adminRequest(t, server, "POST", "/api/releases/lost", `{"name": "Persona 9", "releaseDate": "`+added+`"}`)
+	adminRequest(t, server, "DELETE", "/api/releases/lost/0", "")
+	adminRequest(t, server, "POST", "/api/reminders/chan", `{"name": "Standup", "hour": 9, "minute": 30, "days": [1]}`)
+
+	recorded, _ := entries.Query(func(auditEntry) bool { return true })
+	guilds := make([]string, 0)
+	for _, entry := range recorded {
+		guilds = append(guilds, entry.GuildID)
+	}
+	if strings.Join(guilds, ",") != "guild,stored,stored,stored,guild" {
+		t.Errorf("expected releases to use their channel's guild, then the body's, and reminders to look theirs up, got %v", guilds)
+	}
+
+	//What was audited is also what was saved
+	var data []channelReleaseData
+	if err := NewJSONFileStore(".").Load(releaseDocument, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, channel := range data {
+		if expected := map[string]string{"chan": "guild", "lost": "stored"}[channel.ChannelID]; channel.GuildID != expected {
+			t.Errorf("expected #%s to be saved in %s, got %q", channel.ChannelID, expected, channel.GuildID)
+		}
+	}
+}
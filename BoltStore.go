@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var documentBucket = []byte("documents")
+
+//BoltStore Keeps every document in a single embedded bbolt database
+type BoltStore struct {
+	db *bolt.DB
+}
+
+//NewBoltStore opens, or creates, the database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	//Only one process can hold the database, so don't hang forever if another bot is running
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(documentBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+//Load reads the document from the database
+func (bs *BoltStore) Load(name string, v interface{}) error {
+	return bs.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(documentBucket).Get([]byte(name))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(value, v)
+	})
+}
+
+//Save replaces the document in the database
+func (bs *BoltStore) Save(name string, v interface{}) error {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(documentBucket).Put([]byte(name), jsonBytes)
+	})
+}
+
+//Close releases the database
+func (bs *BoltStore) Close() error {
+	return bs.db.Close()
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//Command Describes a command prefix owned by a handler, eg /rw, along with the subcommands it accepts
+//Commands without subcommands receive everything following the prefix
+type Command struct {
+	Prefix      string
+	Description string
+	Subcommands []Subcommand
+	//Options are only used by commands without subcommands
+	Options []CommandOption
+	//Capability is required to use the command, unless its subcommand declares its own
+	Capability string
+}
+
+//Subcommand Describes a single subcommand, eg the add in /rw add
+type Subcommand struct {
+	Name        string
+	Description string
+	Options     []CommandOption
+	Capability  string
+}
+
+//CommandOption Describes a typed argument of a (sub)command. Options are written out as text in declared order
+type CommandOption struct {
+	Name         string
+	Description  string
+	Type         discordgo.ApplicationCommandOptionType
+	Required     bool
+	Choices      []string
+	Autocomplete bool
+	MinValue     *float64
+	MaxValue     float64
+}
+
+//CommandHandler is implemented by handlers which own commands
+//Handlers that do not implement it are treated as passive listeners and see all traffic
+type CommandHandler interface {
+	Commands() []Command
+}
+
+type commandRoute struct {
+	command Command
+	handler MessageHandler
+	queue   *HandlerQueue
+}
+
+//CommandRouter dispatches commands to the single handler that owns them
+type CommandRouter struct {
+	session      Session
+	settings     *GuildSettingsStore
+	permissions  *PermissionStore
+	routes       map[string]*commandRoute
+	prefixes     []string
+	listeners    []*commandRoute
+	commandRegex *regexp.Regexp
+}
+
+//NewCommandRouter creates an empty router, using the guild settings to determine command prefixes
+//and the permission store to decide who may use them
+func NewCommandRouter(session Session, settings *GuildSettingsStore, permissions *PermissionStore) *CommandRouter {
+	return &CommandRouter{
+		session:     session,
+		settings:    settings,
+		permissions: permissions,
+		routes:      make(map[string]*commandRoute),
+		prefixes:    make([]string, 0),
+		listeners:   make([]*commandRoute, 0),
+	}
+}
+
+//Register adds a handler to the router. Command handlers only receive their own commands,
+//everything else is considered a passive listener
+func (cr *CommandRouter) Register(handler MessageHandler, queue *HandlerQueue) error {
+	commandHandler, ok := handler.(CommandHandler)
+	if !ok {
+		cr.listeners = append(cr.listeners, &commandRoute{handler: handler, queue: queue})
+		return nil
+	}
+
+	for _, command := range commandHandler.Commands() {
+		if existing, exists := cr.routes[command.Prefix]; exists {
+			return fmt.Errorf("command %s registered by both %s and %s", command.Prefix, existing.handler.GetName(), handler.GetName())
+		}
+
+		cr.routes[command.Prefix] = &commandRoute{
+			command: command,
+			handler: handler,
+			queue:   queue,
+		}
+		cr.prefixes = append(cr.prefixes, command.Prefix)
+	}
+
+	cr.buildCommandRegex()
+	return nil
+}
+
+//buildCommandRegex matches mentions of our commands in text, so they can be shown with a guild's own prefix
+func (cr *CommandRouter) buildCommandRegex() {
+	names := make([]string, 0, len(cr.prefixes))
+	for _, prefix := range cr.prefixes {
+		names = append(names, regexp.QuoteMeta(strings.TrimPrefix(prefix, commandPrefix)))
+	}
+	//Longest first, so /ip is preferred over /i
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	cr.commandRegex = regexp.MustCompile("(^|[\\s`(])" + regexp.QuoteMeta(commandPrefix) + "(" + strings.Join(names, "|") + ")\\b")
+}
+
+//Localize rewrites mentions of our commands in the text to use the guild's prefix
+func (cr *CommandRouter) Localize(guildID string, text string) string {
+	prefix := cr.settings.Prefix(guildID)
+	if prefix == commandPrefix || cr.commandRegex == nil {
+		return text
+	}
+
+	return cr.commandRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return strings.Replace(match, commandPrefix, prefix, 1)
+	})
+}
+
+//Route passes the message to the owner of the command it contains (if any) and to the passive listeners switched on in its channel
+func (cr *CommandRouter) Route(m *discordgo.MessageCreate) {
+	if command := cr.canonicalize(m); command != nil {
+		cr.RouteCommand(command)
+	}
+
+	for _, listener := range cr.listeners {
+		if handlerActive(listener.handler.GetName(), m.GuildID, m.ChannelID) {
+			listener.queue.Push(m)
+		}
+	}
+}
+
+//RouteCommand passes a message, already using our own command prefix, to the owner of its command
+func (cr *CommandRouter) RouteCommand(m *discordgo.MessageCreate) {
+	fields := strings.Fields(m.Content)
+	if len(fields) == 0 {
+		return
+	}
+
+	if route, ok := cr.routes[fields[0]]; ok {
+		subcommand := ""
+		if len(fields) > 1 {
+			subcommand = fields[1]
+		}
+
+		if supervisor.Disabled(route.handler.GetName()) {
+			cr.session.SendMessage(m.ChannelID, "Sorry, `"+cr.Localize(m.GuildID, route.command.Prefix)+"` is switched off after repeated errors")
+		} else if !handlerActive(route.handler.GetName(), m.GuildID, m.ChannelID) {
+			cr.session.SendMessage(m.ChannelID, "Sorry, `"+cr.Localize(m.GuildID, route.command.Prefix)+"` is switched off in this channel")
+		} else if route.command.accepts(subcommand) {
+			capability := route.command.capability(subcommand)
+			if cr.permissions.Allowed(m, capability) {
+				//Commands without subcommands take free text, which would make for endless labels
+				if len(route.command.Subcommands) == 0 {
+					subcommand = ""
+				}
+				metrics.Inc(metricCommandsHandled, route.handler.GetName(), route.command.Prefix, subcommand)
+				route.queue.Push(m)
+			} else {
+				used := strings.TrimSpace(route.command.Prefix + " " + subcommand)
+				if len(route.command.Subcommands) == 0 {
+					used = route.command.Prefix
+				}
+				denied := "Sorry, `" + used + "` needs the " + capability + " permission. " +
+					"A server admin can grant it with `" + permCommand + " grant " + capability + " <@role|@user>`"
+				cr.session.SendMessage(m.ChannelID, cr.Localize(m.GuildID, denied))
+			}
+		} else {
+			cr.session.SendMessage(m.ChannelID, cr.Localize(m.GuildID, route.command.unknownSubcommand(subcommand)))
+		}
+	} else if suggestion := cr.suggestPrefix(fields[0]); suggestion != "" {
+		typed := cr.settings.Prefix(m.GuildID) + strings.TrimPrefix(fields[0], commandPrefix)
+		cr.session.SendMessage(m.ChannelID, "Unknown command `"+typed+"`, did you mean `"+cr.Localize(m.GuildID, suggestion)+"`?")
+	}
+}
+
+//canonicalize swaps the guild's prefix for our own, so handlers don't need to care which is in use
+//Returns nil if the message isn't using the guild's prefix
+func (cr *CommandRouter) canonicalize(m *discordgo.MessageCreate) *discordgo.MessageCreate {
+	prefix := cr.settings.Prefix(m.GuildID)
+	if !strings.HasPrefix(m.Content, prefix) {
+		return nil
+	}
+
+	if prefix == commandPrefix {
+		return m
+	}
+
+	canonical := *m.Message
+	canonical.Content = commandPrefix + m.Content[len(prefix):]
+	return &discordgo.MessageCreate{Message: &canonical}
+}
+
+//Lookup finds the command registered for the prefix, along with the handler owning it
+func (cr *CommandRouter) Lookup(prefix string) (*Command, MessageHandler, bool) {
+	route, ok := cr.routes[prefix]
+	if !ok {
+		return nil, nil, false
+	}
+
+	return &route.command, route.handler, true
+}
+
+//Commands returns every registered command, in registration order
+func (cr *CommandRouter) Commands() []*Command {
+	commands := make([]*Command, 0, len(cr.prefixes))
+	for _, prefix := range cr.prefixes {
+		commands = append(commands, &cr.routes[prefix].command)
+	}
+
+	return commands
+}
+
+//Capabilities returns every capability declared by our commands
+func (cr *CommandRouter) Capabilities() []string {
+	capabilities := make([]string, 0)
+	for _, command := range cr.Commands() {
+		if command.Capability != "" && !containsString(capabilities, command.Capability) {
+			capabilities = append(capabilities, command.Capability)
+		}
+		for _, subcommand := range command.Subcommands {
+			if subcommand.Capability != "" && !containsString(capabilities, subcommand.Capability) {
+				capabilities = append(capabilities, subcommand.Capability)
+			}
+		}
+	}
+	sort.Strings(capabilities)
+
+	return capabilities
+}
+
+//suggestPrefix finds a registered prefix that looks like a typo of the provided one
+func (cr *CommandRouter) suggestPrefix(prefix string) string {
+	if !strings.HasPrefix(prefix, commandPrefix) {
+		return ""
+	}
+
+	best := ""
+	bestDistance := -1
+	for _, candidate := range cr.prefixes {
+		distance := editDistance(prefix, candidate)
+		//Short commands like /i are too easy to hit by accident, so scale our tolerance by length
+		if distance <= 2 && distance*2 < len(candidate)-1 && (bestDistance == -1 || distance < bestDistance) {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+func (c *Command) accepts(subcommand string) bool {
+	if len(c.Subcommands) == 0 {
+		return true
+	}
+
+	return c.subcommand(subcommand) != nil
+}
+
+//capability returns the capability needed to use the subcommand
+func (c *Command) capability(subcommand string) string {
+	if sub := c.subcommand(subcommand); sub != nil && sub.Capability != "" {
+		return sub.Capability
+	}
+
+	return c.Capability
+}
+
+func (c *Command) subcommand(name string) *Subcommand {
+	for x := range c.Subcommands {
+		if c.Subcommands[x].Name == name {
+			return &c.Subcommands[x]
+		}
+	}
+
+	return nil
+}
+
+func (c *Command) subcommandNames() []string {
+	names := make([]string, 0, len(c.Subcommands))
+	for _, subcommand := range c.Subcommands {
+		names = append(names, subcommand.Name)
+	}
+
+	return names
+}
+
+func (c *Command) unknownSubcommand(subcommand string) string {
+	best := ""
+	bestDistance := -1
+	for _, candidate := range c.subcommandNames() {
+		distance := editDistance(subcommand, candidate)
+		if bestDistance == -1 || distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	if subcommand == "" {
+		return "`" + c.Prefix + "` needs a subcommand, try one of: " + strings.Join(c.subcommandNames(), ", ")
+	}
+
+	//Way off from anything we know, so point at help instead if we have it
+	if bestDistance > len(subcommand)/2+1 && c.accepts("help") {
+		best = "help"
+	}
+
+	return "Unknown command `" + c.Prefix + " " + subcommand + "`, did you mean `" + c.Prefix + " " + best + "`?"
+}
+
+//editDistance calculates the levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ar := []rune(a)
+	br := []rune(b)
+
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j] + 1
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(br)]
+}
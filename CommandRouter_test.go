@@ -0,0 +1,218 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newTestRouter(t *testing.T) (*CommandRouter, *FakeSession, *HandlerQueue) {
+	useTempDir(t)
+	session := &FakeSession{}
+	router := NewCommandRouter(session, NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC), NewPermissionStore(session, NewJSONFileStore("."), []string{"owner"}))
+	queue := NewHandlerQueue("Release Handler", QueueConfiguration{})
+	if err := router.Register(NewReleaseHandler(session, NewJSONFileStore(".")), queue); err != nil {
+		t.Fatal(err)
+	}
+
+	return router, session, queue
+}
+
+func guildMessage(guildID string, content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "in",
+		GuildID:   guildID,
+		ChannelID: "chan",
+		Content:   content,
+		Author:    &discordgo.User{ID: "user"},
+	}}
+}
+
+func TestRouterDispatchesToOwner(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+
+	router.Route(guildMessage("guild", "/rw list"))
+	router.Route(guildMessage("guild", "hello there"))
+	if queue.Depth() != 1 {
+		t.Errorf("expected only the command to be queued, got %d", queue.Depth())
+	}
+
+	router.Route(guildMessage("guild", "/rw lsit"))
+	assertContains(t, session.LastSent("chan"), "did you mean `/rw list`?")
+}
+
+func TestRouterRegistersAndLooksUpCommands(t *testing.T) {
+	router, session, _ := newTestRouter(t)
+
+	if err := router.Register(NewReleaseHandler(session, NewJSONFileStore(".")), NewHandlerQueue("Release Handler", QueueConfiguration{})); err == nil {
+		t.Error("expected a second owner of /rw to be refused")
+	}
+
+	command, handler, ok := router.Lookup(rwCommand)
+	if !ok || command.Prefix != rwCommand || handler.GetName() != "Release Handler" {
+		t.Errorf("expected /rw to belong to the release handler, got %v %v", command, handler)
+	}
+	if _, _, ok := router.Lookup("/nope"); ok {
+		t.Error("expected unknown prefixes not to be found")
+	}
+	if commands := router.Commands(); len(commands) != 1 || commands[0].Prefix != rwCommand {
+		t.Errorf("expected only /rw to be registered, got %v", commands)
+	}
+	assertContains(t, strings.Join(router.Capabilities(), ","), "releases.delete,releases.edit")
+}
+
+func TestRouterSendsCommandsToOwnerAndEverythingToListeners(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+	listener := NewHandlerQueue("Reaction Handler", QueueConfiguration{})
+	router.Register(NewReactionHandler(session, NewJSONFileStore(".")), listener)
+
+	router.Route(guildMessage("guild", "/rw list"))
+	router.Route(guildMessage("guild", "hello there"))
+	if queue.Depth() != 1 || listener.Depth() != 2 {
+		t.Errorf("expected the owner to get its command and the listener everything, got %d and %d", queue.Depth(), listener.Depth())
+	}
+}
+
+func TestRouterSuggestsCommands(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+	router.Register(NewReminderHandler(session, NewJSONFileStore(".")), NewHandlerQueue("Reminder Handler", QueueConfiguration{}))
+
+	for _, test := range []struct {
+		content  string
+		expected string
+	}{
+		{"/remnid list", "Unknown command `/remnid`, did you mean `/remind`?"},
+		{"/rw", "`/rw` needs a subcommand, try one of: add, list, edit, delete, tz, help"},
+		{"/rw lst", "did you mean `/rw list`?"},
+		{"/rw frobnicate", "did you mean `/rw help`?"},
+	} {
+		session.Reset()
+		router.Route(guildMessage("guild", test.content))
+		assertContains(t, session.LastSent("chan"), test.expected)
+	}
+
+	//Short prefixes are too easy to hit by accident, and anything else isn't a command at all
+	session.Reset()
+	router.Route(guildMessage("guild", "/rx list"))
+	router.Route(guildMessage("guild", "rw list"))
+	if session.Count("send") != 0 || queue.Depth() != 0 {
+		t.Errorf("expected no suggestions, got %+v", session.Calls())
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"list", "list", 0},
+		{"lsit", "list", 2},
+		{"lst", "list", 1},
+		{"", "add", 3},
+		{"héllo", "hello", 1},
+	} {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("expected %q to %q to be %d, got %d", test.a, test.b, test.distance, distance)
+		}
+	}
+}
+
+func TestRouterUsesGuildPrefix(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+	router.settings.SetPrefix("guild", "dk.")
+
+	router.Route(guildMessage("guild", "/rw list"))
+	if queue.Depth() != 0 {
+		t.Error("default prefix should be ignored once the guild changes it")
+	}
+
+	router.Route(guildMessage("guild", "dk.rw list"))
+	if queued := <-queue.Channel(); queued.Content != "/rw list" {
+		t.Errorf("expected handler to see canonical command, got %q", queued.Content)
+	}
+
+	router.Route(guildMessage("guild", "dk.rw lsit"))
+	assertContains(t, session.LastSent("chan"), "did you mean `dk.rw list`?")
+
+	//Other guilds are unaffected
+	router.Route(guildMessage("other", "/rw list"))
+	if queue.Depth() != 1 {
+		t.Error("expected other guild to keep the default prefix")
+	}
+}
+
+func TestRouterLocalize(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	router.settings.SetPrefix("guild", "!")
+
+	localized := router.Localize("guild", "/rw add - see /rw help, but not path/rw or /rwx")
+	if localized != "!rw add - see !rw help, but not path/rw or /rwx" {
+		t.Errorf("unexpected localization %q", localized)
+	}
+}
+
+func TestRouterEnforcesCapabilities(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+
+	router.Route(guildMessage("guild", "/rw delete 0"))
+	if queue.Depth() != 0 {
+		t.Fatal("delete should require a grant")
+	}
+	assertContains(t, session.LastSent("chan"), "needs the releases.delete permission")
+
+	//Role grants apply to members holding the role
+	router.permissions.Grant("guild", "releases.delete", "mods", "")
+	member := guildMessage("guild", "/rw delete 0")
+	member.Member = &discordgo.Member{Roles: []string{"mods"}}
+	router.Route(member)
+	if queue.Depth() != 1 {
+		t.Error("expected role grant to allow delete")
+	}
+
+	//Grants don't leak into other guilds
+	router.Route(guildMessage("other", "/rw delete 0"))
+	if queue.Depth() != 1 {
+		t.Error("expected grant to be limited to its guild")
+	}
+
+	//Admins and owners need no grant
+	session.permissions = map[string]int64{"admin": discordgo.PermissionAdministrator}
+	admin := guildMessage("other", "/rw delete 0")
+	admin.Author.ID = "admin"
+	owner := guildMessage("", "/rw delete 0")
+	owner.Author.ID = "owner"
+	router.Route(admin)
+	router.Route(owner)
+	if queue.Depth() != 3 {
+		t.Errorf("expected admin and owner to be allowed, queue has %d", queue.Depth())
+	}
+
+	//Commands without a capability are open to everyone
+	router.Route(guildMessage("other", "/rw list"))
+	if queue.Depth() != 4 {
+		t.Error("expected list to be open to everyone")
+	}
+}
+
+func TestHelpTriggerMustBeTheWholeMessage(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+	commandRouter, guildSettingsStore = router, router.settings
+	t.Cleanup(func() { commandRouter, guildSettingsStore = nil, nil })
+
+	//Changed with /settings helptrigger, then only answered when it's said on its own
+	h := newHandlerHarness(t, NewSettingsHandler(session, router.settings))
+	h.channel <- guildMessage("guild", "/settings helptrigger !dk")
+	h.Say("chan", "user", "")
+	assertContains(t, session.LastSent("chan"), "Help trigger: `!dk`")
+	session.Reset()
+
+	dispatchMessage(session, guildMessage("guild", "has anyone tried !dk yet?"))
+	if session.Count("send") != 0 || queue.Depth() != 0 {
+		t.Errorf("expected a mention in passing to be ignored, got %+v", session.Calls())
+	}
+
+	dispatchMessage(session, guildMessage("guild", "  !dk "))
+	assertContains(t, session.LastSent("chan"), "I currently support the following options")
+}
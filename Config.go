@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Configuration Struct used to store config info from json
+type Configuration struct {
+	Token         string                  `json:"Token"`
+	Name          string                  `json:"Name"`
+	Owners        []string                `json:"Owners"`
+	Queue         QueueConfiguration      `json:"Queue"`
+	SlashCommands bool                    `json:"SlashCommands"`
+	Storage       StorageConfiguration    `json:"Storage"`
+	Scheduler     SchedulerConfiguration  `json:"Scheduler"`
+	Shutdown      ShutdownConfiguration   `json:"Shutdown"`
+	Supervisor    SupervisorConfiguration `json:"Supervisor"`
+	Log           LogConfiguration        `json:"Log"`
+	Metrics       MetricsConfiguration    `json:"Metrics"`
+	Admin         AdminConfiguration      `json:"Admin"`
+	//Handlers decides which handlers run, by name, eg "Fortune": { "Enabled": true }
+	Handlers map[string]HandlerConfiguration `json:"Handlers"`
+	//TimeZone is the IANA time zone used for guilds which haven't set their own, defaulting to the server's
+	TimeZone string `json:"TimeZone"`
+	//DataDir is where saved data and the image reader directory live, defaulting to the working directory
+	DataDir string `json:"DataDir"`
+}
+
+const defaultConfigurationFile = "./diskhard.json"
+
+//configurationFile is where the configuration was read from, and is reloaded from
+var configurationFile = defaultConfigurationFile
+
+//configurationDefault is where to read the configuration when --config isn't given
+func configurationDefault() string {
+	if path := os.Getenv("DISKHARD_CONFIG"); path != "" {
+		return path
+	}
+
+	return defaultConfigurationFile
+}
+
+//dataDir is where handlers keep their data, see dataPath
+var dataDir = "."
+
+//environmentOverrides Are settings which can be set by environment variables, taking precedence over the file
+//Handy for keeping the token out of the file, eg when it's baked into a container
+var environmentOverrides = []struct {
+	name  string
+	apply func(configuration *Configuration, value string)
+}{
+	{"DISKHARD_TOKEN", func(configuration *Configuration, value string) { configuration.Token = value }},
+	{"DISKHARD_DATA_DIR", func(configuration *Configuration, value string) { configuration.DataDir = value }},
+	{"DISKHARD_LOG_LEVEL", func(configuration *Configuration, value string) { configuration.Log.Level = value }},
+	{"DISKHARD_ADMIN_TOKEN", func(configuration *Configuration, value string) { configuration.Admin.Token = value }},
+	{"DISKHARD_OWNERS", func(configuration *Configuration, value string) { configuration.Owners = strings.Split(value, ",") }},
+}
+
+//loadConfiguration reads the configuration file, then applies any environment overrides
+//Settings we don't know are errors, as they're most likely typos. The overrides are applied even if the file can't be read
+func loadConfiguration(path string) (configuration Configuration, err error) {
+	defer applyEnvironment(&configuration)
+
+	config, err := ioutil.ReadFile(path)
+	if err != nil {
+		return configuration, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&configuration); err != nil {
+		return configuration, fmt.Errorf("reading %s: %v", path, describeJSONError(config, err))
+	}
+
+	return configuration, nil
+}
+
+func applyEnvironment(configuration *Configuration) {
+	for _, override := range environmentOverrides {
+		if value, ok := os.LookupEnv(override.name); ok {
+			override.apply(configuration, value)
+		}
+	}
+}
+
+//validateConfiguration checks every setting makes sense, describing each problem found
+//Running the bot needs a token, checking it in console mode doesn't
+func validateConfiguration(configuration Configuration, requireToken bool) []error {
+	problems := make([]error, 0)
+	check := func(setting string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %v", setting, err))
+		}
+	}
+	duration := func(setting string, value string) {
+		if value != "" {
+			_, err := time.ParseDuration(value)
+			check(setting, err)
+		}
+	}
+	address := func(setting string, value string) {
+		if value != "" {
+			_, _, err := net.SplitHostPort(value)
+			check(setting, err)
+		}
+	}
+
+	if requireToken && configuration.Token == "" {
+		check("Token", fmt.Errorf("the bot's token is required, set it in the file or DISKHARD_TOKEN"))
+	}
+
+	switch OverflowPolicy(configuration.Queue.Policy) {
+	case "", DropOldest, DropNewest, BlockWithTimeout:
+	default:
+		check("Queue.Policy", fmt.Errorf("unknown policy %s, expected %s, %s or %s", configuration.Queue.Policy, DropOldest, DropNewest, BlockWithTimeout))
+	}
+	if configuration.Queue.Size < 0 {
+		check("Queue.Size", fmt.Errorf("can't be negative"))
+	}
+	duration("Queue.Timeout", configuration.Queue.Timeout)
+	duration("Storage.BackupEvery", configuration.Storage.BackupEvery)
+
+	switch configuration.Storage.Backend {
+	case "", "json", "bolt":
+	default:
+		check("Storage.Backend", fmt.Errorf("unknown backend %s, expected json or bolt", configuration.Storage.Backend))
+	}
+
+	switch CatchUpPolicy(configuration.Scheduler.CatchUp) {
+	case "", CatchUpLate, CatchUpSummary, CatchUpSkip:
+	default:
+		check("Scheduler.CatchUp", fmt.Errorf("unknown policy %s, expected %s, %s or %s", configuration.Scheduler.CatchUp, CatchUpLate, CatchUpSummary, CatchUpSkip))
+	}
+	duration("Scheduler.GraceWindow", configuration.Scheduler.GraceWindow)
+	duration("Shutdown.Timeout", configuration.Shutdown.Timeout)
+	duration("Supervisor.Backoff", configuration.Supervisor.Backoff)
+	if configuration.Supervisor.MaxRestarts < 0 {
+		check("Supervisor.MaxRestarts", fmt.Errorf("can't be negative"))
+	}
+
+	_, _, err := parseLogConfiguration(configuration.Log)
+	check("Log", err)
+	address("Metrics.Listen", configuration.Metrics.Listen)
+	address("Admin.Listen", configuration.Admin.Listen)
+	if configuration.Admin.Listen != "" && configuration.Admin.Token == "" {
+		check("Admin.Token", fmt.Errorf("is required to serve the admin API, set it in the file or DISKHARD_ADMIN_TOKEN"))
+	}
+
+	_, err = configuredLocation(configuration.TimeZone)
+	check("TimeZone", err)
+
+	if info, err := os.Stat(dataDirectory(configuration)); err == nil && !info.IsDir() {
+		check("DataDir", fmt.Errorf("%s is not a directory", configuration.DataDir))
+	}
+
+	return problems
+}
+
+//configuredLocation loads the default time zone, which is the server's when unset or invalid
+func configuredLocation(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Local, err
+	}
+
+	return location, nil
+}
+
+func dataDirectory(configuration Configuration) string {
+	if configuration.DataDir == "" {
+		return "."
+	}
+
+	return configuration.DataDir
+}
+
+//dataPath resolves a path inside the data directory. Absolute paths are left alone
+func dataPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dataDir, path)
+}
+
+//readOnlyStore Loads documents without ever saving them, so checking data doesn't upgrade it behind the bot's back
+type readOnlyStore struct {
+	Store
+}
+
+//Save Nothing to do here
+func (ro readOnlyStore) Save(name string, v interface{}) error {
+	return nil
+}
+
+//LoadStrict passes through to the underlying store, so damaged documents are reported rather than recovered
+func (ro readOnlyStore) LoadStrict(name string, v interface{}) error {
+	return loadStrict(ro.Store, name, v)
+}
+
+//checkDocument loads the document as its handler would, and validates it where the handler can
+func checkDocument(store Store, name string) error {
+	switch name {
+	case releaseDocument:
+		var data []channelReleaseData
+		if err := loadStrict(store, name, &data); err != nil {
+			return err
+		}
+		return validateReleases(data)
+	case reminderDocument:
+		var data []channelReminderData
+		if err := loadStrict(store, name, &data); err != nil {
+			return err
+		}
+		return validateReminders(data)
+	case imageDocument:
+		var data []*channelImageData
+		if err := loadStrict(store, name, &data); err != nil {
+			return err
+		}
+		return validateImageBlocks(data)
+	case reactionDocument:
+		var data []reactionData
+		if err := loadStrict(store, name, &data); err != nil {
+			return err
+		}
+		_, err := compileReactionRules(data)
+		return err
+	case fortuneDocument:
+		var data []string
+		return loadStrict(store, name, &data)
+	case guildSettingsDocument:
+		var data []*guildSettings
+		return loadStrict(store, name, &data)
+	case userSettingsDocument:
+		var data []*userSettings
+		return loadStrict(store, name, &data)
+	case permissionsDocument:
+		var data []*guildPermissions
+		return loadStrict(store, name, &data)
+	case schedulerDocument:
+		var data map[string]time.Time
+		return loadStrict(store, name, &data)
+	case handlerTogglesDocument:
+		var data []*handlerToggles
+		return loadStrict(store, name, &data)
+	}
+
+	var data interface{}
+	return loadStrict(store, name, &data)
+}
+
+//checkConfiguration validates the configuration file and every data file, without connecting to discord
+//Writes a line per problem, or file that's fine, and returns whether everything was
+func checkConfiguration(path string, out io.Writer) bool {
+	configuration, err := loadConfiguration(path)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	problems := validateConfiguration(configuration, true)
+	for _, problem := range problems {
+		fmt.Fprintf(out, "%s: %v\n", path, problem)
+	}
+	if len(problems) > 0 {
+		return false
+	}
+	fmt.Fprintf(out, "%s: ok\n", path)
+
+	dataDir = dataDirectory(configuration)
+	backend, err := OpenStore(configuration.Storage)
+	if err != nil {
+		fmt.Fprintf(out, "storage: %v\n", err)
+		return false
+	}
+	defer backend.Close()
+
+	valid := true
+	store := NewVersionedStore(readOnlyStore{backend}, documentMigrations)
+	for _, name := range migratedDocuments {
+		switch err := checkDocument(store, name); err {
+		case nil:
+			fmt.Fprintf(out, "%s: ok\n", name)
+		case ErrNotFound:
+			fmt.Fprintf(out, "%s: not saved yet\n", name)
+		default:
+			fmt.Fprintf(out, "%s: %v\n", name, err)
+			valid = false
+		}
+	}
+
+	return valid
+}
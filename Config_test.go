@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfigurationRejectsUnknownSettings(t *testing.T) {
+	useTempDir(t)
+	writeTestFile(t, "diskhard.json", "{\n\"Token\": \"abc\",\n\"TimeZome\": \"UTC\"\n}")
+
+	_, err := loadConfiguration("diskhard.json")
+	if err == nil {
+		t.Fatal("expected the misspelt setting to be an error")
+	}
+	assertContains(t, err.Error(), "TimeZome")
+}
+
+func TestEnvironmentOverridesConfiguration(t *testing.T) {
+	useTempDir(t)
+	writeTestFile(t, "diskhard.json", `{"Token": "abc", "DataDir": "data"}`)
+	os.Setenv("DISKHARD_TOKEN", "def")
+	t.Cleanup(func() { os.Unsetenv("DISKHARD_TOKEN") })
+
+	configuration, err := loadConfiguration("diskhard.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configuration.Token != "def" || configuration.DataDir != "data" {
+		t.Errorf("expected the token to be overridden and the data directory kept, got %+v", configuration)
+	}
+
+	//Missing files still pick up the environment, so the bot can run from it alone
+	if configuration, err = loadConfiguration("missing.json"); !os.IsNotExist(err) || configuration.Token != "def" {
+		t.Errorf("expected a missing file with the token from the environment, got %v %+v", err, configuration)
+	}
+}
+
+func TestValidationNamesEachBadSetting(t *testing.T) {
+	useTempDir(t)
+	writeTestFile(t, "notadir", "")
+	configuration := Configuration{
+		Queue:    QueueConfiguration{Policy: "drop-everything"},
+		Shutdown: ShutdownConfiguration{Timeout: "soon"},
+		Admin:    AdminConfiguration{Listen: "localhost"},
+		TimeZone: "Nowhere/Special",
+		DataDir:  "notadir",
+	}
+
+	problems := validateConfiguration(configuration, true)
+	described := make([]string, 0)
+	for _, problem := range problems {
+		described = append(described, problem.Error())
+	}
+	all := strings.Join(described, "\n")
+	for _, setting := range []string{"Token:", "Queue.Policy:", "Shutdown.Timeout:", "Admin.Listen:", "Admin.Token:", "TimeZone:", "DataDir:"} {
+		assertContains(t, all, setting)
+	}
+
+	if problems = validateConfiguration(Configuration{}, false); len(problems) != 0 {
+		t.Errorf("expected the defaults to be valid without a token, got %v", problems)
+	}
+}
+
+func TestConfigCheckReportsBrokenData(t *testing.T) {
+	useTempDir(t)
+	os.Mkdir("data", 0755)
+	t.Cleanup(func() { dataDir = "." })
+	writeTestFile(t, "diskhard.json", `{"Token": "abc", "DataDir": "data"}`)
+	writeTestFile(t, "data/"+reminderDocument+".json", `[{"channelID": "chan", "reminders": [{"name": "Standup", "hour": 29, "minute": 0, "days": [1]}]}]`)
+	writeTestFile(t, "data/"+fortuneDocument+".json", `["Fortune favours the bold"]`)
+
+	var out bytes.Buffer
+	if checkConfiguration("diskhard.json", &out) {
+		t.Fatalf("expected the invalid reminder to fail the check, got\n%s", out.String())
+	}
+	assertContains(t, out.String(), "diskhard.json: ok")
+	assertContains(t, out.String(), reminderDocument+": reminder 0 in channel chan")
+	assertContains(t, out.String(), fortuneDocument+": ok")
+	assertContains(t, out.String(), releaseDocument+": not saved yet")
+
+	//Checking shouldn't upgrade or back anything up
+	files, _ := ioutil.ReadDir("data")
+	if len(files) != 2 {
+		t.Errorf("expected the data directory to be left alone, got %d files", len(files))
+	}
+	data, _ := ioutil.ReadFile("data/" + fortuneDocument + ".json")
+	if string(data) != `["Fortune favours the bold"]` {
+		t.Errorf("expected the fortunes not to be rewritten, got %s", data)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//ConsoleSession Prints everything handlers would have sent to discord
+type ConsoleSession struct {
+	out    io.Writer
+	mutex  sync.Mutex
+	nextID int
+	//guilds remembers which simulated guild each channel was last talked in
+	guilds map[string]string
+}
+
+//NewConsoleSession creates a session which writes to the provided output
+func NewConsoleSession(out io.Writer) *ConsoleSession {
+	return &ConsoleSession{out: out, guilds: make(map[string]string)}
+}
+
+func (cs *ConsoleSession) print(format string, args ...interface{}) {
+	cs.mutex.Lock()
+	fmt.Fprintf(cs.out, format+"\n", args...)
+	cs.mutex.Unlock()
+}
+
+func (cs *ConsoleSession) newID() string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.nextID++
+	return "bot-" + strconv.Itoa(cs.nextID)
+}
+
+func (cs *ConsoleSession) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	id := cs.newID()
+	cs.print("[#%s] bot (%s): %s", channelID, id, message)
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: message}, nil
+}
+
+func (cs *ConsoleSession) SendFile(channelID string, filePath string) error {
+	cs.print("[#%s] bot (%s) uploaded file: %s", channelID, cs.newID(), filePath)
+	return nil
+}
+
+func (cs *ConsoleSession) SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	id := cs.newID()
+	cs.print("[#%s] bot (%s): %s", channelID, id, embedText(message))
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: message.Content}, nil
+}
+
+func (cs *ConsoleSession) EditMessage(channelID string, messageID string, newMessage string) error {
+	cs.print("[#%s] bot edited %s: %s", channelID, messageID, newMessage)
+	return nil
+}
+
+func (cs *ConsoleSession) DeleteMessage(channelID string, messageID string) error {
+	cs.print("[#%s] (deleted %s)", channelID, messageID)
+	return nil
+}
+
+func (cs *ConsoleSession) PinMessage(channelID string, messageID string) error {
+	cs.print("[#%s] (pinned %s)", channelID, messageID)
+	return nil
+}
+
+func (cs *ConsoleSession) React(channelID string, messageID string, reaction string) error {
+	cs.print("[#%s] (reacted %s to %s)", channelID, reaction, messageID)
+	return nil
+}
+
+func (cs *ConsoleSession) UserGuilds() ([]*discordgo.UserGuild, error) {
+	return []*discordgo.UserGuild{{ID: "console", Name: "Console"}}, nil
+}
+
+func (cs *ConsoleSession) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	return []*discordgo.Emoji{}, nil
+}
+
+//UserChannelPermissions Whoever is at the console is in charge
+func (cs *ConsoleSession) UserChannelPermissions(userID string, channelID string) (int64, error) {
+	return discordgo.PermissionAll, nil
+}
+
+//ChannelGuild Channels are in whichever guild they were last talked in, or the console's own
+func (cs *ConsoleSession) ChannelGuild(channelID string) (string, error) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if guildID, ok := cs.guilds[channelID]; ok {
+		return guildID, nil
+	}
+
+	return "console", nil
+}
+
+//runConsole feeds lines read from input into the handlers as messages from a simulated user and channel
+//Lines starting with : are console commands rather than messages
+func runConsole(sender *ConsoleSession, in io.Reader) {
+	channelID := "general"
+	guildID := "console"
+	userID := "user"
+	messageCount := 0
+
+	sender.print("Console mode: type messages as %s in #%s, or :help for console commands", userID, channelID)
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, ":") {
+			fields := strings.Fields(line[1:])
+			if len(fields) == 0 {
+				continue
+			}
+
+			switch {
+			case fields[0] == "channel" && len(fields) == 2:
+				channelID = fields[1]
+				sender.print("Now talking in #%s", channelID)
+			case fields[0] == "user" && len(fields) == 2:
+				userID = fields[1]
+				sender.print("Now talking as %s", userID)
+			case fields[0] == "guild" && len(fields) == 2:
+				guildID = fields[1]
+				sender.print("Now talking in guild %s", guildID)
+			case fields[0] == "quit":
+				return
+			default:
+				sender.print(":channel <id> - switch the simulated channel\n" +
+					":user <id> - switch the simulated user\n" +
+					":guild <id> - switch the simulated guild\n" +
+					":quit - exit")
+			}
+			continue
+		}
+
+		messageCount++
+		sender.mutex.Lock()
+		sender.guilds[channelID] = guildID
+		sender.mutex.Unlock()
+		dispatchMessage(sender, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID:        "console-" + strconv.Itoa(messageCount),
+			ChannelID: channelID,
+			GuildID:   guildID,
+			Content:   line,
+			Author:    &discordgo.User{ID: userID, Username: userID},
+		}})
+	}
+}
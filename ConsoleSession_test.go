@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+//useTestConsole runs a release handler behind a router, the way console mode does
+func useTestConsole(t *testing.T) (*ConsoleSession, *bytes.Buffer, *HandlerQueue) {
+	useTempDir(t)
+	out := &bytes.Buffer{}
+	session := NewConsoleSession(out)
+	handler := NewReleaseHandler(session, NewJSONFileStore("."))
+	queue := useTestHandlers(t, session, handler)
+
+	router := NewCommandRouter(session, NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC), NewPermissionStore(session, NewJSONFileStore("."), nil))
+	if err := router.Register(handler, queue); err != nil {
+		t.Fatal(err)
+	}
+	commandRouter, guildSettingsStore = router, router.settings
+	t.Cleanup(func() { commandRouter, guildSettingsStore = nil, nil })
+
+	return session, out, queue
+}
+
+func TestConsoleDispatchesLinesAsMessages(t *testing.T) {
+	session, out, _ := useTestConsole(t)
+
+	input := ":user alice\n:channel releases\n:guild home\n" +
+		"/rw add " + daysAhead(30).Format("01/02/06") + " Persona 8\n" +
+		":\n:bogus\n/rw list\n"
+	runConsole(session, strings.NewReader(input))
+
+	//Input ran out, so shut down as main does, which handles whatever is still queued
+	stopHandlers(session, ShutdownConfiguration{}, func() {})
+
+	printed := out.String()
+	assertContains(t, printed, "Now talking as alice")
+	assertContains(t, printed, "Now talking in #releases")
+	assertContains(t, printed, "Now talking in guild home")
+	assertContains(t, printed, ":quit - exit")
+	assertContains(t, printed, "[#releases] bot (bot-2): Added Persona 8 to releases")
+	assertContains(t, printed, "[#releases] (deleted console-1)")
+	assertContains(t, printed, "[0] Persona 8")
+
+	var data []channelReleaseData
+	if err := NewJSONFileStore(".").Load(releaseDocument, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].ChannelID != "releases" || data[0].GuildID != "home" {
+		t.Errorf("expected the release to be saved for #releases in home, got %+v", data)
+	}
+}
+
+func TestConsoleQuitStopsReading(t *testing.T) {
+	session, out, queue := useTestConsole(t)
+
+	runConsole(session, strings.NewReader(":quit\n/rw list\n"))
+	if queue.Depth() != 0 || strings.Contains(out.String(), "Tracked releases") {
+		t.Errorf("expected nothing after :quit to be dispatched, got %q", out.String())
+	}
+}
+
+func TestConsoleChannelGuild(t *testing.T) {
+	session, _, _ := useTestConsole(t)
+
+	runConsole(session, strings.NewReader(":channel a\n:guild first\nhello\n:channel b\nhello\n:guild second\n"))
+
+	for channelID, expected := range map[string]string{"a": "first", "b": "first", "never": "console"} {
+		if guildID, err := session.ChannelGuild(channelID); err != nil || guildID != expected {
+			t.Errorf("expected #%s to be in %s, got %s %v", channelID, expected, guildID, err)
+		}
+	}
+}
@@ -1,29 +1,21 @@
 package main
 
 import (
-	"fmt"
+	"context"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 //EchoHandler Echoes messages to stdout
 type EchoHandler struct {
+	handlerLoop
 }
 
 //Init Spins up our channel handling
-func (eh *EchoHandler) Init(m chan *discordgo.MessageCreate) {
-	go func() {
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					fmt.Printf("Message: %s\n", message.Content)
-				} else {
-					return
-				}
-			}
-		}
-	}()
+func (eh *EchoHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	eh.listen(ctx, eh.GetName(), m, func(message *discordgo.MessageCreate) {
+		eh.log.Info("Message", "content", message.Content)
+	})
 }
 
 //GetName returns name of handler
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//embedColor is the stripe down the side of our embeds
+const embedColor = 0x5865F2
+
+//Discord's limits on the parts of an embed
+const maxEmbedFieldName = 256
+const maxEmbedFieldValue = 1024
+
+//newEmbed starts an embed in our colors, stamped with the current time
+func newEmbed(title string) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:     title,
+		Color:     embedColor,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+//embedField builds a field, cutting the name and value down to what discord accepts
+func embedField(name string, value string, inline bool) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:   truncate(name, maxEmbedFieldName),
+		Value:  truncate(value, maxEmbedFieldValue),
+		Inline: inline,
+	}
+}
+
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	return string([]rune(text)[:limit-1]) + "…"
+}
+
+//embedText renders a rich message as plain text, for places without embeds like the console
+func embedText(message *discordgo.MessageSend) string {
+	lines := make([]string, 0)
+	if message.Content != "" {
+		lines = append(lines, message.Content)
+	}
+
+	for _, embed := range message.Embeds {
+		if embed.Thumbnail != nil {
+			lines = append(lines, "("+embed.Thumbnail.URL+")")
+		}
+		if embed.Title != "" {
+			lines = append(lines, "**"+embed.Title+"**")
+		}
+		if embed.Description != "" {
+			lines = append(lines, embed.Description)
+		}
+		for _, field := range embed.Fields {
+			lines = append(lines, field.Name+": "+field.Value)
+		}
+		if embed.Footer != nil {
+			lines = append(lines, embed.Footer.Text)
+		}
+	}
+
+	for _, row := range message.Components {
+		if actions, ok := row.(discordgo.ActionsRow); ok {
+			controls := make([]string, 0)
+			for _, component := range actions.Components {
+				switch control := component.(type) {
+				case discordgo.Button:
+					controls = append(controls, "["+control.Label+"]")
+				case discordgo.SelectMenu:
+					controls = append(controls, "["+control.Placeholder+" v]")
+				}
+			}
+			lines = append(lines, strings.Join(controls, " "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
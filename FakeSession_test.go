@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//fakeCall Records a single action performed against the fake session
+type fakeCall struct {
+	Action    string
+	ChannelID string
+	MessageID string
+	Content   string
+	//Complex is the message with embeds or components, for sends which had them
+	Complex *discordgo.MessageSend
+}
+
+//FakeSession An in-memory Session which records everything handlers ask of it
+type FakeSession struct {
+	mutex  sync.Mutex
+	calls  []fakeCall
+	nextID int
+	guilds []*discordgo.UserGuild
+	emojis map[string][]*discordgo.Emoji
+	//permissions by user ID, anyone else has none
+	permissions map[string]int64
+	//channelGuilds by channel ID, other channels are direct messages
+	channelGuilds map[string]string
+}
+
+func (fs *FakeSession) record(call fakeCall) {
+	fs.mutex.Lock()
+	fs.calls = append(fs.calls, call)
+	fs.mutex.Unlock()
+}
+
+func (fs *FakeSession) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	fs.mutex.Lock()
+	fs.nextID++
+	id := "sent" + strconv.Itoa(fs.nextID)
+	fs.mutex.Unlock()
+
+	fs.record(fakeCall{Action: "send", ChannelID: channelID, MessageID: id, Content: message})
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: message}, nil
+}
+
+func (fs *FakeSession) SendFile(channelID string, filePath string) error {
+	fs.record(fakeCall{Action: "file", ChannelID: channelID, Content: filePath})
+	return nil
+}
+
+//SendComplex records the message as a send, with its text rendered the same way the console does
+func (fs *FakeSession) SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	fs.mutex.Lock()
+	fs.nextID++
+	id := "sent" + strconv.Itoa(fs.nextID)
+	fs.mutex.Unlock()
+
+	fs.record(fakeCall{Action: "send", ChannelID: channelID, MessageID: id, Content: embedText(message), Complex: message})
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: message.Content}, nil
+}
+
+func (fs *FakeSession) EditMessage(channelID string, messageID string, newMessage string) error {
+	fs.record(fakeCall{Action: "edit", ChannelID: channelID, MessageID: messageID, Content: newMessage})
+	return nil
+}
+
+func (fs *FakeSession) DeleteMessage(channelID string, messageID string) error {
+	fs.record(fakeCall{Action: "delete", ChannelID: channelID, MessageID: messageID})
+	return nil
+}
+
+func (fs *FakeSession) PinMessage(channelID string, messageID string) error {
+	fs.record(fakeCall{Action: "pin", ChannelID: channelID, MessageID: messageID})
+	return nil
+}
+
+func (fs *FakeSession) React(channelID string, messageID string, reaction string) error {
+	fs.record(fakeCall{Action: "react", ChannelID: channelID, MessageID: messageID, Content: reaction})
+	return nil
+}
+
+func (fs *FakeSession) UserGuilds() ([]*discordgo.UserGuild, error) {
+	return fs.guilds, nil
+}
+
+func (fs *FakeSession) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	return fs.emojis[guildID], nil
+}
+
+func (fs *FakeSession) UserChannelPermissions(userID string, channelID string) (int64, error) {
+	return fs.permissions[userID], nil
+}
+
+func (fs *FakeSession) ChannelGuild(channelID string) (string, error) {
+	return fs.channelGuilds[channelID], nil
+}
+
+//Calls returns a copy of everything recorded so far
+func (fs *FakeSession) Calls() []fakeCall {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return append([]fakeCall(nil), fs.calls...)
+}
+
+//Reset forgets all recorded calls
+func (fs *FakeSession) Reset() {
+	fs.mutex.Lock()
+	fs.calls = nil
+	fs.mutex.Unlock()
+}
+
+//Sent returns the content of every message sent to the channel
+func (fs *FakeSession) Sent(channelID string) []string {
+	sent := make([]string, 0)
+	for _, call := range fs.Calls() {
+		if call.Action == "send" && call.ChannelID == channelID {
+			sent = append(sent, call.Content)
+		}
+	}
+	return sent
+}
+
+//LastSent returns the most recent message sent to the channel
+func (fs *FakeSession) LastSent(channelID string) string {
+	sent := fs.Sent(channelID)
+	if len(sent) == 0 {
+		return ""
+	}
+	return sent[len(sent)-1]
+}
+
+//Count returns how many times the action was performed
+func (fs *FakeSession) Count(action string) int {
+	count := 0
+	for _, call := range fs.Calls() {
+		if call.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+//handlerHarness drives messages through a handler's channel, the same way the router does
+type handlerHarness struct {
+	t       *testing.T
+	channel chan *discordgo.MessageCreate
+	nextID  int
+}
+
+func newHandlerHarness(t *testing.T, handler MessageHandler) *handlerHarness {
+	channel := make(chan *discordgo.MessageCreate)
+	handler.Init(context.Background(), channel)
+	h := &handlerHarness{t: t, channel: channel}
+	t.Cleanup(func() {
+		channel <- nil
+		handler.Stop()
+	})
+	return h
+}
+
+//Say sends the message as the user, returning once the handler has finished processing it
+func (h *handlerHarness) Say(channelID string, userID string, content string) {
+	h.nextID++
+	h.channel <- &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "in" + strconv.Itoa(h.nextID),
+		ChannelID: channelID,
+		Content:   content,
+		Author:    &discordgo.User{ID: userID},
+	}}
+
+	//Channels are unbuffered, so once this is accepted the previous message has been fully handled
+	h.channel <- &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: channelID, Author: &discordgo.User{ID: userID}}}
+}
+
+//useTempDir runs the test from an empty directory, as handlers persist data to the working directory
+func useTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "diskhard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(previous)
+		os.RemoveAll(dir)
+	})
+
+	return dir
+}
+
+func assertContains(t *testing.T, haystack string, needle string) {
+	t.Helper()
+	if !strings.Contains(haystack, needle) {
+		t.Errorf("expected %q to contain %q", haystack, needle)
+	}
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"context"
 	"os/exec"
 	"regexp"
 	"time"
@@ -13,57 +11,57 @@ import (
 
 //FortuneHandler Echoes messages to stdout
 type FortuneHandler struct {
+	handlerLoop
+	session      Session
+	store        Store
 	active       bool
 	channelIDs   []string
 	fortuneRegex *regexp.Regexp
 }
 
-const fortuneFile = "./fortuneData.json"
+//NewFortuneHandler creates a handler which posts through the provided session, reading channels from the store
+func NewFortuneHandler(session Session, store Store) *FortuneHandler {
+	return &FortuneHandler{session: session, store: store}
+}
+
+const fortuneCommand = "/fortune"
 
 //Init Nothing to do here
-func (fh *FortuneHandler) Init(m chan *discordgo.MessageCreate) {
+func (fh *FortuneHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
 	//If fortune's usable...
 	_, err := exec.Command("fortune").Output()
 	if err != nil {
-		fmt.Println("fortune is not accessible; disabling.", err)
+		fh.log.Warn("fortune is not accessible, disabling", "error", err)
 	} else {
 		var chans []string
 
-		fileData, err := ioutil.ReadFile(fortuneFile)
-
 		//Load up in-memory cache of this info
-		if err == nil {
-			fmt.Println("Reading saved release data")
-			err = json.Unmarshal(fileData, &chans)
-			if err != nil {
-				fmt.Println("Error unmarshalling json", err)
-			}
+		if loadErr := fh.store.Load(fortuneDocument, &chans); loadErr == nil {
+			fh.log.Info("Reading saved fortune data")
 			fh.channelIDs = chans
+		} else if loadErr != ErrNotFound {
+			fh.log.Error("Error loading fortune data", "error", loadErr)
 		}
 
 		//Set up our regexp
-		fh.fortuneRegex = regexp.MustCompile(`^/fortune$`)
+		fh.fortuneRegex = regexp.MustCompile(`^` + fortuneCommand + `$`)
 	}
 
 	fh.active = (err == nil)
 
-	go func() {
-		minuteSchedule := time.NewTicker(time.Minute)
-		defer minuteSchedule.Stop()
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					fh.handleMessage(message)
-				} else {
-					return
-				}
-			case <-minuteSchedule.C:
-				fh.scheduledTask()
-			}
-		}
-	}()
+	fh.listen(ctx, fh.GetName(), m, fh.handleMessage)
+
+}
+
+//Reload re-reads the channels we post fortunes to, keeping the ones we have if that fails
+func (fh *FortuneHandler) Reload() (string, error) {
+	var chans []string
+	if err := loadStrict(fh.store, fortuneDocument, &chans); err != nil && err != ErrNotFound {
+		return fortuneDocument, err
+	}
 
+	fh.channelIDs = chans
+	return fortuneDocument, nil
 }
 
 //GetName returns name of handler
@@ -77,24 +75,37 @@ func (fh *FortuneHandler) handleMessage(m *discordgo.MessageCreate) {
 		if fh.fortuneRegex.MatchString(m.Content) {
 			channelID := make([]string, 1)
 			channelID[0] = m.ChannelID
-			fh.generateFortune(channelID)
+			fh.generateFortune(fh.session, channelID)
 		}
 	}
 }
 
-//ScheduledTask enmpty func to comply with interface reqs
-func (fh *FortuneHandler) scheduledTask() {
-	if fh.active {
-		current := time.Now()
+//Jobs At 9, let's fortune! Checked every minute, as it's 9 in each channel's guild's time zone
+func (fh *FortuneHandler) Jobs() []Job {
+	return []Job{{Name: "daily fortune", Schedule: Every(time.Minute), Run: fh.scheduledTask, Queue: fh.tasks}}
+}
+
+func (fh *FortuneHandler) scheduledTask(run JobRun) {
+	if !fh.active {
+		return
+	}
 
-		//At 9, let's fortune!
-		if current.Hour() == 9 && current.Minute() == 0 {
-			fh.generateFortune(fh.channelIDs)
+	channelIDs := make([]string, 0, len(fh.channelIDs))
+	for _, channelID := range fh.channelIDs {
+		guildID := channelGuild(fh.session, channelID)
+		if !dueAt(run.Occurrence, userLocation(guildID, ""), 9, 0).Equal(run.Occurrence) {
+			continue
 		}
+		if handlerActive(fh.GetName(), guildID, channelID) {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+	if len(channelIDs) > 0 {
+		fh.generateFortune(run.Session, channelIDs)
 	}
 }
 
-func (fh *FortuneHandler) generateFortune(channelIDs []string) {
+func (fh *FortuneHandler) generateFortune(session Session, channelIDs []string) {
 	command := "fortune"
 
 	//Special frogtime for wednesday?
@@ -104,11 +115,16 @@ func (fh *FortuneHandler) generateFortune(channelIDs []string) {
 	output := string(out)
 	if err == nil {
 		for _, channelID := range channelIDs {
-			MessageSender.SendMessage(channelID, output)
+			session.SendMessage(channelID, output)
 		}
 	}
 }
 
+//Commands returns the commands owned by this handler
+func (fh *FortuneHandler) Commands() []Command {
+	return []Command{{Prefix: fortuneCommand, Description: "Tell a fortune"}}
+}
+
 //Help Gets info about this release handler
 func (fh *FortuneHandler) Help() string {
 	return "(Fortune Handler Active)"
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//commandPrefix is the prefix handlers see on their commands, whatever the guild actually uses
+const commandPrefix = "/"
+const maxPrefixLength = 10
+
+var channelMentionMatcher = regexp.MustCompile(`^<#(\d+)>$`)
+
+type guildSettings struct {
+	GuildID     string `json:"guildID"`
+	Prefix      string `json:"prefix,omitempty"`
+	HelpTrigger string `json:"helpTrigger,omitempty"`
+	TimeZone    string `json:"timeZone,omitempty"`
+	//ModLog is the channel audit entries are mirrored to
+	ModLog string `json:"modLog,omitempty"`
+}
+
+//userSettings Are a user's own settings, which follow them between guilds
+type userSettings struct {
+	UserID   string `json:"userID"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+//GuildSettingsStore Holds per-guild and per-user settings, shared between the router and handlers
+type GuildSettingsStore struct {
+	mutex              sync.RWMutex
+	store              Store
+	defaultHelpTrigger string
+	defaultLocation    *time.Location
+	settings           map[string]*guildSettings
+	users              map[string]*userSettings
+}
+
+//NewGuildSettingsStore loads saved guild and user settings. Guilds without a help trigger or time zone use the provided defaults
+func NewGuildSettingsStore(store Store, defaultHelpTrigger string, defaultLocation *time.Location) *GuildSettingsStore {
+	gs := &GuildSettingsStore{
+		store:              store,
+		defaultHelpTrigger: defaultHelpTrigger,
+		defaultLocation:    defaultLocation,
+		settings:           make(map[string]*guildSettings),
+		users:              make(map[string]*userSettings),
+	}
+
+	var data []*guildSettings
+	err := store.Load(guildSettingsDocument, &data)
+	if err == nil {
+		logger.Info("Reading saved guild settings")
+		for _, guild := range data {
+			gs.settings[guild.GuildID] = guild
+		}
+	} else if err != ErrNotFound {
+		logger.Error("Error loading guild settings", "error", err)
+	}
+
+	var users []*userSettings
+	err = store.Load(userSettingsDocument, &users)
+	if err == nil {
+		for _, user := range users {
+			gs.users[user.UserID] = user
+		}
+	} else if err != ErrNotFound {
+		logger.Error("Error loading user settings", "error", err)
+	}
+
+	return gs
+}
+
+//Prefix returns the command prefix used in the guild
+func (gs *GuildSettingsStore) Prefix(guildID string) string {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if guild, ok := gs.settings[guildID]; ok && guild.Prefix != "" {
+		return guild.Prefix
+	}
+
+	return commandPrefix
+}
+
+//HelpTrigger returns the text which makes us list our handlers in the guild
+func (gs *GuildSettingsStore) HelpTrigger(guildID string) string {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if guild, ok := gs.settings[guildID]; ok && guild.HelpTrigger != "" {
+		return guild.HelpTrigger
+	}
+
+	return gs.defaultHelpTrigger
+}
+
+//SetDefaults changes the help trigger and time zone used by guilds which haven't set their own
+func (gs *GuildSettingsStore) SetDefaults(helpTrigger string, location *time.Location) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	gs.defaultHelpTrigger = helpTrigger
+	gs.defaultLocation = location
+}
+
+//SetPrefix changes the guild's command prefix
+func (gs *GuildSettingsStore) SetPrefix(guildID string, prefix string) error {
+	if err := validateTrigger(prefix); err != nil {
+		return err
+	}
+
+	gs.mutex.Lock()
+	gs.guild(guildID).Prefix = prefix
+	gs.mutex.Unlock()
+
+	return gs.writeData()
+}
+
+//SetHelpTrigger changes the text which makes us list our handlers in the guild
+func (gs *GuildSettingsStore) SetHelpTrigger(guildID string, trigger string) error {
+	if err := validateTrigger(trigger); err != nil {
+		return err
+	}
+
+	gs.mutex.Lock()
+	gs.guild(guildID).HelpTrigger = trigger
+	gs.mutex.Unlock()
+
+	return gs.writeData()
+}
+
+//ModLog returns the channel the guild's audit entries are mirrored to, if it has one
+func (gs *GuildSettingsStore) ModLog(guildID string) string {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if guild, ok := gs.settings[guildID]; ok {
+		return guild.ModLog
+	}
+
+	return ""
+}
+
+//SetModLog changes the channel the guild's audit entries are mirrored to, eg <#123>. off stops mirroring them
+func (gs *GuildSettingsStore) SetModLog(guildID string, channel string) error {
+	channelID := ""
+	if channel != "off" && channel != "" {
+		match := channelMentionMatcher.FindStringSubmatch(channel)
+		if match == nil {
+			return errors.New("it needs to be a channel, eg #mod-log, or off")
+		}
+		channelID = match[1]
+	}
+
+	gs.mutex.Lock()
+	gs.guild(guildID).ModLog = channelID
+	gs.mutex.Unlock()
+
+	return gs.writeData()
+}
+
+//TimeZone returns the guild's time zone, or the bot's default if the guild hasn't set one
+func (gs *GuildSettingsStore) TimeZone(guildID string) *time.Location {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if guild, ok := gs.settings[guildID]; ok && guild.TimeZone != "" {
+		return itemLocation(guild.TimeZone, gs.defaultLocation)
+	}
+
+	return gs.defaultLocation
+}
+
+//UserTimeZone returns the time zone the user has chosen, or nil if they haven't
+func (gs *GuildSettingsStore) UserTimeZone(userID string) *time.Location {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if user, ok := gs.users[userID]; ok && user.TimeZone != "" {
+		return itemLocation(user.TimeZone, nil)
+	}
+
+	return nil
+}
+
+//Location returns the user's time zone, falling back to the guild's
+func (gs *GuildSettingsStore) Location(guildID string, userID string) *time.Location {
+	if location := gs.UserTimeZone(userID); location != nil {
+		return location
+	}
+
+	return gs.TimeZone(guildID)
+}
+
+//SetTimeZone changes the guild's time zone. default goes back to the bot's
+func (gs *GuildSettingsStore) SetTimeZone(guildID string, zone string) error {
+	name, err := validateTimeZone(zone)
+	if err != nil {
+		return err
+	}
+
+	gs.mutex.Lock()
+	gs.guild(guildID).TimeZone = name
+	gs.mutex.Unlock()
+
+	return gs.writeData()
+}
+
+//SetUserTimeZone changes the user's own time zone. default goes back to using the guild's
+func (gs *GuildSettingsStore) SetUserTimeZone(userID string, zone string) error {
+	name, err := validateTimeZone(zone)
+	if err != nil {
+		return err
+	}
+
+	gs.mutex.Lock()
+	user, ok := gs.users[userID]
+	if !ok {
+		user = &userSettings{UserID: userID}
+		gs.users[userID] = user
+	}
+	user.TimeZone = name
+	data := make([]*userSettings, 0, len(gs.users))
+	for _, user := range gs.users {
+		data = append(data, user)
+	}
+	defer gs.mutex.Unlock()
+
+	return gs.store.Save(userSettingsDocument, data)
+}
+
+//guild returns the settings for the guild, creating them if needed. Callers must hold the write lock
+func (gs *GuildSettingsStore) guild(guildID string) *guildSettings {
+	guild, ok := gs.settings[guildID]
+	if !ok {
+		guild = &guildSettings{GuildID: guildID}
+		gs.settings[guildID] = guild
+	}
+
+	return guild
+}
+
+func (gs *GuildSettingsStore) writeData() error {
+	gs.mutex.RLock()
+	data := make([]*guildSettings, 0, len(gs.settings))
+	for _, guild := range gs.settings {
+		data = append(data, guild)
+	}
+	//Save while still holding the lock, the store encodes the entries we share with readers
+	defer gs.mutex.RUnlock()
+
+	return gs.store.Save(guildSettingsDocument, data)
+}
+
+func validateTrigger(trigger string) error {
+	if trigger == "" {
+		return errors.New("it can't be empty")
+	}
+	if len(trigger) > maxPrefixLength {
+		return fmt.Errorf("it can be at most %d characters", maxPrefixLength)
+	}
+	if strings.ContainsAny(trigger, " \t\n`") {
+		return errors.New("it can't contain spaces or backticks")
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+//serveHTTP starts serving the handler in the background, returning the server so it can be closed, or nil if we couldn't listen
+func serveHTTP(name string, address string, handler http.Handler) *http.Server {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Error("Error starting "+name+" listener", "address", address, "error", err)
+		return nil
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error(name+" listener stopped", "error", err)
+		}
+	}()
+
+	logger.Info("Serving "+name, "address", listener.Addr().String())
+	return server
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//OverflowPolicy determines what happens to new events when a handler's queue is full
+type OverflowPolicy string
+
+const (
+	//DropOldest discards the oldest queued event to make room for the new one
+	DropOldest OverflowPolicy = "drop-oldest"
+	//DropNewest discards the new event, leaving the queue untouched
+	DropNewest OverflowPolicy = "drop-newest"
+	//BlockWithTimeout waits for room in the queue, dropping the new event if none frees up in time
+	BlockWithTimeout OverflowPolicy = "block"
+)
+
+const defaultQueueSize = 100
+const defaultQueueTimeout = 5 * time.Second
+
+//QueueConfiguration Configures the queues feeding each handler
+type QueueConfiguration struct {
+	Size    int    `json:"Size"`
+	Policy  string `json:"Policy"`
+	Timeout string `json:"Timeout"`
+}
+
+//HandlerQueue is a bounded queue of events for a single handler
+type HandlerQueue struct {
+	name    string
+	channel chan *discordgo.MessageCreate
+	policy  OverflowPolicy
+	timeout time.Duration
+	dropped uint64
+	//mutex is held by pushers, and by Close while it marks the queue closed, so nothing is queued behind its stop
+	mutex  sync.RWMutex
+	closed bool
+	//shuffle is held while dropping the oldest event, so only one pusher makes room at a time
+	shuffle sync.Mutex
+}
+
+//NewHandlerQueue builds a queue from configuration, falling back to defaults for anything unset or invalid
+func NewHandlerQueue(name string, config QueueConfiguration) *HandlerQueue {
+	size := config.Size
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+
+	policy := OverflowPolicy(config.Policy)
+	switch policy {
+	case DropOldest, DropNewest, BlockWithTimeout:
+	case "":
+		policy = DropOldest
+	default:
+		logger.Warn("Unknown queue policy, using "+string(DropOldest), "policy", config.Policy)
+		policy = DropOldest
+	}
+
+	timeout := defaultQueueTimeout
+	if config.Timeout != "" {
+		if parsed, err := time.ParseDuration(config.Timeout); err == nil {
+			timeout = parsed
+		} else {
+			logger.Warn("Invalid queue timeout", "timeout", config.Timeout, "error", err)
+		}
+	}
+
+	return &HandlerQueue{
+		name:    name,
+		channel: make(chan *discordgo.MessageCreate, size),
+		policy:  policy,
+		timeout: timeout,
+	}
+}
+
+//Channel returns the channel the handler should read its events from
+func (q *HandlerQueue) Channel() chan *discordgo.MessageCreate {
+	return q.channel
+}
+
+//Push queues up the event without blocking the caller beyond the configured policy
+//Returns false if the event (or an older one, for drop-oldest) was dropped, or the queue is closed
+func (q *HandlerQueue) Push(m *discordgo.MessageCreate) bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.channel <- m:
+		return true
+	default:
+	}
+
+	switch q.policy {
+	case DropNewest:
+		q.drop()
+		return false
+	case BlockWithTimeout:
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		select {
+		case q.channel <- m:
+			return true
+		case <-timer.C:
+			q.drop()
+			return false
+		}
+	default:
+		//Only one pusher may shuffle the queue at a time, otherwise we'd drop more than needed
+		//Close can't have queued its stop yet, so that's never what we drop
+		q.shuffle.Lock()
+		defer q.shuffle.Unlock()
+		for {
+			select {
+			case q.channel <- m:
+				return false
+			default:
+			}
+
+			select {
+			case <-q.channel:
+				q.drop()
+			default:
+			}
+		}
+	}
+}
+
+//Close signals the handler to stop once it's worked through everything queued ahead of the signal
+//Events pushed afterwards are dropped, as the handler won't be around to see them
+//Returns false if the handler didn't make room for the signal within the timeout, eg because it's stuck
+//Waiting for the handler to finish is up to its Stop
+func (q *HandlerQueue) Close(timeout time.Duration) bool {
+	//Once closed nothing else is queued, so the signal can wait for room without holding up pushers
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case q.channel <- nil:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+//Dropped returns the number of events this queue has discarded
+func (q *HandlerQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+//Depth returns the number of events currently waiting in the queue
+func (q *HandlerQueue) Depth() int {
+	return len(q.channel)
+}
+
+func (q *HandlerQueue) drop() {
+	dropped := atomic.AddUint64(&q.dropped, 1)
+	logger.Warn("Queue full", "handler", q.name, "policy", q.policy, "dropped", dropped)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func queuedMessage(content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{Content: content}}
+}
+
+//queuedContents empties the queue, returning what was waiting in order
+func queuedContents(q *HandlerQueue) []string {
+	contents := make([]string, 0)
+	for q.Depth() > 0 {
+		m := <-q.Channel()
+		if m == nil {
+			contents = append(contents, "<stop>")
+		} else {
+			contents = append(contents, m.Content)
+		}
+	}
+
+	return contents
+}
+
+func TestHandlerQueueOverflowPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy   OverflowPolicy
+		accepted []bool
+		queued   string
+	}{
+		{DropOldest, []bool{true, true, false, false}, "2 3"},
+		{DropNewest, []bool{true, true, false, false}, "0 1"},
+		{BlockWithTimeout, []bool{true, true, false, false}, "0 1"},
+	} {
+		q := NewHandlerQueue("Test Handler", QueueConfiguration{Size: 2, Policy: string(test.policy), Timeout: "10ms"})
+		for i, expected := range test.accepted {
+			if accepted := q.Push(queuedMessage(strconv.Itoa(i))); accepted != expected {
+				t.Errorf("%s: expected push %d to return %v", test.policy, i, expected)
+			}
+		}
+
+		if q.Depth() != 2 || q.Dropped() != 2 {
+			t.Errorf("%s: expected 2 queued and 2 dropped, got %d and %d", test.policy, q.Depth(), q.Dropped())
+		}
+		queued := queuedContents(q)
+		if joined := queued[0] + " " + queued[1]; joined != test.queued {
+			t.Errorf("%s: expected %s to be left queued, got %s", test.policy, test.queued, joined)
+		}
+	}
+}
+
+func TestHandlerQueueBlocksUntilThereIsRoom(t *testing.T) {
+	q := NewHandlerQueue("Test Handler", QueueConfiguration{Size: 1, Policy: string(BlockWithTimeout), Timeout: "5s"})
+	q.Push(queuedMessage("first"))
+
+	go func() { <-q.Channel() }()
+	if !q.Push(queuedMessage("second")) || q.Dropped() != 0 {
+		t.Errorf("expected the push to wait for room, dropped %d", q.Dropped())
+	}
+}
+
+func TestHandlerQueueCloseIsNeverDropped(t *testing.T) {
+	q := NewHandlerQueue("Test Handler", QueueConfiguration{Size: 1, Policy: string(DropOldest)})
+	q.Push(queuedMessage("first"))
+
+	handled := make(chan []string)
+	go func() {
+		contents := make([]string, 0)
+		for m := range q.Channel() {
+			if m == nil {
+				break
+			}
+			contents = append(contents, m.Content)
+		}
+		handled <- contents
+	}()
+
+	q.Close(5 * time.Second)
+	if q.Push(queuedMessage("late")) {
+		t.Error("expected pushes after closing to be refused")
+	}
+	if contents := <-handled; len(contents) != 1 || contents[0] != "first" {
+		t.Errorf("expected the handler to see the queued event then stop, got %v", contents)
+	}
+	if q.Depth() != 0 {
+		t.Errorf("expected nothing to be left behind the stop, got %v", queuedContents(q))
+	}
+}
+
+func TestHandlerQueueStopsWhilePushersDropOldest(t *testing.T) {
+	q := NewHandlerQueue("Test Handler", QueueConfiguration{Size: 1, Policy: string(DropOldest)})
+	stopped := make(chan struct{})
+	go func() {
+		for m := range q.Channel() {
+			if m == nil {
+				close(stopped)
+				return
+			}
+		}
+	}()
+
+	var pushers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		pushers.Add(1)
+		go func() {
+			defer pushers.Done()
+			for j := 0; j < 1000; j++ {
+				q.Push(queuedMessage("spam"))
+			}
+		}()
+	}
+	q.Close(5 * time.Second)
+	pushers.Wait()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handler to be told to stop")
+	}
+}
+
+func TestHandlerQueueCloseGivesUpOnAStuckHandler(t *testing.T) {
+	q := NewHandlerQueue("Test Handler", QueueConfiguration{Size: 1})
+	q.Push(queuedMessage("first"))
+
+	//Nothing is reading, so there's never room for the stop
+	if q.Close(10 * time.Millisecond) {
+		t.Error("expected the stop not to be queued")
+	}
+	if q.Push(queuedMessage("late")) {
+		t.Error("expected pushes after closing to be refused")
+	}
+	if contents := queuedContents(q); len(contents) != 1 || contents[0] != "first" {
+		t.Errorf("expected only the first event to be queued, got %v", contents)
+	}
+}
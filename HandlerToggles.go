@@ -0,0 +1,239 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+//HandlerConfiguration Declares whether a handler runs, and where
+type HandlerConfiguration struct {
+	//Enabled starts the handler. Unset uses the handler's default, which is on for all but the echo and fortune handlers
+	Enabled *bool `json:"Enabled"`
+	//OptIn makes the handler only respond in servers and channels it's been switched on in, rather than everywhere it hasn't been switched off
+	OptIn bool `json:"OptIn"`
+}
+
+//handlerToggles Are a guild's overrides of which handlers respond, by handler key. Channels override their guild
+type handlerToggles struct {
+	GuildID  string                     `json:"guildID"`
+	Handlers map[string]bool            `json:"handlers"`
+	Channels map[string]map[string]bool `json:"channels"`
+}
+
+//toggledHandler Is a running handler which can be switched on and off
+type toggledHandler struct {
+	name  string
+	optIn bool
+	//core handlers manage the bot, so are never switched off
+	core bool
+}
+
+//handlerState Is whether a handler responds in a channel, and which setting decided it
+type handlerState struct {
+	Name   string
+	Active bool
+	//Source is core, channel, server or default
+	Source string
+}
+
+//HandlerToggleStore Decides which handlers respond in each guild and channel
+type HandlerToggleStore struct {
+	mutex    sync.RWMutex
+	store    Store
+	handlers map[string]*toggledHandler
+	order    []string
+	guilds   map[string]*handlerToggles
+}
+
+var handlerToggleStore *HandlerToggleStore
+
+//NewHandlerToggleStore loads the saved overrides. Handlers respond everywhere until they're registered with other defaults
+func NewHandlerToggleStore(store Store) *HandlerToggleStore {
+	hs := &HandlerToggleStore{
+		store:    store,
+		handlers: make(map[string]*toggledHandler),
+		order:    make([]string, 0),
+		guilds:   make(map[string]*handlerToggles),
+	}
+
+	var data []*handlerToggles
+	err := store.Load(handlerTogglesDocument, &data)
+	if err == nil {
+		logger.Info("Reading saved handler toggles")
+		for _, guild := range data {
+			hs.guilds[guild.GuildID] = guild
+		}
+	} else if err != ErrNotFound {
+		logger.Error("Error loading handler toggles", "error", err)
+	}
+
+	return hs
+}
+
+//handlerKey is how handlers are named in configuration and commands, eg reaction or alternatingcase
+//Their full names, like Reaction Handler or ReactionHandler, work too
+func handlerKey(name string) string {
+	key := strings.ToLower(strings.Replace(name, " ", "", -1))
+	if key != "handler" {
+		key = strings.TrimSuffix(key, "handler")
+	}
+
+	return key
+}
+
+//handlerConfiguration finds the handler's configuration, however its name was written
+func handlerConfiguration(configurations map[string]HandlerConfiguration, name string) (HandlerConfiguration, bool) {
+	for configured, configuration := range configurations {
+		if handlerKey(configured) == handlerKey(name) {
+			return configuration, true
+		}
+	}
+
+	return HandlerConfiguration{}, false
+}
+
+//Register adds a running handler, which only responds where it's been switched on if optIn is set
+func (hs *HandlerToggleStore) Register(name string, optIn bool, core bool) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	key := handlerKey(name)
+	if _, exists := hs.handlers[key]; !exists {
+		hs.order = append(hs.order, key)
+	}
+	hs.handlers[key] = &toggledHandler{name: name, optIn: optIn, core: core}
+}
+
+//Lookup finds a registered handler by name or key, returning its full name
+func (hs *HandlerToggleStore) Lookup(name string) (string, bool) {
+	hs.mutex.RLock()
+	defer hs.mutex.RUnlock()
+
+	if handler, ok := hs.handlers[handlerKey(name)]; ok {
+		return handler.name, true
+	}
+
+	return "", false
+}
+
+//Core checks if the handler is one which can't be switched off
+func (hs *HandlerToggleStore) Core(name string) bool {
+	hs.mutex.RLock()
+	defer hs.mutex.RUnlock()
+
+	handler, ok := hs.handlers[handlerKey(name)]
+	return ok && handler.core
+}
+
+//Active checks if the handler responds in the channel
+func (hs *HandlerToggleStore) Active(name string, guildID string, channelID string) bool {
+	hs.mutex.RLock()
+	defer hs.mutex.RUnlock()
+
+	return hs.state(handlerKey(name), guildID, channelID).Active
+}
+
+//States lists whether each registered handler responds in the channel, in registration order
+func (hs *HandlerToggleStore) States(guildID string, channelID string) []handlerState {
+	hs.mutex.RLock()
+	defer hs.mutex.RUnlock()
+
+	states := make([]handlerState, 0, len(hs.order))
+	for _, key := range hs.order {
+		states = append(states, hs.state(key, guildID, channelID))
+	}
+
+	return states
+}
+
+//state decides whether the handler responds in the channel. Callers hold the lock
+func (hs *HandlerToggleStore) state(key string, guildID string, channelID string) handlerState {
+	handler, ok := hs.handlers[key]
+	if !ok {
+		//Handlers we weren't told about aren't ours to switch off
+		return handlerState{Name: key, Active: true, Source: "default"}
+	}
+
+	if handler.core {
+		return handlerState{Name: handler.name, Active: true, Source: "core"}
+	}
+
+	if guild, ok := hs.guilds[guildID]; ok {
+		if on, ok := guild.Channels[channelID][key]; ok {
+			return handlerState{Name: handler.name, Active: on, Source: "channel"}
+		}
+		if on, ok := guild.Handlers[key]; ok {
+			return handlerState{Name: handler.name, Active: on, Source: "server"}
+		}
+	}
+
+	return handlerState{Name: handler.name, Active: !handler.optIn, Source: "default"}
+}
+
+//Set switches the handler on or off in the channel, or the whole guild if channelID is empty
+//A nil on removes the override, so the channel follows its guild, and the guild the handler's default
+func (hs *HandlerToggleStore) Set(guildID string, channelID string, name string, on *bool) error {
+	key := handlerKey(name)
+
+	hs.mutex.Lock()
+	guild, ok := hs.guilds[guildID]
+	if !ok {
+		guild = &handlerToggles{GuildID: guildID, Handlers: make(map[string]bool), Channels: make(map[string]map[string]bool)}
+		hs.guilds[guildID] = guild
+	}
+	if guild.Handlers == nil {
+		guild.Handlers = make(map[string]bool)
+	}
+	if guild.Channels == nil {
+		guild.Channels = make(map[string]map[string]bool)
+	}
+
+	toggles := guild.Handlers
+	if channelID != "" {
+		if guild.Channels[channelID] == nil {
+			guild.Channels[channelID] = make(map[string]bool)
+		}
+		toggles = guild.Channels[channelID]
+	}
+
+	if on != nil {
+		toggles[key] = *on
+	} else {
+		delete(toggles, key)
+		if channelID != "" && len(toggles) == 0 {
+			delete(guild.Channels, channelID)
+		}
+	}
+	hs.mutex.Unlock()
+
+	return hs.writeData()
+}
+
+func (hs *HandlerToggleStore) writeData() error {
+	hs.mutex.RLock()
+	data := make([]*handlerToggles, 0, len(hs.guilds))
+	for _, guild := range hs.guilds {
+		data = append(data, guild)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].GuildID < data[j].GuildID })
+	//Save while still holding the lock, the store encodes the toggles we share with readers
+	defer hs.mutex.RUnlock()
+
+	return hs.store.Save(handlerTogglesDocument, data)
+}
+
+//handlerActive checks if the handler responds in the channel, which they all do until toggles are set up
+func handlerActive(name string, guildID string, channelID string) bool {
+	return handlerToggleStore == nil || handlerToggleStore.Active(name, guildID, channelID)
+}
+
+//channelActive checks if the handler posts to the channel by itself, eg from a scheduled job
+//There's no message to say which guild the channel is in, so it's looked up
+func channelActive(session Session, name string, channelID string) bool {
+	if handlerToggleStore == nil {
+		return true
+	}
+
+	return handlerToggleStore.Active(name, channelGuild(session, channelID), channelID)
+}
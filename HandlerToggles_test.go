@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func useTestToggles(t *testing.T) *HandlerToggleStore {
+	toggles := NewHandlerToggleStore(NewJSONFileStore("."))
+	handlerToggleStore = toggles
+	t.Cleanup(func() { handlerToggleStore = nil })
+	return toggles
+}
+
+func TestHandlerKeys(t *testing.T) {
+	for name, expected := range map[string]string{"Reaction Handler": "reaction", "AlternatingCaseHandler": "alternatingcase", "ip": "ip"} {
+		if key := handlerKey(name); key != expected {
+			t.Errorf("expected %s to be %s, got %s", name, expected, key)
+		}
+	}
+}
+
+func TestChannelTogglesOverrideTheirGuild(t *testing.T) {
+	useTempDir(t)
+	toggles := NewHandlerToggleStore(NewJSONFileStore("."))
+	toggles.Register("Alternating Case Handler", true, false)
+	toggles.Register("Reaction Handler", false, false)
+	toggles.Register("Settings Handler", false, true)
+
+	on, off := true, false
+	toggles.Set("guild", "memes", "alternatingcase", &on)
+	toggles.Set("guild", "", "ReactionHandler", &off)
+	toggles.Set("guild", "memes", "reaction", &on)
+	toggles.Set("guild", "", "settings", &off)
+
+	for _, check := range []struct {
+		handler  string
+		channel  string
+		expected bool
+	}{
+		{"Alternating Case Handler", "memes", true},
+		{"Alternating Case Handler", "serious", false},
+		{"Reaction Handler", "memes", true},
+		{"Reaction Handler", "serious", false},
+		{"Settings Handler", "serious", true},
+	} {
+		if active := toggles.Active(check.handler, "guild", check.channel); active != check.expected {
+			t.Errorf("expected %s in %s to be %v", check.handler, check.channel, check.expected)
+		}
+	}
+
+	//Toggles are saved, and resetting the channel falls back to the guild
+	reloaded := NewHandlerToggleStore(NewJSONFileStore("."))
+	reloaded.Register("Reaction Handler", false, false)
+	reloaded.Set("guild", "memes", "reaction", nil)
+	if reloaded.Active("Reaction Handler", "guild", "memes") {
+		t.Error("expected the channel to follow its guild once reset")
+	}
+}
+
+func TestRouterSkipsHandlersSwitchedOff(t *testing.T) {
+	router, session, queue := newTestRouter(t)
+	toggles := useTestToggles(t)
+	toggles.Register("Release Handler", false, false)
+	listener := NewHandlerQueue("Reaction Handler", QueueConfiguration{})
+	router.Register(NewReactionHandler(session, NewJSONFileStore(".")), listener)
+	toggles.Register("Reaction Handler", false, false)
+
+	off := false
+	toggles.Set("guild", "chan", "release", &off)
+	toggles.Set("guild", "", "reaction", &off)
+
+	router.Route(guildMessage("guild", "/rw list"))
+	if queue.Depth() != 0 || listener.Depth() != 0 {
+		t.Errorf("expected nothing to be queued, got %d and %d", queue.Depth(), listener.Depth())
+	}
+	assertContains(t, session.LastSent("chan"), "`/rw` is switched off in this channel")
+
+	router.Route(guildMessage("other", "hello"))
+	if listener.Depth() != 1 {
+		t.Errorf("expected the listener to still hear other guilds, got %d", listener.Depth())
+	}
+}
+
+func TestToggleHandlerSwitchesHandlers(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	toggles := useTestToggles(t)
+	toggles.Register("Reaction Handler", false, false)
+	toggles.Register("Toggle Handler", false, true)
+	h := newHandlerHarness(t, NewToggleHandler(session, toggles))
+
+	h.Say("chan", "admin", "/handlers disable reaction")
+	assertContains(t, session.LastSent("chan"), "Switched Reaction Handler off in this channel")
+	h.Say("chan", "admin", "/handlers list")
+	assertContains(t, session.LastSent("chan"), "reaction: Off in this channel")
+
+	h.Say("chan", "admin", "/handlers reset reaction")
+	assertContains(t, session.LastSent("chan"), "it's now on here")
+
+	h.Say("chan", "admin", "/handlers disable toggle")
+	assertContains(t, session.LastSent("chan"), "can't be switched off")
+	h.Say("chan", "admin", "/handlers disable nonsense")
+	assertContains(t, session.LastSent("chan"), "Usage: /handlers")
+	h.Say("chan", "admin", "/handlers disable reaction server")
+	assertContains(t, session.LastSent("chan"), "only be switched on and off for a server from within it")
+}
+
+func TestScheduledPostsSkipChannelsSwitchedOff(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{channelGuilds: map[string]string{"quiet": "guild", "chan": "guild"}}
+	toggles := useTestToggles(t)
+	toggles.Register("Release Handler", false, false)
+	handler := NewReleaseHandler(session, NewJSONFileStore("."))
+	h := newHandlerHarness(t, handler)
+
+	nextWeek := time.Now().AddDate(0, 0, 7)
+	h.Say("quiet", "user", "/rw add "+nextWeek.Format("01/02/06")+" Persona 8")
+	h.Say("chan", "user", "/rw add "+nextWeek.Format("01/02/06")+" Persona 8")
+	off := false
+	toggles.Set("guild", "quiet", "release", &off)
+
+	job := handler.Jobs()[0]
+	today := time.Now()
+	job.Queue <- func() {
+		job.Run(JobRun{Occurrence: time.Date(today.Year(), today.Month(), today.Day(), 11, 0, 0, 0, time.Local), Session: session})
+	}
+	h.Say("chan", "user", "")
+
+	assertContains(t, session.LastSent("chan"), "Persona 8 is releasing next week!")
+	for _, sent := range session.Sent("quiet") {
+		if sent == "Persona 8 is releasing next week!" {
+			t.Error("expected nothing to be announced where releases are switched off")
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -10,22 +11,20 @@ import (
 
 //IPHandler Echoes the public IP
 type IPHandler struct {
+	handlerLoop
+	session Session
 }
 
+//NewIPHandler creates a handler which responds through the provided session
+func NewIPHandler(session Session) *IPHandler {
+	return &IPHandler{session: session}
+}
+
+const ipCommand = "/ip"
+
 //Init Nothing to do here
-func (iph *IPHandler) Init(m chan *discordgo.MessageCreate) {
-	go func() {
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					iph.handleMessage(message)
-				} else {
-					return
-				}
-			}
-		}
-	}()
+func (iph *IPHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	iph.listen(ctx, iph.GetName(), m, iph.handleMessage)
 }
 
 //GetName returns name of handler
@@ -63,7 +62,7 @@ type Message struct {
 
 //handleMessage echoes the messages seen to stdout
 func (iph *IPHandler) handleMessage(m *discordgo.MessageCreate) {
-	if m.Content == "/ip" {
+	if m.Content == ipCommand {
 		var myClient = &http.Client{Timeout: 1 * time.Second}
 		resp, err := myClient.Get("http://ipinfo.io")
 		if err == nil {
@@ -73,22 +72,25 @@ func (iph *IPHandler) handleMessage(m *discordgo.MessageCreate) {
 			decoder := json.NewDecoder(resp.Body)
 			err = decoder.Decode(message)
 			if err != nil {
-				print("Failed to extract ipinfo")
+				iph.log.Error("Failed to extract ipinfo", "error", err)
 			} else {
-				MessageSender.SendMessage(m.ChannelID, "My publicly accessible IP is: "+message.IP)
-				if err != nil {
-					print("Error sending message")
-				}
+				iph.session.SendMessage(m.ChannelID, "My publicly accessible IP is: "+message.IP)
 			}
 			return
 		}
 
-		MessageSender.SendMessage(m.ChannelID, "Error obtaining publicly accessible IP")
+		iph.log.Error("Error obtaining ipinfo", "error", err)
+		iph.session.SendMessage(m.ChannelID, "Error obtaining publicly accessible IP")
 	}
 
 }
 
+//Commands returns the commands owned by this handler
+func (iph *IPHandler) Commands() []Command {
+	return []Command{{Prefix: ipCommand, Description: "Display the current publicly accessible IP", Capability: "ip.view"}}
+}
+
 //Help Gets info about this release handler
 func (iph *IPHandler) Help() string {
-	return "/ip - Display the current publicly accessible IP"
+	return ipCommand + " - Display the current publicly accessible IP"
 }
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -15,20 +18,33 @@ import (
 
 //ImageHandler automatically posts images from specified directories on a schedule
 type ImageHandler struct {
+	handlerLoop
+	session      Session
+	store        Store
 	matcher      regexp.Regexp
 	startMatcher regexp.Regexp
 	nextMatcher  regexp.Regexp
+	tzMatcher    regexp.Regexp
 	imageMap     map[string]*channelImageData
 	scheduleEnum map[string]time.Weekday
+	completions  completionCache
+}
+
+//NewImageHandler creates a handler which posts through the provided session and saves to the store
+func NewImageHandler(session Session, store Store) *ImageHandler {
+	return &ImageHandler{session: session, store: store}
 }
 
 type imageData struct {
-	Dir        string `json:"dir"`
-	Current    int    `json:"current"`
-	Schedule   time.Weekday
-	Repeat     bool
-	Hour       int
-	Multiplier int
+	Dir     string `json:"dir"`
+	Current int    `json:"current"`
+	//Schedule is a lowercase day of the week, daily or manual
+	Schedule   string `json:"schedule"`
+	Repeat     bool   `json:"repeat"`
+	Hour       int    `json:"hour"`
+	Multiplier int    `json:"multiplier"`
+	//TimeZone is the zone Hour is in, usually whoever started the block's
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type channelImageData struct {
@@ -36,65 +52,104 @@ type channelImageData struct {
 	ImageData []*imageData `json:"imageData"`
 }
 
+//migrateImageV1 replaces the numeric schedule, where 7 meant daily and -1 manual, with its name, and tags the remaining fields
+func migrateImageV1(data interface{}) (interface{}, error) {
+	for _, channelData := range jsonObjects(data) {
+		for _, block := range jsonObjects(channelData["imageData"]) {
+			renameKeys(block, map[string]string{"Schedule": "schedule", "Repeat": "repeat", "Hour": "hour", "Multiplier": "multiplier"})
+
+			number, ok := block["schedule"].(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("image block %v has no numeric schedule", block["dir"])
+			}
+			schedule, err := number.Int64()
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case schedule == 7:
+				block["schedule"] = "daily"
+			case schedule >= 0 && schedule < 7:
+				block["schedule"] = strings.ToLower(time.Weekday(schedule).String())
+			default:
+				block["schedule"] = "manual"
+			}
+		}
+	}
+
+	return data, nil
+}
+
 const iCommand string = "/i"
-const imageDataFile = "./imageData.json"
+
+//imageSchedules Are the schedules image blocks can be posted on
+var imageSchedules = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+
+	//not real days of the week, but used for validating input
+	"daily":  7,
+	"manual": -1,
+}
+
+//imageDirMatcher matches the directories image blocks may read from, which are always directly inside the reader directory
+var imageDirMatcher = regexp.MustCompile(`^\w+$`)
 
 //Init compiles regexp and loads in saved information
-func (ih *ImageHandler) Init(m chan *discordgo.MessageCreate) {
+func (ih *ImageHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
 	ih.matcher = *regexp.MustCompile(`^\` + iCommand + `\s+(\w+)\s*(.*)`)
 	//dir, schedule, hour, repeat
 	ih.startMatcher = *regexp.MustCompile(`^(\w+)\s(\w+)\s(\d+)\s(\d+)\s(\w+)$`)
 	ih.nextMatcher = *regexp.MustCompile(`^(\w+)$`)
+	ih.tzMatcher = *regexp.MustCompile(`^(\w+)\s+(\S+)$`)
 
-	ih.scheduleEnum = make(map[string]time.Weekday)
-	ih.scheduleEnum["sunday"] = time.Sunday
-	ih.scheduleEnum["monday"] = time.Monday
-	ih.scheduleEnum["tuesday"] = time.Tuesday
-	ih.scheduleEnum["wednesday"] = time.Wednesday
-	ih.scheduleEnum["thursday"] = time.Thursday
-	ih.scheduleEnum["friday"] = time.Friday
-	ih.scheduleEnum["saturday"] = time.Saturday
-
-	//not real days of the week, but used for validating input
-	ih.scheduleEnum["daily"] = 7
-	ih.scheduleEnum["manual"] = -1
+	ih.scheduleEnum = imageSchedules
 
 	ih.imageMap = make(map[string]*channelImageData)
 
 	//Need to read in stored json info as well!
 	var data []*channelImageData
 
-	// Get configuration
-	fileData, err := ioutil.ReadFile(imageDataFile)
-
 	//Load up in-memory cache of this info
+	err := ih.store.Load(imageDocument, &data)
 	if err == nil {
-		fmt.Println("Reading saved image data")
-		err = json.Unmarshal(fileData, &data)
-		if err == nil {
-			for _, channelData := range data {
-				ih.imageMap[channelData.ChannelID] = channelData
-			}
+		ih.log.Info("Reading saved image data")
+		for _, channelData := range data {
+			ih.imageMap[channelData.ChannelID] = channelData
 		}
+	} else if err != ErrNotFound {
+		ih.log.Error("Error loading image data", "error", err)
 	}
+	ih.updateCompletions()
 
-	go func() {
-		//schedule to check for when we need to do stuff!
-		minuteSchedule := time.NewTicker(time.Minute)
-		defer minuteSchedule.Stop()
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					ih.handleMessage(message)
-				} else {
-					return
-				}
-			case <-minuteSchedule.C:
-				ih.scheduledTask()
-			}
-		}
-	}()
+	ih.listen(ctx, ih.GetName(), m, ih.handleMessage)
+}
+
+//Reload re-reads the saved image blocks, keeping the ones we have if the file can't be loaded or any block is invalid
+func (ih *ImageHandler) Reload() (string, error) {
+	var data []*channelImageData
+	if err := loadStrict(ih.store, imageDocument, &data); err != nil && err != ErrNotFound {
+		return imageDocument, err
+	}
+
+	if err := validateImageBlocks(data); err != nil {
+		return imageDocument, err
+	}
+
+	imageMap := make(map[string]*channelImageData)
+	for _, channelData := range data {
+		imageMap[channelData.ChannelID] = channelData
+	}
+
+	ih.imageMap = imageMap
+	ih.updateCompletions()
+	return imageDocument, nil
 }
 
 //GetName returns our name
@@ -109,64 +164,65 @@ func (ih *ImageHandler) handleMessage(m *discordgo.MessageCreate) {
 		command := submatches[1]
 		switch command {
 		case "start":
-			ih.start(m.ChannelID, submatches[2])
+			ih.start(m.ChannelID, userLocation(m.GuildID, m.Author.ID), submatches[2])
 		case "next":
 			ih.next(m.ChannelID, submatches[2])
 		case "list":
 			ih.list(m.ChannelID)
+		case "tz":
+			ih.setTimeZone(m.ChannelID, submatches[2])
 		case "help":
-			ih.help(m.ChannelID)
+			ih.help(m.ChannelID, m.GuildID)
 		default:
-			ih.help(m.ChannelID)
+			ih.help(m.ChannelID, m.GuildID)
 		}
 
 		//DeleteMessage(m.ChannelID, m.ID)
 	}
 }
 
-//ScheduledTask Handle our scheduled release notifications
-func (ih *ImageHandler) scheduledTask() {
-	currentTime := time.Now()
+//Jobs posts image blocks on the hour. Blocks can be in any time zone, so check every minute for whose hour it is, whatever their offset
+func (ih *ImageHandler) Jobs() []Job {
+	return []Job{{Name: "image posts", Schedule: Every(time.Minute), Run: ih.scheduledTask, Queue: ih.tasks}}
+}
+
+//ScheduledTask Handle our scheduled image posts
+func (ih *ImageHandler) scheduledTask(run JobRun) {
 	updatedGlobally := false
 
-	//We only ever kick off when the time value is on the hour
-	if currentTime.Minute() == 0 {
-		//For each channel...
-		for _, channelData := range ih.imageMap {
-			//For each group of images...
-			afterIterationSlice := make([]*imageData, 0)
-			for _, imageBlock := range channelData.ImageData {
-				keep := true
-				//Does this image block have a schedule specified?
-				//-1 == manual, aka no schedule
-				if imageBlock.Schedule > 0 {
-					//7 == daily, so we do it always.
-					if imageBlock.Schedule == 7 || (imageBlock.Schedule) == currentTime.Weekday() {
-						if imageBlock.Hour == currentTime.Hour() {
-							if imageList, err := ih.listFiles(imageBlock.Dir); err == nil {
-								ih.displayMultiple(channelData.ChannelID, imageBlock, imageList)
-								updatedGlobally = true
-								if len(imageList) <= imageBlock.Current {
-									if imageBlock.Repeat {
-										imageBlock.Current = 0
-									} else {
-										keep = false
-									}
-								}
+	//For each channel...
+	for _, channelData := range ih.imageMap {
+		//For each group of images...
+		afterIterationSlice := make([]*imageData, 0)
+		for _, imageBlock := range channelData.ImageData {
+			keep := true
+			due := dueAt(run.Occurrence, channelItemLocation(ih.session, channelData.ChannelID, imageBlock.TimeZone), imageBlock.Hour, 0)
+			//Is this image block scheduled for today? Manual blocks never are
+			if imageBlock.Schedule == "daily" || imageBlock.Schedule == strings.ToLower(due.Weekday().String()) {
+				//Blocks in channels we've been switched off in wait where they are until we're back
+				if due.Equal(run.Occurrence) && channelActive(ih.session, ih.GetName(), channelData.ChannelID) {
+					if imageList, err := ih.listFiles(imageBlock.Dir); err == nil {
+						ih.displayMultiple(channelData.ChannelID, imageBlock, imageList)
+						updatedGlobally = true
+						if len(imageList) <= imageBlock.Current {
+							if imageBlock.Repeat {
+								imageBlock.Current = 0
 							} else {
-								MessageSender.SendMessage(channelData.ChannelID, "Could not list out files for image block")
+								keep = false
 							}
 						}
+					} else {
+						run.Session.SendMessage(channelData.ChannelID, "Could not list out files for image block")
 					}
 				}
-
-				if keep {
-					afterIterationSlice = append(afterIterationSlice, imageBlock)
-				}
 			}
 
-			channelData.ImageData = afterIterationSlice
+			if keep {
+				afterIterationSlice = append(afterIterationSlice, imageBlock)
+			}
 		}
+
+		channelData.ImageData = afterIterationSlice
 	}
 
 	if updatedGlobally {
@@ -174,12 +230,64 @@ func (ih *ImageHandler) scheduledTask() {
 	}
 }
 
+//Commands returns the commands owned by this handler
+func (ih *ImageHandler) Commands() []Command {
+	hourMin := 0.0
+	countMin := 1.0
+
+	return []Command{{
+		Prefix:      iCommand,
+		Description: "Image Reader - Reads images into chat from disk on a set schedule",
+		Subcommands: []Subcommand{
+			{Name: "start", Description: "Start posting images from a server dir", Capability: "images.manage", Options: []CommandOption{
+				{Name: "dir", Description: "Directory to post from", Type: discordgo.ApplicationCommandOptionString, Required: true, Autocomplete: true},
+				{Name: "frequency", Description: "When posts are made", Type: discordgo.ApplicationCommandOptionString, Required: true,
+					Choices: []string{"manual", "daily", "sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}},
+				{Name: "hour", Description: "Hour of the day to post at", Type: discordgo.ApplicationCommandOptionInteger, Required: true, MinValue: &hourMin, MaxValue: 23},
+				{Name: "pages", Description: "Pages to display per post", Type: discordgo.ApplicationCommandOptionInteger, Required: true, MinValue: &countMin},
+				{Name: "repeat", Description: "Start over once finished", Type: discordgo.ApplicationCommandOptionBoolean, Required: true},
+			}},
+			{Name: "next", Description: "Post the next pages of an image block", Capability: "images.manage", Options: []CommandOption{
+				{Name: "dir", Description: "Image block directory", Type: discordgo.ApplicationCommandOptionString, Required: true, Autocomplete: true},
+			}},
+			{Name: "list", Description: "List image blocks and their progress"},
+			{Name: "tz", Description: "Change the time zone an image block's hour is in", Capability: "images.manage", Options: []CommandOption{
+				{Name: "dir", Description: "Image block directory", Type: discordgo.ApplicationCommandOptionString, Required: true, Autocomplete: true},
+				{Name: "zone", Description: "Time zone, eg America/New_York, or default to use the server's", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "help", Description: "Show image reader help"},
+		},
+	}}
+}
+
+//Autocomplete suggests directories to start from, or the channel's image blocks
+func (ih *ImageHandler) Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	if option != "dir" {
+		return nil
+	}
+
+	if subcommand == "next" || subcommand == "tz" {
+		return ih.completions.Match(channelID, partial)
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	if entries, err := ioutil.ReadDir(ih.readerDir()); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: entry.Name(), Value: entry.Name()})
+			}
+		}
+	}
+
+	return matchChoices(choices, partial)
+}
+
 //Help Gets info about this handler
 func (ih *ImageHandler) Help() string {
 	return "/i : Image Reader - Reads images into chat from disk on a set schedule"
 }
 
-func (ih *ImageHandler) help(channelID string) {
+func (ih *ImageHandler) help(channelID string, guildID string) {
 	helpMessage := "The following commands are supported by /i:\n"
 	helpMessage += "/i start <dir> <frequency> <hour> <pages-per-post> <repeat>\n"
 	helpMessage += "  Starts automatic posting of the images in the specified server dir\n"
@@ -187,32 +295,43 @@ func (ih *ImageHandler) help(channelID string) {
 	helpMessage += "  Hour is hour of the day to post at (0-23)\n"
 	helpMessage += "  Pages per post is how many pages to display at once\n"
 	helpMessage += "  Repeat allows the image block to repeat once it has finished (true|false)\n"
+	helpMessage += "  Hour is in your time zone, see " + settingsCommand + " mytimezone\n"
 	helpMessage += "/i list - lists out all currently configured image blocks and their progress\n"
+	helpMessage += "/i tz <dir> <zone> - Change the time zone the image block's hour is in, eg /i tz comic Asia/Tokyo\n"
 
-	MessageSender.SendMessage(channelID, helpMessage)
+	ih.session.SendMessage(channelID, localizeCommands(guildID, helpMessage))
 }
 
 func (ih *ImageHandler) list(channelID string) {
 	if channelData, exists := ih.imageMap[channelID]; exists {
 		if len(channelData.ImageData) > 0 {
-			message := "Image Block Data\n"
+			embed := newEmbed("Image blocks")
 			for _, imageBlock := range channelData.ImageData {
 				if fileList, err := ih.listFiles(imageBlock.Dir); err == nil {
-					total := strconv.Itoa(len(fileList))
-					page := strconv.Itoa(imageBlock.Current + 1) //Switch from 0-index to 1-index
-					message += imageBlock.Dir + " Page: " + page + " / " + total + "\n"
+					//Switch from 0-index to 1-index
+					progress := fmt.Sprintf("Page %d / %d, %d per post", imageBlock.Current+1, len(fileList), imageBlock.Multiplier)
+					if imageBlock.Schedule != "manual" {
+						progress += fmt.Sprintf(", %s at %d:00", imageBlock.Schedule, imageBlock.Hour)
+					}
+					if imageBlock.TimeZone != "" {
+						progress += " (" + imageBlock.TimeZone + ")"
+					}
+					if imageBlock.Repeat {
+						progress += ", repeating"
+					}
+					embed.Fields = append(embed.Fields, embedField(imageBlock.Dir, progress, false))
 				}
 			}
-			MessageSender.SendMessage(channelID, message)
+			sendPaged(ih.session, channelID, embed)
 			return
 		}
 	}
 
 	//If we got here, no channel data exists!
-	MessageSender.SendMessage(channelID, "No image block data exists for this channel!")
+	ih.session.SendMessage(channelID, "No image block data exists for this channel!")
 }
 
-func (ih *ImageHandler) start(channelID string, command string) {
+func (ih *ImageHandler) start(channelID string, location *time.Location, command string) {
 	submatches := ih.startMatcher.FindStringSubmatch(command)
 	if submatches != nil {
 		dir := submatches[1]
@@ -229,30 +348,168 @@ func (ih *ImageHandler) start(channelID string, command string) {
 				if multiplier, err := strconv.Atoi(multiplierStr); err == nil {
 					if repeat, err := strconv.ParseBool(repeatStr); err == nil {
 						if newImageData, err := ih.buildImageData(dir, schedule, hour, multiplier, repeat); err == nil {
+							//Keep the zone of whoever started it, even if it's only the guild's or bot's
+							newImageData.TimeZone = location.String()
 
-							//Create our channel map if needed
-							if _, exists := ih.imageMap[channelID]; !exists {
-								ih.imageMap[channelID] = &channelImageData{
-									ChannelID: channelID,
-								}
-								ih.imageMap[channelID].ImageData = make([]*imageData, 0)
+							if err := ih.addBlock(channelID, newImageData); err != nil {
+								ih.session.SendMessage(channelID, err.Error())
 							}
-
-							//Append our new image data
-							ih.imageMap[channelID].ImageData = append(ih.imageMap[channelID].ImageData, newImageData)
-							ih.writeData()
 						} else {
-							fmt.Println("failed to build up image data!")
+							ih.log.Error("Failed to build up image data", "dir", dir, "error", err)
 						}
 					}
 				}
 			}
 		} else {
-			MessageSender.SendMessage(channelID, schedule+" is not a valid schedule")
+			ih.session.SendMessage(channelID, schedule+" is not a valid schedule")
 		}
 	} else {
-		MessageSender.SendMessage(channelID, "Invalid start usage. See help for details")
+		ih.session.SendMessage(channelID, "Invalid start usage. See help for details")
+	}
+}
+
+//validateImageBlocks checks every saved image block, see validateBlock
+func validateImageBlocks(data []*channelImageData) error {
+	for _, channelData := range data {
+		for _, block := range channelData.ImageData {
+			if err := validateBlock(block); err != nil {
+				return fmt.Errorf("image block %s in channel %s: %v", block.Dir, channelData.ChannelID, err)
+			}
+		}
 	}
+
+	return nil
+}
+
+//validateBlock checks the image block reads from a directory of ours, on a schedule we understand
+func validateBlock(data *imageData) error {
+	if !imageDirMatcher.MatchString(data.Dir) {
+		return errors.New("Image block directories can only use letters, numbers and underscores")
+	}
+	if _, valid := imageSchedules[data.Schedule]; !valid {
+		return errors.New(data.Schedule + " is not a valid schedule")
+	}
+	if data.Hour < 0 || data.Hour > 23 {
+		return errors.New("Hour must be between 0 and 23")
+	}
+	if data.Multiplier < 1 {
+		return errors.New("Pages per post must be at least 1")
+	}
+	if data.Current < 0 {
+		return errors.New("Current page can't be negative")
+	}
+	if data.TimeZone != "" {
+		zone, err := validateTimeZone(data.TimeZone)
+		if err != nil {
+			return err
+		}
+		data.TimeZone = zone
+	}
+
+	return nil
+}
+
+//addBlock starts posting a new image block in the channel
+func (ih *ImageHandler) addBlock(channelID string, data *imageData) error {
+	if err := validateBlock(data); err != nil {
+		return err
+	}
+	if _, err := ih.block(channelID, data.Dir); err == nil {
+		return errors.New(data.Dir + " is already being posted in this channel")
+	}
+
+	//Create our channel map if needed
+	if _, exists := ih.imageMap[channelID]; !exists {
+		ih.imageMap[channelID] = &channelImageData{
+			ChannelID: channelID,
+		}
+		ih.imageMap[channelID].ImageData = make([]*imageData, 0)
+	}
+
+	//Append our new image data
+	ih.imageMap[channelID].ImageData = append(ih.imageMap[channelID].ImageData, data)
+	ih.writeData()
+	ih.audit("start", nil, data)
+	return nil
+}
+
+//editBlock replaces the block's settings, keeping the directory it reads from
+func (ih *ImageHandler) editBlock(channelID string, dir string, edited imageData) (*imageData, error) {
+	data, err := ih.block(channelID, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	edited.Dir = data.Dir
+	if err := validateBlock(&edited); err != nil {
+		return nil, err
+	}
+
+	before := *data
+	*data = edited
+	ih.writeData()
+	ih.audit("edit", before, data)
+	return data, nil
+}
+
+//removeBlock stops posting the image block
+func (ih *ImageHandler) removeBlock(channelID string, dir string) error {
+	if _, err := ih.block(channelID, dir); err != nil {
+		return err
+	}
+
+	imageGroup := ih.imageMap[channelID]
+	for x, data := range imageGroup.ImageData {
+		if data.Dir == dir {
+			imageGroup.ImageData = append(imageGroup.ImageData[:x], imageGroup.ImageData[x+1:]...)
+			ih.audit("remove", data, nil)
+			break
+		}
+	}
+	ih.writeData()
+	return nil
+}
+
+//block finds the channel's image block reading from dir
+func (ih *ImageHandler) block(channelID string, dir string) (*imageData, error) {
+	if imageGroup, ok := ih.imageMap[channelID]; ok {
+		for _, data := range imageGroup.ImageData {
+			if data.Dir == dir {
+				return data, nil
+			}
+		}
+	}
+
+	return nil, errors.New("No image block for " + dir + " in this channel")
+}
+
+func (ih *ImageHandler) setTimeZone(channelID string, command string) {
+	submatches := ih.tzMatcher.FindStringSubmatch(command)
+	if submatches == nil {
+		ih.session.SendMessage(channelID, "Invalid tz usage. See help for details")
+		return
+	}
+
+	zone, err := validateTimeZone(submatches[2])
+	if err != nil {
+		ih.session.SendMessage(channelID, "Can't use \""+submatches[2]+"\": "+err.Error())
+		return
+	}
+
+	if imageGroup, ok := ih.imageMap[channelID]; ok {
+		for _, data := range imageGroup.ImageData {
+			if data.Dir == submatches[1] {
+				before := data.TimeZone
+				data.TimeZone = zone
+				ih.writeData()
+				ih.audit("tz", before, zone)
+				ih.session.SendMessage(channelID, data.Dir+" now posts at "+strconv.Itoa(data.Hour)+":00 "+channelItemLocation(ih.session, channelID, zone).String())
+				return
+			}
+		}
+	}
+
+	ih.session.SendMessage(channelID, "No image block for "+submatches[1]+" in this channel")
 }
 
 func (ih *ImageHandler) next(channelID string, command string) {
@@ -263,22 +520,25 @@ func (ih *ImageHandler) next(channelID string, command string) {
 			for _, data := range imageGroup.ImageData {
 				if data.Dir == imageGroupDir {
 					if imageList, err := ih.listFiles(data.Dir); err == nil {
+						before := data.Current
 						ih.displayMultiple(channelID, data, imageList)
 						if len(imageList) <= data.Current {
 							if data.Repeat {
 								data.Current = 0
 							} else {
-								MessageSender.SendMessage(channelID, "Done! Completed all images for image block: "+data.Dir)
+								//Queued behind the images, rather than jumping ahead of them
+								queueOutbound(ih.session, Outbound{ChannelID: channelID, Content: "Done! Completed all images for image block: " + data.Dir, Priority: LowPriority})
 							}
 						}
 						ih.writeData()
+						ih.audit("next", before, data.Current)
 					}
 					return
 				}
 			}
-			MessageSender.SendMessage(channelID, "Specified image group does not exist!")
+			ih.session.SendMessage(channelID, "Specified image group does not exist!")
 		} else {
-			MessageSender.SendMessage(channelID, "No image groups on this channel!")
+			ih.session.SendMessage(channelID, "No image groups on this channel!")
 		}
 	}
 }
@@ -292,10 +552,22 @@ func (ih *ImageHandler) writeData() {
 		channelDataSlice = append(channelDataSlice, *channelData)
 	}
 
-	//..which we then byte-ify and write to disk
-	jsonBytes, err := json.Marshal(channelDataSlice)
-	if err == nil {
-		ioutil.WriteFile(imageDataFile, jsonBytes, 0644)
+	//..which we then save
+	if err := ih.store.Save(imageDocument, channelDataSlice); err != nil {
+		ih.log.Error("Error saving image data", "error", err)
+	}
+
+	ih.updateCompletions()
+}
+
+//updateCompletions publishes each channel's image blocks for slash command autocomplete
+func (ih *ImageHandler) updateCompletions() {
+	for channelID, channelData := range ih.imageMap {
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(channelData.ImageData))
+		for _, block := range channelData.ImageData {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: block.Dir, Value: block.Dir})
+		}
+		ih.completions.Set(channelID, choices)
 	}
 }
 
@@ -304,7 +576,7 @@ func (ih *ImageHandler) buildImageData(dir string, schedule string, hour int, mu
 	data := imageData{}
 	data.Dir = dir
 	data.Current = 0
-	data.Schedule = ih.scheduleEnum[schedule]
+	data.Schedule = schedule
 	data.Repeat = repeat
 	data.Multiplier = multiplier
 	data.Hour = hour
@@ -312,12 +584,13 @@ func (ih *ImageHandler) buildImageData(dir string, schedule string, hour int, mu
 	return &data, nil
 }
 
+//readerDir returns the directory image blocks are read from
+func (ih *ImageHandler) readerDir() string {
+	return dataPath("reader")
+}
+
 func (ih *ImageHandler) listFiles(dir string) ([]string, error) {
-	path, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-	path = filepath.FromSlash(path + "/reader/" + dir)
+	path := filepath.Join(ih.readerDir(), filepath.FromSlash(dir))
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, err
@@ -325,7 +598,7 @@ func (ih *ImageHandler) listFiles(dir string) ([]string, error) {
 
 	files := make([]string, 0)
 
-	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if !info.IsDir() {
 			files = append(files, path)
 		}
@@ -348,9 +621,13 @@ func (ih *ImageHandler) displayMultiple(channelID string, data *imageData, image
 	}
 
 	for i := 0; i < showCount; i++ {
-		MessageSender.SendFile(channelID, imageList[data.Current])
+		//Current counts what's been queued up to post, so anything left over goes out next time
+		if ih.stopping() {
+			break
+		}
+		queueOutbound(ih.session, Outbound{ChannelID: channelID, FilePath: imageList[data.Current], Priority: LowPriority})
 		data.Current++
 	}
-	
+
 	//Cleanup is handled by the respective callers, as they need to handle clean-up differently
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//makeImageBlock creates a reader directory with the specified number of files
+func makeImageBlock(t *testing.T, root string, dir string, count int) {
+	path := filepath.Join(root, "reader", dir)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for x := 0; x < count; x++ {
+		name := filepath.Join(path, string(rune('a'+x))+".png")
+		if err := ioutil.WriteFile(name, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImageStartNextAndList(t *testing.T) {
+	root := useTempDir(t)
+	makeImageBlock(t, root, "comic", 3)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewImageHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/i start comic daily 9 2 false")
+	harness.Say("chan", "user", "/i list")
+	assertContains(t, session.LastSent("chan"), "comic: Page 1 / 3, 2 per post, daily at 9:00")
+
+	harness.Say("chan", "user", "/i next comic")
+	files := make([]string, 0)
+	for _, call := range session.Calls() {
+		if call.Action == "file" {
+			files = append(files, filepath.Base(call.Content))
+		}
+	}
+	if strings.Join(files, ",") != "a.png,b.png" {
+		t.Errorf("expected first two pages to be posted, got %v", files)
+	}
+
+	harness.Say("chan", "user", "/i next comic")
+	if session.Count("file") != 3 {
+		t.Errorf("expected only the final page to be posted, got %d files", session.Count("file"))
+	}
+	assertContains(t, session.LastSent("chan"), "Done! Completed all images for image block: comic")
+}
+
+func TestImageStartValidatesInput(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewImageHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/i start comic fortnightly 9 2 false")
+	assertContains(t, session.LastSent("chan"), "fortnightly is not a valid schedule")
+
+	harness.Say("chan", "user", "/i start comic")
+	assertContains(t, session.LastSent("chan"), "Invalid start usage")
+
+	harness.Say("chan", "user", "/i next comic")
+	assertContains(t, session.LastSent("chan"), "No image groups on this channel!")
+
+	harness.Say("chan", "user", "/i list")
+	assertContains(t, session.LastSent("chan"), "No image block data exists for this channel!")
+}
+
+func TestImageNextUnknownGroup(t *testing.T) {
+	root := useTempDir(t)
+	makeImageBlock(t, root, "comic", 1)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewImageHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/i start comic manual 9 1 true")
+	harness.Say("chan", "user", "/i next manga")
+	assertContains(t, session.LastSent("chan"), "Specified image group does not exist!")
+
+	//Repeating blocks wrap back around rather than finishing
+	harness.Say("chan", "user", "/i next comic")
+	harness.Say("chan", "user", "/i list")
+	assertContains(t, session.LastSent("chan"), "comic: Page 1 / 1")
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultBackups = 5
+const defaultBackupEvery = time.Hour
+const backupDir = "backups"
+
+//backupTimeFormat sorts lexically in time order, and is fine enough that quick saves don't collide
+const backupTimeFormat = "20060102-150405.000000000"
+
+//JSONFileStore Keeps each document in its own json file, the way handlers always have
+//Files are replaced atomically, and a few older versions are kept in a backups directory
+type JSONFileStore struct {
+	dir     string
+	backups int
+	//backupEvery spaces backups out, so they reach back further than a quick run of saves
+	backupEvery time.Duration
+}
+
+//NewJSONFileStore creates a store which reads and writes files in dir
+func NewJSONFileStore(dir string) *JSONFileStore {
+	return &JSONFileStore{dir: dir, backups: defaultBackups, backupEvery: defaultBackupEvery}
+}
+
+func (js *JSONFileStore) path(name string) string {
+	return filepath.Join(js.dir, name+".json")
+}
+
+//Load reads the document's file. If it can't be read or decoded, eg it's empty or corrupt, the newest backup which decodes is used instead
+func (js *JSONFileStore) Load(name string, v interface{}) error {
+	fileData, err := ioutil.ReadFile(js.path(name))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err == nil && len(bytes.TrimSpace(fileData)) == 0 {
+		err = errors.New("file is empty")
+	} else if err == nil {
+		if err = decodeFresh(fileData, v); err == nil {
+			return nil
+		}
+	}
+
+	logger.Warn("Error reading document, looking for a backup", "path", js.path(name), "error", err)
+
+	backups, listErr := js.listBackups(name)
+	if listErr != nil {
+		return fmt.Errorf("reading %s: %v, and listing backups: %v", js.path(name), err, listErr)
+	}
+
+	for x := len(backups) - 1; x >= 0; x-- {
+		backupData, backupErr := ioutil.ReadFile(backups[x])
+		if backupErr == nil {
+			backupErr = decodeFresh(backupData, v)
+		}
+		if backupErr == nil {
+			//Whatever was saved after the backup was taken is gone, so make sure someone notices
+			age := "unknown"
+			if info, statErr := os.Stat(backups[x]); statErr == nil {
+				age = time.Since(info.ModTime()).Round(time.Second).String()
+			}
+			logger.Error("Recovered document from backup, losing any changes since", "document", name, "backup", backups[x], "age", age)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("reading %s: %v, and no valid backup was found", js.path(name), err)
+}
+
+//decodeFresh decodes into a new value before handing it over, so a failed attempt leaves v as it was
+func decodeFresh(data []byte, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return json.Unmarshal(data, v)
+	}
+
+	fresh := reflect.New(target.Elem().Type())
+	if err := json.Unmarshal(data, fresh.Interface()); err != nil {
+		return err
+	}
+	target.Elem().Set(fresh.Elem())
+
+	return nil
+}
+
+//LoadStrict reads the document's file, without falling back to a backup if it's corrupt
+func (js *JSONFileStore) LoadStrict(name string, v interface{}) error {
+	fileData, err := ioutil.ReadFile(js.path(name))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(fileData, v); err != nil {
+		return fmt.Errorf("reading %s: %v", js.path(name), describeJSONError(fileData, err))
+	}
+
+	return nil
+}
+
+//Save backs up the current file, then atomically replaces it
+func (js *JSONFileStore) Save(name string, v interface{}) error {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	//A failed backup shouldn't stop us saving the newer data
+	if err = js.backup(name); err != nil {
+		logger.Error("Error backing up document", "document", name, "error", err)
+	}
+
+	return writeFileAtomic(js.path(name), jsonBytes, 0644)
+}
+
+//Close Nothing to do here
+func (js *JSONFileStore) Close() error {
+	return nil
+}
+
+//backup copies the current file into the backups directory, then removes all but the newest backups
+//Nothing is backed up until the newest backup is backupEvery old
+func (js *JSONFileStore) backup(name string) error {
+	if js.backups < 0 {
+		return nil
+	}
+
+	backups, err := js.listBackups(name)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 && js.backupEvery > 0 {
+		if info, err := os.Stat(backups[len(backups)-1]); err == nil && time.Since(info.ModTime()) < js.backupEvery {
+			return nil
+		}
+	}
+
+	fileData, err := ioutil.ReadFile(js.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	//Never rotate a good backup out in favour of a corrupt file
+	if !json.Valid(fileData) {
+		return nil
+	}
+
+	if err = os.MkdirAll(filepath.Join(js.dir, backupDir), 0755); err != nil {
+		return err
+	}
+
+	backupPath := filepath.Join(js.dir, backupDir, name+"."+time.Now().UTC().Format(backupTimeFormat)+".json")
+	if err = writeFileAtomic(backupPath, fileData, 0644); err != nil {
+		return err
+	}
+
+	if backups, err = js.listBackups(name); err != nil {
+		return err
+	}
+
+	for len(backups) > js.backups {
+		if err = os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+//listBackups returns paths of the document's backups, oldest first
+func (js *JSONFileStore) listBackups(name string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(js.dir, backupDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	backups := make([]string, 0)
+	for _, entry := range entries {
+		//Matching the dot too keeps documents whose names share a prefix apart
+		if strings.HasPrefix(entry.Name(), name+".") && strings.HasSuffix(entry.Name(), ".json") {
+			backups = append(backups, filepath.Join(js.dir, backupDir, entry.Name()))
+		}
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+//writeFileAtomic writes to a temporary file and renames it over path, so readers see the old or new file but never part of one
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	//Sync the directory too, otherwise the rename itself may not survive a crash
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
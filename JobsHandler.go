@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//JobsHandler lists the jobs every handler has scheduled
+type JobsHandler struct {
+	handlerLoop
+	session   Session
+	scheduler *Scheduler
+	matcher   regexp.Regexp
+}
+
+const jobsCommand = "/jobs"
+
+//NewJobsHandler creates a handler which reports on the provided scheduler
+func NewJobsHandler(session Session, scheduler *Scheduler) *JobsHandler {
+	return &JobsHandler{session: session, scheduler: scheduler}
+}
+
+//Init compiles our regexp and spins up our channel handling
+func (jh *JobsHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	jh.matcher = *regexp.MustCompile(`^` + jobsCommand + `\s*$`)
+
+	jh.listen(ctx, jh.GetName(), m, jh.handleMessage)
+}
+
+//GetName returns our name
+func (jh *JobsHandler) GetName() string {
+	return "Jobs Handler"
+}
+
+//Commands returns the commands owned by this handler
+func (jh *JobsHandler) Commands() []Command {
+	return []Command{{Prefix: jobsCommand, Description: "List upcoming scheduled jobs", Capability: "jobs.view"}}
+}
+
+//Help Gets info about this handler
+func (jh *JobsHandler) Help() string {
+	return jobsCommand + " : Jobs - List upcoming scheduled jobs"
+}
+
+func (jh *JobsHandler) handleMessage(m *discordgo.MessageCreate) {
+	if !jh.matcher.MatchString(m.Content) {
+		return
+	}
+
+	upcoming := jh.scheduler.Upcoming()
+	if len(upcoming) == 0 {
+		jh.session.SendMessage(m.ChannelID, "No jobs are scheduled")
+		return
+	}
+
+	now := time.Now()
+	message := "Upcoming jobs:\n"
+	for _, job := range upcoming {
+		message += job.Owner + " - " + job.Name + " (" + job.Schedule + "): " +
+			job.Next.Format("Mon Jan 2 15:04 MST") + ", in " + job.Next.Sub(now).Round(time.Second).String() + "\n"
+	}
+
+	jh.session.SendMessage(m.ChannelID, message)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//LogLevel is how important a log line is, lines below the configured level are skipped
+type LogLevel int
+
+const (
+	DebugLevel LogLevel = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var logLevelNames = map[LogLevel]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+
+const defaultLogMaxSize = 10
+const defaultLogMaxFiles = 5
+
+//redacted replaces secrets wherever they'd otherwise be logged
+const redacted = "[redacted]"
+
+//LogConfiguration Configures where logs go and how they look
+type LogConfiguration struct {
+	//Level is the least important level logged: debug, info, warn or error
+	Level string `json:"Level"`
+	//Format is logfmt or json
+	Format string `json:"Format"`
+	//File is written to instead of stdout, if set
+	File string `json:"File"`
+	//MaxSize is how many megabytes File grows to before it's rotated
+	MaxSize int `json:"MaxSize"`
+	//MaxFiles is how many rotated files are kept
+	MaxFiles int `json:"MaxFiles"`
+}
+
+//logOutput is shared by a logger and everything derived from it
+type logOutput struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	level   LogLevel
+	json    bool
+	secrets []string
+}
+
+type logField struct {
+	key   string
+	value interface{}
+}
+
+//Logger Writes leveled, structured log lines, each tagged with the logger's context
+//A nil logger logs through the global one
+type Logger struct {
+	output *logOutput
+	fields []logField
+}
+
+var logger = NewLogger(os.Stdout, InfoLevel, false)
+
+//NewLogger creates a logger writing lines at or above the level to the writer, as json or logfmt
+func NewLogger(writer io.Writer, level LogLevel, asJSON bool) *Logger {
+	return &Logger{output: &logOutput{writer: writer, level: level, json: asJSON}}
+}
+
+//NewConfiguredLogger builds a logger from configuration, falling back to defaults for anything unset
+func NewConfiguredLogger(config LogConfiguration) (*Logger, error) {
+	level, asJSON, err := parseLogConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var writer io.Writer = os.Stdout
+	if config.File != "" {
+		maxSize := config.MaxSize
+		if maxSize <= 0 {
+			maxSize = defaultLogMaxSize
+		}
+		maxFiles := config.MaxFiles
+		if maxFiles <= 0 {
+			maxFiles = defaultLogMaxFiles
+		}
+
+		file, err := openRotatingFile(config.File, int64(maxSize)*1024*1024, maxFiles)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	}
+
+	return NewLogger(writer, level, asJSON), nil
+}
+
+//parseLogConfiguration checks the level and format, returning the level and whether to write json
+func parseLogConfiguration(config LogConfiguration) (LogLevel, bool, error) {
+	level := InfoLevel
+	if config.Level != "" {
+		var ok bool
+		if level, ok = parseLogLevel(config.Level); !ok {
+			return level, false, fmt.Errorf("unknown log level %s, expected debug, info, warn or error", config.Level)
+		}
+	}
+
+	switch strings.ToLower(config.Format) {
+	case "", "logfmt":
+		return level, false, nil
+	case "json":
+		return level, true, nil
+	}
+
+	return level, false, fmt.Errorf("unknown log format %s, expected logfmt or json", config.Format)
+}
+
+func parseLogLevel(name string) (LogLevel, bool) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, true
+		}
+	}
+
+	return InfoLevel, false
+}
+
+func (l *Logger) or() *Logger {
+	if l == nil {
+		return logger
+	}
+
+	return l
+}
+
+//With returns a logger which adds the key value pairs to every line
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	l = l.or()
+	fields := make([]logField, len(l.fields), len(l.fields)+len(keyvals)/2)
+	copy(fields, l.fields)
+
+	return &Logger{output: l.output, fields: appendFields(fields, keyvals)}
+}
+
+//ForMessage returns a logger tagged with where the message came from and who sent it
+func (l *Logger) ForMessage(m *discordgo.MessageCreate) *Logger {
+	keyvals := []interface{}{"guild", m.GuildID, "channel", m.ChannelID}
+	if m.Author != nil {
+		keyvals = append(keyvals, "user", m.Author.ID)
+	}
+
+	return l.With(keyvals...)
+}
+
+//Redact stops the secret from ever appearing in our logs
+func (l *Logger) Redact(secret string) {
+	if secret == "" {
+		return
+	}
+
+	output := l.or().output
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	output.secrets = append(output.secrets, secret)
+}
+
+//Reconfigure switches the logger, and every logger derived from it, to writing the way other does
+//Secrets we've already been told about stay redacted
+func (l *Logger) Reconfigure(other *Logger) {
+	output := l.or().output
+	output.mutex.Lock()
+	previous := output.writer
+	output.writer = other.output.writer
+	output.level = other.output.level
+	output.json = other.output.json
+	output.mutex.Unlock()
+
+	if file, ok := previous.(*rotatingFile); ok && previous != output.writer {
+		file.Close()
+	}
+}
+
+//Enabled reports whether lines at the level are logged, for skipping work that's only needed for them
+func (l *Logger) Enabled(level LogLevel) bool {
+	output := l.or().output
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	return level >= output.level
+}
+
+//Debug logs detail that's only useful when chasing down a problem
+func (l *Logger) Debug(message string, keyvals ...interface{}) {
+	l.log(DebugLevel, message, keyvals)
+}
+
+//Info logs normal goings on
+func (l *Logger) Info(message string, keyvals ...interface{}) {
+	l.log(InfoLevel, message, keyvals)
+}
+
+//Warn logs something odd which we've worked around
+func (l *Logger) Warn(message string, keyvals ...interface{}) {
+	l.log(WarnLevel, message, keyvals)
+}
+
+//Error logs something which failed
+func (l *Logger) Error(message string, keyvals ...interface{}) {
+	l.log(ErrorLevel, message, keyvals)
+}
+
+func (l *Logger) log(level LogLevel, message string, keyvals []interface{}) {
+	l = l.or()
+	if !l.Enabled(level) {
+		return
+	}
+
+	fields := []logField{{"time", time.Now().Format(time.RFC3339)}, {"level", logLevelNames[level]}, {"msg", message}}
+	fields = append(fields, l.fields...)
+	fields = appendFields(fields, keyvals)
+
+	output := l.output
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	var line string
+	if output.json {
+		line = jsonLine(fields)
+	} else {
+		line = logfmtLine(fields)
+	}
+	for _, secret := range output.secrets {
+		line = strings.Replace(line, secret, redacted, -1)
+	}
+
+	io.WriteString(output.writer, line+"\n")
+}
+
+//appendFields pairs up the keys and values. A value without a key is logged under "extra"
+func appendFields(fields []logField, keyvals []interface{}) []logField {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fields = append(fields, logField{"extra", keyvals[i]})
+			break
+		}
+		fields = append(fields, logField{fmt.Sprint(keyvals[i]), keyvals[i+1]})
+	}
+
+	return fields
+}
+
+func logValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return fmt.Sprint(value)
+}
+
+func logfmtLine(fields []logField) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value := logValue(field.value)
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = strconv.Quote(value)
+		}
+		parts = append(parts, field.key+"="+value)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func jsonLine(fields []logField) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		key, _ := json.Marshal(field.key)
+		value, _ := json.Marshal(logValue(field.value))
+		parts = append(parts, string(key)+":"+string(value))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+//rotatingFile Is a log file which is moved aside once it gets too big, keeping a few old ones around
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+//Write appends to the file, rotating first if this would take it over the limit. Callers serialise writes
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+//Close closes the current file
+func (rf *rotatingFile) Close() error {
+	return rf.file.Close()
+}
+
+//rotate shuffles log.1 to log.2 and so on, dropping the oldest, then starts a fresh log
+func (rf *rotatingFile) rotate() error {
+	rf.file.Close()
+
+	os.Remove(rf.path + "." + strconv.Itoa(rf.maxFiles))
+	for x := rf.maxFiles - 1; x > 0; x-- {
+		os.Rename(rf.path+"."+strconv.Itoa(x), rf.path+"."+strconv.Itoa(x+1))
+	}
+	if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+//discordLog routes discordgo's own logging through ours
+func discordLog(level int, caller int, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	switch level {
+	case discordgo.LogError:
+		logger.Error(message, "component", "discordgo")
+	case discordgo.LogWarning:
+		logger.Warn(message, "component", "discordgo")
+	case discordgo.LogInformational:
+		logger.Info(message, "component", "discordgo")
+	default:
+		logger.Debug(message, "component", "discordgo")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestLoggerWritesLogfmtWithContext(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLogger(&out, InfoLevel, false).With("handler", "Release Handler")
+	message := &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: "guild", ChannelID: "chan", Author: &discordgo.User{ID: "123"}}}
+
+	log.ForMessage(message).Error("Error saving release data", "error", errors.New("disk full"))
+
+	line := out.String()
+	assertContains(t, line, `level=error msg="Error saving release data" handler="Release Handler" guild=guild channel=chan user=123 error="disk full"`)
+}
+
+func TestLoggerSkipsLowerLevels(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLogger(&out, WarnLevel, false)
+
+	log.Info("quiet")
+	log.Debug("quieter")
+	log.Warn("loud")
+
+	if lines := strings.Count(out.String(), "\n"); lines != 1 {
+		t.Errorf("expected only the warning, got %q", out.String())
+	}
+}
+
+func TestLoggerWritesJSON(t *testing.T) {
+	var out bytes.Buffer
+	NewLogger(&out, InfoLevel, true).With("handler", "Image Handler").Info("Reading saved image data", "blocks", 3)
+
+	var line map[string]string
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("expected a json line, got %q: %v", out.String(), err)
+	}
+	if line["msg"] != "Reading saved image data" || line["handler"] != "Image Handler" || line["blocks"] != "3" || line["level"] != "info" {
+		t.Errorf("unexpected line %v", line)
+	}
+}
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLogger(&out, InfoLevel, false)
+	log.Redact("sekrit-token")
+
+	log.With("token", "sekrit-token").Error("Error opening Discord session", "error", errors.New("bad auth Bot sekrit-token"))
+
+	if strings.Contains(out.String(), "sekrit") {
+		t.Errorf("expected the token to be redacted, got %q", out.String())
+	}
+	assertContains(t, out.String(), "token="+redacted)
+}
+
+func TestRotatingFileKeepsOldLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskhard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "diskhard.log")
+	file, err := openRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, expected := range map[string]string{"": "fourth line\n", ".1": "third line\n", ".2": "second line\n"} {
+		data, err := ioutil.ReadFile(path + name)
+		if err != nil || string(data) != expected {
+			t.Errorf("expected %s to hold %q, got %q %v", path+name, expected, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 old logs to be kept")
+	}
+}
+
+func TestConfiguredLoggerRejectsUnknownLevel(t *testing.T) {
+	if _, err := NewConfiguredLogger(LogConfiguration{Level: "chatty"}); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+}
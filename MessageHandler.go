@@ -1,10 +1,173 @@
 package main
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
 
 //MessageHandler Defines the functions all handlers should implement
 type MessageHandler interface {
-	Init(m chan *discordgo.MessageCreate)
+	Init(ctx context.Context, m chan *discordgo.MessageCreate)
 	GetName() string
 	Help() string
+	//Stop blocks until the handler has finished up, after it's been sent nil or its context is cancelled
+	Stop()
+}
+
+//loggedHandler Is implemented by handlers which tag their logs with what they're working on
+type loggedHandler interface {
+	useLogger(log *Logger)
+}
+
+//handlerLoop Runs a handler's messages and scheduled tasks on one goroutine
+//Handlers embed it, which provides their Stop method
+type handlerLoop struct {
+	ctx     context.Context
+	tasks   chan func()
+	stopped chan struct{}
+	//log is tagged with the handler, and the guild, channel and user of the message being handled
+	//Only use it from the handler's own goroutine (or Init)
+	log  *Logger
+	base *Logger
+	name string
+	//message is the one being handled, or the admin request doing work, which changes are audited against
+	message *discordgo.MessageCreate
+}
+
+//useLogger sets the logger the handler's lines are written through
+func (hl *handlerLoop) useLogger(log *Logger) {
+	hl.log = log
+	hl.base = log
+}
+
+//listen handles messages until a nil one arrives or ctx is cancelled. Tasks queued for the handler run between messages
+//The supervisor restarts the loop if it panics
+func (hl *handlerLoop) listen(ctx context.Context, name string, m chan *discordgo.MessageCreate, handle func(*discordgo.MessageCreate)) {
+	hl.ctx = ctx
+	hl.name = name
+	hl.tasks = make(chan func())
+	hl.stopped = make(chan struct{})
+
+	go func() {
+		defer close(hl.stopped)
+		current := ""
+		if !supervisor.Supervise(ctx, name, &current, func() { hl.loop(ctx, m, handle, &current) }, hl.tasks) {
+			hl.discard(ctx, m)
+		}
+	}()
+}
+
+func (hl *handlerLoop) loop(ctx context.Context, m chan *discordgo.MessageCreate, handle func(*discordgo.MessageCreate), current *string) {
+	for {
+		*current = ""
+		hl.log = hl.base
+		hl.message = nil
+		select {
+		case message := <-m:
+			if message == nil {
+				return
+			}
+			*current = describeMessage(message)
+			hl.log = hl.base.ForMessage(message)
+			hl.message = message
+			handle(message)
+		case task := <-hl.tasks:
+			*current = "a scheduled job"
+			task()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//discard empties the queue of a disabled handler, so nothing waiting on it gets stuck
+//Jobs are still run, as the scheduler skips the handler's own work itself
+func (hl *handlerLoop) discard(ctx context.Context, m chan *discordgo.MessageCreate) {
+	for {
+		select {
+		case message := <-m:
+			if message == nil {
+				return
+			}
+		case task := <-hl.tasks:
+			task()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//errHandlerStopped is returned for work sent to a handler which has stopped, or which failed partway through
+var errHandlerStopped = errors.New("handler isn't running")
+
+//do runs work on the handler's goroutine and waits for it, so it can safely use the handler's data from elsewhere
+func (hl *handlerLoop) do(work func()) error {
+	if hl.tasks == nil {
+		return errHandlerStopped
+	}
+
+	finished := false
+	done := make(chan struct{})
+	task := func() {
+		//Closed even if work panics, the supervisor deals with that
+		defer close(done)
+		work()
+		finished = true
+	}
+
+	select {
+	case hl.tasks <- task:
+	case <-hl.stopped:
+		return errHandlerStopped
+	}
+
+	select {
+	case <-done:
+	case <-hl.stopped:
+		return errHandlerStopped
+	}
+	if !finished {
+		return errHandlerStopped
+	}
+
+	return nil
+}
+
+//doAs runs work like do, auditing any changes it makes against the message
+func (hl *handlerLoop) doAs(m *discordgo.MessageCreate, work func()) error {
+	return hl.do(func() {
+		hl.message = m
+		work()
+	})
+}
+
+//audit records a change made by the message being handled in the audit log
+//before and after are the changed values, either of which can be nil if something was added or removed
+func (hl *handlerLoop) audit(action string, before interface{}, after interface{}) {
+	recordAudit(hl.name, hl.message, action, before, after)
+}
+
+//Stop waits for whatever the handler is in the middle of, including any saves, to finish
+func (hl *handlerLoop) Stop() {
+	if hl.stopped != nil {
+		<-hl.stopped
+	}
+}
+
+//stopping reports whether we've given up waiting on the handler, so long running work should stop early
+func (hl *handlerLoop) stopping() bool {
+	return hl.ctx != nil && hl.ctx.Err() != nil
+}
+
+//describeMessage summarises a message for logs
+func describeMessage(m *discordgo.MessageCreate) string {
+	author := "unknown"
+	if m.Author != nil {
+		author = m.Author.ID
+	}
+
+	return fmt.Sprintf("message %s from %s in %s: %q", m.ID, author, m.ChannelID, m.Content)
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+//maxMessageLength is the most characters discord accepts in a single message
+const maxMessageLength = 2000
+
+const codeFence = "```"
+
+//splitMessage breaks content into chunks of at most limit characters, splitting between lines where it can
+//A code block still open at the end of a chunk is closed there and reopened at the start of the next, so tables keep their formatting
+func splitMessage(content string, limit int) []string {
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	chunks := make([]string, 0)
+	chunk := make([]string, 0)
+	length := 0
+	//fresh is set while the chunk holds nothing but the reopened code block
+	fresh := true
+	//fence opened the code block we're in, if any
+	fence := ""
+
+	flush := func() {
+		text := strings.Join(chunk, "\n")
+		if fence != "" {
+			text += "\n" + codeFence
+		}
+		chunks = append(chunks, text)
+
+		chunk, length, fresh = chunk[:0], 0, true
+		if fence != "" {
+			chunk = append(chunk, fence)
+			length = utf8.RuneCountInString(fence)
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		toggles := strings.Count(line, codeFence)%2 == 1
+
+		//Leave room to close the code block, while we're in one
+		room := limit
+		if fence != "" || toggles {
+			room -= len(codeFence) + 1
+		}
+
+		for {
+			space := room - length
+			if len(chunk) > 0 {
+				space--
+			}
+			if utf8.RuneCountInString(line) <= space {
+				break
+			}
+
+			if !fresh {
+				flush()
+				continue
+			}
+
+			//The line's too long for any chunk, so it has to be broken up
+			runes := []rune(line)
+			chunk = append(chunk, string(runes[:space]))
+			line = string(runes[space:])
+			flush()
+		}
+
+		if len(chunk) > 0 {
+			length++
+		}
+		chunk = append(chunk, line)
+		length += utf8.RuneCountInString(line)
+		fresh = false
+
+		if toggles {
+			if fence == "" {
+				fence = codeFence
+				if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, codeFence) {
+					fence = strings.Fields(trimmed)[0]
+				}
+			} else {
+				fence = ""
+			}
+		}
+	}
+
+	if !fresh {
+		chunks = append(chunks, strings.Join(chunk, "\n"))
+	}
+
+	return chunks
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessageOnLines(t *testing.T) {
+	chunks := splitMessage("first line\nsecond line\nthird line", 25)
+
+	if len(chunks) != 2 || chunks[0] != "first line\nsecond line" || chunks[1] != "third line" {
+		t.Errorf("unexpected chunks %q", chunks)
+	}
+}
+
+func TestSplitMessageReopensCodeBlocks(t *testing.T) {
+	lines := []string{"Reminders:", "```md"}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, "[ row "+strings.Repeat("x", 10)+" ]")
+	}
+	lines = append(lines, "```", "Done")
+	chunks := splitMessage(strings.Join(lines, "\n"), 60)
+
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %q", chunks)
+	}
+	for i, chunk := range chunks {
+		if utf8.RuneCountInString(chunk) > 60 {
+			t.Errorf("chunk %d is too long: %q", i, chunk)
+		}
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Errorf("chunk %d leaves a code block open: %q", i, chunk)
+		}
+		if i > 0 && i < len(chunks)-1 && !strings.HasPrefix(chunk, "```md\n") {
+			t.Errorf("chunk %d should reopen the code block: %q", i, chunk)
+		}
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "```\nDone") {
+		t.Errorf("expected the block to close in the last chunk, got %q", chunks[len(chunks)-1])
+	}
+}
+
+func TestSplitMessageBreaksLongLines(t *testing.T) {
+	line := strings.Repeat("a", 50)
+	chunks := splitMessage(line, 20)
+
+	if strings.Join(chunks, "") != line {
+		t.Errorf("expected the line to survive being split, got %q", chunks)
+	}
+	for _, chunk := range chunks {
+		if utf8.RuneCountInString(chunk) > 20 {
+			t.Errorf("chunk is too long: %q", chunk)
+		}
+	}
+}
@@ -1,98 +1,136 @@
 package main
 
 import (
-	"fmt"
 	"os"
-	"sync"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+//Messager Sends to discord through a per-channel outbound queue, retrying anything that fails along the way
 type Messager struct {
-	session      *discordgo.Session
-	messageMutex sync.Mutex
+	session  *discordgo.Session
+	outbound *OutboundQueue
 }
 
 func (m *Messager) Init(sess *discordgo.Session) {
 	m.session = sess
+	m.outbound = NewOutboundQueue(m.deliver)
 }
 
-func (m *Messager) SendMessage(channelID string, message string) (*discordgo.Message, error) {
-	m.messageMutex.Lock()
-	mess, err := m.session.ChannelMessageSend(channelID, message)
-	m.messageMutex.Unlock()
-	if err != nil {
-		fmt.Println("Error sending message", err)
+//Queue sends the message or file in the background
+func (m *Messager) Queue(outbound Outbound) {
+	m.outbound.Queue(outbound)
+}
+
+//Wait blocks until every queued message has gone
+func (m *Messager) Wait() {
+	if m.outbound != nil {
+		m.outbound.Wait()
 	}
+}
 
-	return mess, err
+//SendMessage sends the message, split into several if it's too long for one
+func (m *Messager) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	return sendQueued(m, Outbound{ChannelID: channelID, Content: message, Priority: NormalPriority})
 }
 
+//SendFile uploads the file. Uploads are bulky, so wait behind everything else in the channel
 func (m *Messager) SendFile(channelID string, filePath string) error {
-	if img, err := os.Open(filePath); err == nil {
-		dgoFiles := make([]*discordgo.File, 0)
-		dgoFiles = append(dgoFiles, &discordgo.File{
-			Name:   filePath,
-			Reader: img,
-		})
-
-		m.messageMutex.Lock()
-		_, err := m.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-			Files: dgoFiles,
-		})
-		m.messageMutex.Unlock()
-
-		if err != nil {
-			fmt.Println("Error sending image: ", err)
-			return err
-		}
-	} else {
-		return err
-	}
+	_, err := sendQueued(m, Outbound{ChannelID: channelID, FilePath: filePath, Priority: LowPriority})
+	return err
+}
 
-	return nil
+//SendComplex sends a message with embeds and components
+func (m *Messager) SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	return sendQueued(m, Outbound{ChannelID: channelID, Complex: message, Priority: NormalPriority})
 }
 
+//DeleteMessage removes the message in the background, retrying on the channel's queue rather than holding up the handler
 func (m *Messager) DeleteMessage(channelID string, messageID string) error {
-	m.messageMutex.Lock()
-	err := m.session.ChannelMessageDelete(channelID, messageID)
-	m.messageMutex.Unlock()
-	if err != nil {
-		fmt.Println("Error removing message", err)
+	//Slash commands don't have a message of their own to remove
+	if messageID == "" {
+		return nil
 	}
 
-	return err
+	m.Queue(Outbound{ChannelID: channelID, Priority: NormalPriority, Request: func() error {
+		return trackRequest("delete", m.session.ChannelMessageDelete(channelID, messageID))
+	}})
+	return nil
 }
 
+//EditMessage edits the message in the background, after anything already waiting to be sent to the channel
 func (m *Messager) EditMessage(channelID string, messageID string, newMessage string) error {
-	m.messageMutex.Lock()
-	_, err := m.session.ChannelMessageEdit(channelID, messageID, newMessage)
-	m.messageMutex.Unlock()
-	if err != nil {
-		fmt.Println("Error editing message", err)
-	}
+	m.Queue(Outbound{ChannelID: channelID, Priority: NormalPriority, Request: func() error {
+		_, err := m.session.ChannelMessageEdit(channelID, messageID, newMessage)
+		return trackRequest("edit", err)
+	}})
+	return nil
+}
 
+//PinMessage waits to hear whether the pin worked, as callers keep track of what's pinned
+func (m *Messager) PinMessage(channelID string, messageID string) error {
+	_, err := sendQueued(m, Outbound{ChannelID: channelID, Priority: NormalPriority, Request: func() error {
+		return trackRequest("pin", m.session.ChannelMessagePin(channelID, messageID))
+	}})
 	return err
 }
 
-func (m *Messager) PinMessage(channelID string, messageID string) error {
-	m.messageMutex.Lock()
-	err := m.session.ChannelMessagePin(channelID, messageID)
-	m.messageMutex.Unlock()
-	if err != nil {
-		fmt.Println("Error pinning message", err)
+//React reacts in the background
+func (m *Messager) React(channelID string, messageID string, reaction string) error {
+	m.Queue(Outbound{ChannelID: channelID, Priority: NormalPriority, Request: func() error {
+		return trackRequest("react", m.session.MessageReactionAdd(channelID, messageID, reaction))
+	}})
+	return nil
+}
+
+func (m *Messager) UserGuilds() ([]*discordgo.UserGuild, error) {
+	return m.session.UserGuilds(100, "", "")
+}
+
+func (m *Messager) GuildEmojis(guildID string) ([]*discordgo.Emoji, error) {
+	return m.session.GuildEmojis(guildID)
+}
+
+func (m *Messager) UserChannelPermissions(userID string, channelID string) (int64, error) {
+	return m.session.UserChannelPermissions(userID, channelID)
+}
+
+//ChannelGuild checks the state first, only asking discord about channels we haven't seen
+func (m *Messager) ChannelGuild(channelID string) (string, error) {
+	if m.session.State != nil {
+		if channel, err := m.session.State.Channel(channelID); err == nil {
+			return channel.GuildID, nil
+		}
 	}
 
-	return err
+	channel, err := m.session.Channel(channelID)
+	if err = trackRequest("channel", err); err != nil {
+		return "", err
+	}
+
+	return channel.GuildID, nil
 }
 
-func (m *Messager) React(channelID string, messageID string, reaction string) error {
-	m.messageMutex.Lock()
-	err := m.session.MessageReactionAdd(channelID, messageID, reaction)
-	m.messageMutex.Unlock()
+//deliver makes a single attempt at sending the outbound message
+func (m *Messager) deliver(outbound Outbound) (*discordgo.Message, error) {
+	if outbound.Complex != nil {
+		message, err := m.session.ChannelMessageSendComplex(outbound.ChannelID, outbound.Complex)
+		return message, trackRequest("send", err)
+	}
+	if outbound.FilePath == "" {
+		message, err := m.session.ChannelMessageSend(outbound.ChannelID, outbound.Content)
+		return message, trackRequest("send", err)
+	}
+
+	//Opened per attempt, as a failed upload may have read some of it already
+	img, err := os.Open(outbound.FilePath)
 	if err != nil {
-		fmt.Println("Error reacting to message", err)
+		return nil, err
 	}
+	defer img.Close()
 
-	return err
+	message, err := m.session.ChannelMessageSendComplex(outbound.ChannelID, &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: outbound.FilePath, Reader: img}},
+	})
+	return message, trackRequest("upload", err)
 }
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//The metrics we expose, see registerMetrics for what each one means
+const (
+	metricMessagesReceived = "diskhard_messages_received_total"
+	metricCommandsHandled  = "diskhard_commands_handled_total"
+	metricDiscordRequests  = "diskhard_discord_requests_total"
+	metricDiscordFailures  = "diskhard_discord_request_failures_total"
+	metricQueueDepth       = "diskhard_handler_queue_depth"
+	metricQueueDropped     = "diskhard_handler_queue_dropped_total"
+	metricJobRuns          = "diskhard_job_runs_total"
+	metricJobLateness      = "diskhard_job_lateness_seconds"
+	metricStoreWrites      = "diskhard_store_write_duration_seconds"
+	metricGatewayConnected = "diskhard_gateway_connected"
+	metricGatewayConnects  = "diskhard_gateway_connects_total"
+	metricGatewayLatency   = "diskhard_gateway_heartbeat_latency_seconds"
+)
+
+type metricKind string
+
+const (
+	counterMetric   metricKind = "counter"
+	gaugeMetric     metricKind = "gauge"
+	histogramMetric metricKind = "histogram"
+)
+
+//MetricsConfiguration Configures the optional prometheus listener
+type MetricsConfiguration struct {
+	//Listen is the address to serve /metrics on, eg 127.0.0.1:9090. Metrics aren't served if unset
+	Listen string `json:"Listen"`
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    metricKind
+	labels  []string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labels []string
+	value  float64
+	//counts, sum and count are only used by histograms. counts are per bucket, not cumulative
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+//Metrics Holds our counters, gauges and histograms, and writes them out in the prometheus text format
+type Metrics struct {
+	mutex      sync.Mutex
+	families   []*metricFamily
+	byName     map[string]*metricFamily
+	collectors []func()
+}
+
+var metrics = registerMetrics(NewMetrics())
+
+//NewMetrics creates an empty set of metrics
+func NewMetrics() *Metrics {
+	return &Metrics{byName: make(map[string]*metricFamily)}
+}
+
+func registerMetrics(m *Metrics) *Metrics {
+	latencyBuckets := []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 30, 60, 300}
+	m.Register(metricMessagesReceived, counterMetric, "Messages seen from discord and the console", nil)
+	m.Register(metricCommandsHandled, counterMetric, "Commands passed to handlers", nil, "handler", "command", "subcommand")
+	m.Register(metricDiscordRequests, counterMetric, "Requests made to discord, including retries", nil, "action")
+	m.Register(metricDiscordFailures, counterMetric, "Requests to discord which failed", nil, "action")
+	m.Register(metricQueueDepth, gaugeMetric, "Events waiting in each handler's queue", nil, "handler")
+	m.Register(metricQueueDropped, counterMetric, "Events each handler's queue has discarded", nil, "handler")
+	m.Register(metricJobRuns, counterMetric, "Scheduled job runs, late ones are catch ups from while we were offline", nil, "handler", "job", "late")
+	m.Register(metricJobLateness, histogramMetric, "How long after it was due a scheduled job started", latencyBuckets, "handler", "job")
+	m.Register(metricStoreWrites, histogramMetric, "How long saving each document took", []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}, "document")
+	m.Register(metricGatewayConnected, gaugeMetric, "1 while connected to the discord gateway", nil)
+	m.Register(metricGatewayConnects, counterMetric, "Connections made to the discord gateway", nil)
+	m.Register(metricGatewayLatency, gaugeMetric, "Time the last gateway heartbeat took to be acknowledged", nil)
+
+	return m
+}
+
+//Register adds a metric. Buckets are only used by histograms
+func (m *Metrics) Register(name string, kind metricKind, help string, buckets []float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	family := &metricFamily{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*metricSeries)}
+	m.families = append(m.families, family)
+	m.byName[name] = family
+}
+
+//OnScrape runs collect before metrics are written, for gauges that are cheaper to read than keep up to date
+func (m *Metrics) OnScrape(collect func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.collectors = append(m.collectors, collect)
+}
+
+//Inc adds one to the counter
+func (m *Metrics) Inc(name string, labelValues ...string) {
+	m.Add(name, 1, labelValues...)
+}
+
+//Add adds the value to the counter or gauge
+func (m *Metrics) Add(name string, value float64, labelValues ...string) {
+	m.update(name, labelValues, func(family *metricFamily, series *metricSeries) { series.value += value })
+}
+
+//Set sets the gauge, or a counter we're tracking elsewhere
+func (m *Metrics) Set(name string, value float64, labelValues ...string) {
+	m.update(name, labelValues, func(family *metricFamily, series *metricSeries) { series.value = value })
+}
+
+//Observe records a value in the histogram
+func (m *Metrics) Observe(name string, value float64, labelValues ...string) {
+	m.update(name, labelValues, func(family *metricFamily, series *metricSeries) {
+		series.sum += value
+		series.count++
+		for x, bound := range family.buckets {
+			if value <= bound {
+				series.counts[x]++
+				return
+			}
+		}
+	})
+}
+
+func (m *Metrics) update(name string, labelValues []string, apply func(*metricFamily, *metricSeries)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	family, ok := m.byName[name]
+	if !ok || len(labelValues) != len(family.labels) {
+		logger.Warn("Unknown metric or wrong labels", "metric", name, "labels", strings.Join(labelValues, ","))
+		return
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{labels: labelValues, counts: make([]uint64, len(family.buckets))}
+		family.series[key] = series
+	}
+	apply(family, series)
+}
+
+//Value returns the counter or gauge's current value, or 0 if it hasn't been set
+func (m *Metrics) Value(name string, labelValues ...string) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if family, ok := m.byName[name]; ok {
+		if series, ok := family.series[strings.Join(labelValues, "\xff")]; ok {
+			return series.value
+		}
+	}
+
+	return 0
+}
+
+//WriteTo writes every metric in the prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	collectors := m.collectors
+	m.mutex.Unlock()
+	for _, collect := range collectors {
+		collect()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var out strings.Builder
+	for _, family := range m.families {
+		out.WriteString("# HELP " + family.name + " " + family.help + "\n")
+		out.WriteString("# TYPE " + family.name + " " + string(family.kind) + "\n")
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		//Unlabelled metrics always have a value, even before anything has happened
+		if len(keys) == 0 && len(family.labels) == 0 {
+			family.series[""] = &metricSeries{counts: make([]uint64, len(family.buckets))}
+			keys = append(keys, "")
+		}
+
+		for _, key := range keys {
+			series := family.series[key]
+			labels := formatLabels(family.labels, series.labels)
+			if family.kind != histogramMetric {
+				out.WriteString(family.name + wrapLabels(labels) + " " + formatMetricValue(series.value) + "\n")
+				continue
+			}
+
+			cumulative := uint64(0)
+			for x, bound := range family.buckets {
+				cumulative += series.counts[x]
+				out.WriteString(family.name + "_bucket" + wrapLabels(append(labels, `le="`+formatMetricValue(bound)+`"`)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+			}
+			out.WriteString(family.name + "_bucket" + wrapLabels(append(labels, `le="+Inf"`)) + " " + strconv.FormatUint(series.count, 10) + "\n")
+			out.WriteString(family.name + "_sum" + wrapLabels(labels) + " " + formatMetricValue(series.sum) + "\n")
+			out.WriteString(family.name + "_count" + wrapLabels(labels) + " " + strconv.FormatUint(series.count, 10) + "\n")
+		}
+	}
+
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+//ServeHTTP serves the metrics to prometheus
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func formatLabels(names []string, values []string) []string {
+	labels := make([]string, 0, len(names)+1)
+	for x, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[x])
+		labels = append(labels, name+`="`+value+`"`)
+	}
+
+	return labels
+}
+
+func wrapLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+//serveMetrics starts serving /metrics if configured, returning the server so it can be closed, or nil
+func serveMetrics(config MetricsConfiguration) *http.Server {
+	if config.Listen == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	return serveHTTP("metrics", config.Listen, mux)
+}
+
+//trackRequest counts a request made to discord, and whether it failed
+func trackRequest(action string, err error) error {
+	metrics.Inc(metricDiscordRequests, action)
+	if err != nil {
+		metrics.Inc(metricDiscordFailures, action)
+	}
+
+	return err
+}
+
+//trackQueues reports the depth of the handlers' queues, and how much they've dropped, whenever we're scraped
+func trackQueues(queues []*HandlerQueue) {
+	metrics.OnScrape(func() {
+		for _, queue := range queues {
+			metrics.Set(metricQueueDepth, float64(queue.Depth()), queue.name)
+			metrics.Set(metricQueueDropped, float64(queue.Dropped()), queue.name)
+		}
+	})
+}
+
+//trackGateway follows the session's connection to the discord gateway. Returns functions which stop following it
+func trackGateway(session *discordgo.Session) []func() {
+	metrics.OnScrape(func() {
+		metrics.Set(metricGatewayLatency, session.HeartbeatLatency().Seconds())
+	})
+
+	return []func(){
+		session.AddHandler(func(s *discordgo.Session, event *discordgo.Connect) {
+			metrics.Set(metricGatewayConnected, 1)
+			metrics.Inc(metricGatewayConnects)
+		}),
+		session.AddHandler(func(s *discordgo.Session, event *discordgo.Disconnect) {
+			metrics.Set(metricGatewayConnected, 0)
+		}),
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	m := NewMetrics()
+	m.Register("test_requests_total", counterMetric, "Requests", nil, "action")
+	m.Register("test_connected", gaugeMetric, "Connected", nil)
+	m.Inc("test_requests_total", "send")
+	m.Inc("test_requests_total", "send")
+	m.Inc("test_requests_total", `say "hi"`)
+
+	var out bytes.Buffer
+	m.WriteTo(&out)
+
+	text := out.String()
+	assertContains(t, text, "# HELP test_requests_total Requests\n# TYPE test_requests_total counter\n")
+	assertContains(t, text, `test_requests_total{action="send"} 2`)
+	assertContains(t, text, `test_requests_total{action="say \"hi\""} 1`)
+	assertContains(t, text, "# TYPE test_connected gauge\ntest_connected 0\n")
+}
+
+func TestMetricsHistogramBuckets(t *testing.T) {
+	m := NewMetrics()
+	m.Register("test_seconds", histogramMetric, "Durations", []float64{0.1, 1}, "job")
+	m.Observe("test_seconds", 0.05, "post")
+	m.Observe("test_seconds", 0.5, "post")
+	m.Observe("test_seconds", 5, "post")
+
+	var out bytes.Buffer
+	m.WriteTo(&out)
+
+	text := out.String()
+	assertContains(t, text, `test_seconds_bucket{job="post",le="0.1"} 1`)
+	assertContains(t, text, `test_seconds_bucket{job="post",le="1"} 2`)
+	assertContains(t, text, `test_seconds_bucket{job="post",le="+Inf"} 3`)
+	assertContains(t, text, `test_seconds_sum{job="post"} 5.55`)
+	assertContains(t, text, `test_seconds_count{job="post"} 3`)
+}
+
+func TestMetricsServedOverHTTP(t *testing.T) {
+	m := NewMetrics()
+	m.Register("test_depth", gaugeMetric, "Depth", nil, "handler")
+	depth := 0.0
+	m.OnScrape(func() {
+		depth++
+		m.Set("test_depth", depth, "Release Handler")
+	})
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+	for x := 0; x < 2; x++ {
+		resp, err := server.Client().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if x == 1 {
+			assertContains(t, string(body), `test_depth{handler="Release Handler"} 2`)
+		}
+	}
+}
+
+func TestRoutedCommandsAreCounted(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	before := metrics.Value(metricCommandsHandled, "Release Handler", "/rw", "list")
+
+	router.Route(guildMessage("guild", "/rw list"))
+
+	if after := metrics.Value(metricCommandsHandled, "Release Handler", "/rw", "list"); after != before+1 {
+		t.Errorf("expected the command to be counted, went from %v to %v", before, after)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//Priority Orders a channel's waiting messages, higher priorities are sent first
+type Priority int
+
+const (
+	//LowPriority is for bulk posts, eg image blocks
+	LowPriority Priority = iota
+	//NormalPriority is for replies to commands
+	NormalPriority
+	//HighPriority is for time sensitive posts, eg reminders and release alerts
+	HighPriority
+)
+
+const maxSendAttempts = 5
+const defaultSendBackoff = 500 * time.Millisecond
+const maxSendBackoff = 30 * time.Second
+
+//Outbound Is a message or file waiting to be sent to a channel
+type Outbound struct {
+	ChannelID string
+	//Content too long for one message is split, and its parts sent together
+	Content string
+	//FilePath is uploaded instead of sending Content, if set
+	FilePath string
+	//Complex is sent instead of Content, if set, for messages with embeds or components
+	Complex *discordgo.MessageSend
+	//Request, if set, is made instead of sending anything, eg editing or pinning a message in the channel
+	Request  func() error
+	Priority Priority
+	//Callback, if set, is called with the sent message once delivered, or with the error we gave up on
+	Callback func(*discordgo.Message, error)
+}
+
+//QueueingSession Is a session which can send in the background
+type QueueingSession interface {
+	Queue(outbound Outbound)
+}
+
+//OutboundQueue Sends messages in the background, one at a time per channel and highest priority first
+//Discord rate limits messages per channel, so a busy channel only holds up itself
+//Failures worth retrying are retried with backoff, so a blip on discord's side doesn't lose the message
+type OutboundQueue struct {
+	mutex    sync.Mutex
+	idle     *sync.Cond
+	deliver  func(Outbound) (*discordgo.Message, error)
+	backoff  time.Duration
+	channels map[string][]Outbound
+	sending  map[string]bool
+	pending  int
+}
+
+//NewOutboundQueue creates a queue which sends each message using deliver
+func NewOutboundQueue(deliver func(Outbound) (*discordgo.Message, error)) *OutboundQueue {
+	oq := &OutboundQueue{
+		deliver:  deliver,
+		backoff:  defaultSendBackoff,
+		channels: make(map[string][]Outbound),
+		sending:  make(map[string]bool),
+	}
+	oq.idle = sync.NewCond(&oq.mutex)
+
+	return oq
+}
+
+//Queue adds the message to its channel, behind anything already waiting with the same or higher priority
+func (oq *OutboundQueue) Queue(outbound Outbound) {
+	oq.mutex.Lock()
+	defer oq.mutex.Unlock()
+
+	queue := oq.channels[outbound.ChannelID]
+	position := len(queue)
+	for position > 0 && queue[position-1].Priority < outbound.Priority {
+		position--
+	}
+	queue = append(queue, Outbound{})
+	copy(queue[position+1:], queue[position:])
+	queue[position] = outbound
+	oq.channels[outbound.ChannelID] = queue
+	oq.pending++
+
+	if !oq.sending[outbound.ChannelID] {
+		oq.sending[outbound.ChannelID] = true
+		go oq.drain(outbound.ChannelID)
+	}
+}
+
+//Wait blocks until everything queued has been delivered or given up on
+func (oq *OutboundQueue) Wait() {
+	oq.mutex.Lock()
+	defer oq.mutex.Unlock()
+	for oq.pending > 0 {
+		oq.idle.Wait()
+	}
+}
+
+//drain sends the channel's messages until there are none left
+func (oq *OutboundQueue) drain(channelID string) {
+	for {
+		oq.mutex.Lock()
+		queue := oq.channels[channelID]
+		if len(queue) == 0 {
+			delete(oq.channels, channelID)
+			delete(oq.sending, channelID)
+			oq.mutex.Unlock()
+			return
+		}
+		outbound := queue[0]
+		oq.channels[channelID] = queue[1:]
+		oq.mutex.Unlock()
+
+		message, err := oq.send(outbound)
+		if outbound.Callback != nil {
+			outbound.Callback(message, err)
+		}
+
+		oq.mutex.Lock()
+		oq.pending--
+		if oq.pending == 0 {
+			oq.idle.Broadcast()
+		}
+		oq.mutex.Unlock()
+	}
+}
+
+//send delivers the outbound message, returning the first part of messages which had to be split
+//Parts are sent one after another, so nothing else in the channel lands between them
+func (oq *OutboundQueue) send(outbound Outbound) (*discordgo.Message, error) {
+	if outbound.Request != nil {
+		err := withRetries(oq.backoff, outbound.Request)
+		if err != nil {
+			logger.Error("Error making request, giving up", "channel", outbound.ChannelID, "error", err)
+		}
+		return nil, err
+	}
+
+	parts := []Outbound{outbound}
+	if outbound.FilePath == "" && outbound.Complex == nil {
+		parts = parts[:0]
+		for _, chunk := range splitMessage(outbound.Content, maxMessageLength) {
+			part := outbound
+			part.Content = chunk
+			parts = append(parts, part)
+		}
+	}
+
+	var first *discordgo.Message
+	for i, part := range parts {
+		var message *discordgo.Message
+		err := withRetries(oq.backoff, func() error {
+			var err error
+			message, err = oq.deliver(part)
+			return err
+		})
+		if err != nil {
+			logger.Error("Error sending, giving up", "channel", outbound.ChannelID, "part", i+1, "parts", len(parts), "error", err)
+			return first, err
+		}
+		if i == 0 {
+			first = message
+		}
+	}
+
+	return first, nil
+}
+
+//withRetries calls the request until it succeeds or fails in a way that isn't worth retrying, backing off in between
+func withRetries(backoff time.Duration, request func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := request()
+		if err == nil {
+			return nil
+		}
+
+		wait, retry := retryDelay(err, backoff)
+		if !retry || attempt >= maxSendAttempts {
+			return err
+		}
+
+		logger.Warn("Request to discord failed, retrying", "wait", wait, "error", err)
+		time.Sleep(wait)
+		if backoff *= 2; backoff > maxSendBackoff {
+			backoff = maxSendBackoff
+		}
+	}
+}
+
+//retryDelay decides whether a failed request is worth retrying, and how long to wait first
+//Rate limits say how long, server errors and lost connections back off, and anything else won't get better by itself
+func retryDelay(err error, backoff time.Duration) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *discordgo.RateLimitError:
+		return e.RetryAfter, true
+	case *discordgo.RESTError:
+		if e.Response != nil && (e.Response.StatusCode >= 500 || e.Response.StatusCode == http.StatusTooManyRequests) {
+			return backoff, true
+		}
+		return 0, false
+	case *os.PathError:
+		return 0, false
+	}
+
+	//Anything else never got an answer from discord, eg a timeout or dropped connection
+	return backoff, true
+}
+
+//sendQueued queues the message and waits for it to be delivered
+func sendQueued(session QueueingSession, outbound Outbound) (*discordgo.Message, error) {
+	type result struct {
+		message *discordgo.Message
+		err     error
+	}
+
+	done := make(chan result, 1)
+	callback := outbound.Callback
+	outbound.Callback = func(message *discordgo.Message, err error) {
+		if callback != nil {
+			callback(message, err)
+		}
+		done <- result{message: message, err: err}
+	}
+	session.Queue(outbound)
+
+	sent := <-done
+	return sent.message, sent.err
+}
+
+//queueOutbound sends in the background if the session can, otherwise sends right away
+func queueOutbound(session Session, outbound Outbound) {
+	if queueing, ok := session.(QueueingSession); ok {
+		queueing.Queue(outbound)
+		return
+	}
+
+	var message *discordgo.Message
+	var err error
+	if outbound.Request != nil {
+		err = outbound.Request()
+	} else if outbound.FilePath != "" {
+		err = session.SendFile(outbound.ChannelID, outbound.FilePath)
+	} else if outbound.Complex != nil {
+		message, err = session.SendComplex(outbound.ChannelID, outbound.Complex)
+	} else {
+		message, err = session.SendMessage(outbound.ChannelID, outbound.Content)
+	}
+	if outbound.Callback != nil {
+		outbound.Callback(message, err)
+	}
+}
+
+//prioritySession Sends messages at a fixed priority, where the session supports priorities
+type prioritySession struct {
+	Session
+	priority Priority
+}
+
+//WithPriority returns a session whose messages are sent at the given priority
+func WithPriority(session Session, priority Priority) Session {
+	return &prioritySession{Session: session, priority: priority}
+}
+
+//prioritySender Is implemented by sessions which wrap another, eg for late job runs, so the priority reaches the one underneath
+type prioritySender interface {
+	sendAt(channelID string, message string, priority Priority) (*discordgo.Message, error)
+}
+
+func (ps *prioritySession) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	if wrapper, ok := ps.Session.(prioritySender); ok {
+		return wrapper.sendAt(channelID, message, ps.priority)
+	}
+	if queueing, ok := ps.Session.(QueueingSession); ok {
+		return sendQueued(queueing, Outbound{ChannelID: channelID, Content: message, Priority: ps.priority})
+	}
+
+	return ps.Session.SendMessage(channelID, message)
+}
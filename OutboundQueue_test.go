@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func restError(status int) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: status, Status: http.StatusText(status)}}
+}
+
+func TestOutboundQueueSendsHighPriorityFirst(t *testing.T) {
+	var mutex sync.Mutex
+	sent := make([]string, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	queue := NewOutboundQueue(func(outbound Outbound) (*discordgo.Message, error) {
+		//Hold up the first upload until everything else is queued behind it
+		if outbound.Content == "page 1" {
+			close(started)
+			<-release
+		}
+		mutex.Lock()
+		sent = append(sent, outbound.Content)
+		mutex.Unlock()
+		return &discordgo.Message{ID: "id-" + outbound.Content}, nil
+	})
+
+	queue.Queue(Outbound{ChannelID: "chan", Content: "page 1", Priority: LowPriority})
+	<-started
+	queue.Queue(Outbound{ChannelID: "chan", Content: "page 2", Priority: LowPriority})
+	queue.Queue(Outbound{ChannelID: "chan", Content: "page 3", Priority: LowPriority})
+	queue.Queue(Outbound{ChannelID: "chan", Content: "reply", Priority: NormalPriority})
+	queue.Queue(Outbound{ChannelID: "chan", Content: "reminder", Priority: HighPriority})
+	close(release)
+	queue.Wait()
+
+	if order := strings.Join(sent, ", "); order != "page 1, reminder, reply, page 2, page 3" {
+		t.Errorf("unexpected send order %s", order)
+	}
+}
+
+func TestOutboundQueueSendsSplitMessagesTogether(t *testing.T) {
+	var mutex sync.Mutex
+	sent := make([]string, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	queue := NewOutboundQueue(func(outbound Outbound) (*discordgo.Message, error) {
+		//Hold up the first part until the reminder is queued behind it
+		if strings.HasPrefix(outbound.Content, "a") {
+			close(started)
+			<-release
+		}
+		mutex.Lock()
+		sent = append(sent, outbound.Content[:1])
+		mutex.Unlock()
+		return &discordgo.Message{ID: "id-" + outbound.Content[:1]}, nil
+	})
+
+	long := strings.Repeat("a", maxMessageLength) + "\n" + strings.Repeat("b", 10)
+	var first *discordgo.Message
+	queue.Queue(Outbound{ChannelID: "chan", Content: long, Priority: LowPriority, Callback: func(message *discordgo.Message, err error) { first = message }})
+	<-started
+	queue.Queue(Outbound{ChannelID: "chan", Content: "reminder", Priority: HighPriority})
+	close(release)
+	queue.Wait()
+
+	if order := strings.Join(sent, ", "); order != "a, b, r" {
+		t.Errorf("expected the reminder to wait for the rest of the message, got %s", order)
+	}
+	if first == nil || first.ID != "id-a" {
+		t.Errorf("expected the first part to be returned, got %+v", first)
+	}
+}
+
+func TestOutboundQueueRetries(t *testing.T) {
+	attempts := make(map[string]int)
+	var mutex sync.Mutex
+	queue := NewOutboundQueue(func(outbound Outbound) (*discordgo.Message, error) {
+		mutex.Lock()
+		attempts[outbound.Content]++
+		attempt := attempts[outbound.Content]
+		mutex.Unlock()
+
+		switch {
+		case outbound.Content == "flaky" && attempt < 3:
+			return nil, restError(http.StatusBadGateway)
+		case outbound.Content == "forbidden":
+			return nil, restError(http.StatusForbidden)
+		case outbound.Content == "offline":
+			return nil, errors.New("connection reset")
+		}
+		return &discordgo.Message{ID: "sent"}, nil
+	})
+	queue.backoff = time.Millisecond
+
+	message, err := sendQueued(queue, Outbound{ChannelID: "chan", Content: "flaky"})
+	if err != nil || message.ID != "sent" || attempts["flaky"] != 3 {
+		t.Errorf("expected flaky to be delivered on the third attempt, got %v %v after %d", message, err, attempts["flaky"])
+	}
+
+	if _, err = sendQueued(queue, Outbound{ChannelID: "chan", Content: "forbidden"}); err == nil || attempts["forbidden"] != 1 {
+		t.Errorf("expected forbidden to fail without retrying, got %v after %d", err, attempts["forbidden"])
+	}
+
+	if _, err = sendQueued(queue, Outbound{ChannelID: "other", Content: "offline"}); err == nil || attempts["offline"] != maxSendAttempts {
+		t.Errorf("expected offline to give up after %d attempts, got %v after %d", maxSendAttempts, err, attempts["offline"])
+	}
+}
+
+func TestQueueOutboundFallsBackToSending(t *testing.T) {
+	session := &FakeSession{}
+	var delivered *discordgo.Message
+	queueOutbound(session, Outbound{ChannelID: "chan", Content: "hello", Callback: func(message *discordgo.Message, err error) { delivered = message }})
+	queueOutbound(session, Outbound{ChannelID: "chan", FilePath: "page.png"})
+
+	if delivered == nil || delivered.Content != "hello" {
+		t.Errorf("expected the callback to get the sent message, got %+v", delivered)
+	}
+	if session.Count("file") != 1 {
+		t.Errorf("expected the file to be uploaded, got %+v", session.Calls())
+	}
+}
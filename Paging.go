@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//fieldsPerPage is how many embed fields show at once, discord allows up to 25
+const fieldsPerPage = 10
+
+//maxEmbedLength is discord's limit on the text in an embed, counting its title, description, fields and footer
+const maxEmbedLength = 6000
+
+//pageFooterLength leaves room for our page numbers in the footer
+const pageFooterLength = 32
+
+//maxPagedMessages is how many paged messages we remember, older ones can no longer be paged through
+const maxPagedMessages = 100
+
+//pageComponentPrefix starts the custom ID of our paging controls
+const pageComponentPrefix = "page:"
+
+//pageCache Remembers the pages of recently sent paged messages, so their buttons can flip between them
+type pageCache struct {
+	mutex sync.Mutex
+	pages map[string][]*discordgo.MessageEmbed
+	order []string
+}
+
+var pagedMessages = &pageCache{pages: make(map[string][]*discordgo.MessageEmbed)}
+
+//Remember holds on to the message's pages, forgetting the oldest message if we have too many
+func (pc *pageCache) Remember(messageID string, pages []*discordgo.MessageEmbed) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.pages[messageID] = pages
+	pc.order = append(pc.order, messageID)
+	if len(pc.order) > maxPagedMessages {
+		delete(pc.pages, pc.order[0])
+		pc.order = pc.order[1:]
+	}
+}
+
+//Page returns the page of the message along with its controls, or false if we don't remember the message
+func (pc *pageCache) Page(messageID string, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent, bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pages, ok := pc.pages[messageID]
+	if !ok || page < 0 || page >= len(pages) {
+		return nil, nil, false
+	}
+
+	return pages[page], pageComponents(page, len(pages)), true
+}
+
+//paginate spreads the embed's fields over as many pages as needed, numbering them in the footer
+//Pages hold up to fieldsPerPage fields, fewer if they're long enough to take the page over discord's length limit
+func paginate(embed *discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+	if len(embed.Fields) <= fieldsPerPage && embedLength(embed) <= maxEmbedLength {
+		return []*discordgo.MessageEmbed{embed}
+	}
+
+	empty := *embed
+	empty.Fields = nil
+	empty.Footer = nil
+	base := embedLength(&empty) + pageFooterLength
+
+	pages := make([]*discordgo.MessageEmbed, 0)
+	for start := 0; start < len(embed.Fields); {
+		end := start
+		length := base
+		//Every page gets at least one field, fields are already truncated to fit
+		for end < len(embed.Fields) && end-start < fieldsPerPage {
+			fieldLength := utf8.RuneCountInString(embed.Fields[end].Name) + utf8.RuneCountInString(embed.Fields[end].Value)
+			if end > start && length+fieldLength > maxEmbedLength {
+				break
+			}
+			length += fieldLength
+			end++
+		}
+
+		page := *embed
+		page.Fields = embed.Fields[start:end]
+		pages = append(pages, &page)
+		start = end
+	}
+
+	for i, page := range pages {
+		page.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d", i+1, len(pages))}
+	}
+
+	return pages
+}
+
+//embedLength counts the text discord limits across the embed
+func embedLength(embed *discordgo.MessageEmbed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+
+	return length
+}
+
+//pageComponents builds the controls for a page: previous and next buttons, plus a menu to jump around longer lists
+func pageComponents(page int, total int) []discordgo.MessageComponent {
+	rows := []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "Previous", Style: discordgo.SecondaryButton, CustomID: pageComponentPrefix + strconv.Itoa(page-1), Disabled: page == 0},
+		discordgo.Button{Label: "Next", Style: discordgo.SecondaryButton, CustomID: pageComponentPrefix + strconv.Itoa(page+1), Disabled: page == total-1},
+	}}}
+
+	if total > 2 {
+		menu := discordgo.SelectMenu{CustomID: pageComponentPrefix + "jump", Placeholder: fmt.Sprintf("Page %d of %d", page+1, total)}
+		//Menus only take 25 options, so offer the pages around this one
+		first := page - 12
+		if first < 0 {
+			first = 0
+		}
+		for i := first; i < total && i < first+25; i++ {
+			menu.Options = append(menu.Options, discordgo.SelectMenuOption{Label: fmt.Sprintf("Page %d", i+1), Value: strconv.Itoa(i), Default: i == page})
+		}
+		rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}})
+	}
+
+	return rows
+}
+
+//sendPaged sends the first page of the embed, with controls to page through the rest
+func sendPaged(session Session, channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	pages := paginate(embed)
+	message := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{pages[0]}}
+	if len(pages) > 1 {
+		message.Components = pageComponents(0, len(pages))
+	}
+
+	sent, err := session.SendComplex(channelID, message)
+	if err == nil && len(pages) > 1 {
+		pagedMessages.Remember(sent.ID, pages)
+	}
+
+	return sent, err
+}
+
+//turnPage responds to a paging control by swapping the message over to the chosen page
+func turnPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	target := strings.TrimPrefix(data.CustomID, pageComponentPrefix)
+	if len(data.Values) > 0 {
+		target = data.Values[0]
+	}
+
+	response := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "I no longer remember that list, please ask for it again",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+
+	page, err := strconv.Atoi(target)
+	if err == nil && i.Message != nil {
+		if embed, components, ok := pagedMessages.Page(i.Message.ID, page); ok {
+			response = &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{embed}, Components: components},
+			}
+		}
+	}
+
+	if err := s.InteractionRespond(i.Interaction, response); err != nil {
+		logger.Error("Error responding to paging", "channel", i.ChannelID, "error", err)
+	}
+}
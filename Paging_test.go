@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPaginateSpreadsFields(t *testing.T) {
+	embed := newEmbed("Things")
+	for i := 0; i < 25; i++ {
+		embed.Fields = append(embed.Fields, embedField(strconv.Itoa(i), "value", false))
+	}
+
+	pages := paginate(embed)
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(pages))
+	}
+	if len(pages[2].Fields) != 5 || pages[2].Fields[0].Name != "20" {
+		t.Errorf("unexpected last page %+v", pages[2].Fields)
+	}
+	if pages[1].Footer == nil || pages[1].Footer.Text != "Page 2 of 3" {
+		t.Errorf("expected the footer to number the page, got %+v", pages[1].Footer)
+	}
+}
+
+func TestPaginateKeepsPagesWithinTheEmbedLimit(t *testing.T) {
+	embed := newEmbed("Things")
+	for i := 0; i < 10; i++ {
+		embed.Fields = append(embed.Fields, embedField(strconv.Itoa(i), strings.Repeat("x", 1000), false))
+	}
+
+	pages := paginate(embed)
+	if len(pages) != 2 || len(pages[0].Fields) != 5 {
+		t.Fatalf("expected the long fields to be spread over 2 pages, got %d", len(pages))
+	}
+	for _, page := range pages {
+		if length := embedLength(page); length > maxEmbedLength {
+			t.Errorf("expected each page to fit discord's limit, got %d", length)
+		}
+	}
+}
+
+func TestSendPagedRemembersPages(t *testing.T) {
+	session := &FakeSession{}
+	embed := newEmbed("Things")
+	for i := 0; i < 12; i++ {
+		embed.Fields = append(embed.Fields, embedField(strconv.Itoa(i), "value", false))
+	}
+
+	sent, err := sendPaged(session, "chan", embed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, session.LastSent("chan"), "[Previous] [Next]")
+
+	page, components, ok := pagedMessages.Page(sent.ID, 1)
+	if !ok || len(page.Fields) != 2 {
+		t.Fatalf("expected to find the second page, got %v %+v", ok, page)
+	}
+	row := components[0].(discordgo.ActionsRow)
+	if previous := row.Components[0].(discordgo.Button); previous.CustomID != "page:0" || previous.Disabled {
+		t.Errorf("expected previous to go back to the first page, got %+v", previous)
+	}
+	if next := row.Components[1].(discordgo.Button); !next.Disabled {
+		t.Errorf("expected next to be disabled on the last page, got %+v", next)
+	}
+
+	if _, _, ok := pagedMessages.Page(sent.ID, 2); ok {
+		t.Error("expected no page past the end")
+	}
+}
+
+func TestShortListsHaveNoControls(t *testing.T) {
+	session := &FakeSession{}
+	embed := newEmbed("Things")
+	embed.Fields = append(embed.Fields, embedField("only", "one", false))
+
+	sendPaged(session, "chan", embed)
+	calls := session.Calls()
+	if len(calls) != 1 || calls[0].Complex == nil || len(calls[0].Complex.Components) != 0 {
+		t.Fatalf("expected a single page without controls, got %+v", calls)
+	}
+	if calls[0].Content != "**Things**\nonly: one" {
+		t.Errorf("unexpected text %q", calls[0].Content)
+	}
+}
+
+func TestEmbedFieldTruncates(t *testing.T) {
+	long := ""
+	for i := 0; i < 300; i++ {
+		long += "é"
+	}
+
+	field := embedField(long, "value", true)
+	if runes := []rune(field.Name); len(runes) != maxEmbedFieldName || runes[len(runes)-1] != '…' {
+		t.Errorf("expected the name to be cut to %d characters, got %d", maxEmbedFieldName, len(runes))
+	}
+}
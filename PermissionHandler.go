@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//PermissionHandler lets guild admins grant command capabilities to roles and users
+type PermissionHandler struct {
+	handlerLoop
+	session      Session
+	permissions  *PermissionStore
+	matcher      regexp.Regexp
+	grantMatcher regexp.Regexp
+}
+
+const permCommand = "/perm"
+
+//NewPermissionHandler creates a handler which manages the provided permissions
+func NewPermissionHandler(session Session, permissions *PermissionStore) *PermissionHandler {
+	return &PermissionHandler{session: session, permissions: permissions}
+}
+
+//Init compiles our regexp and spins up our channel handling
+func (ph *PermissionHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	ph.matcher = *regexp.MustCompile(`^` + permCommand + `\s+(\w+)\s*(.*)$`)
+	//capability, then a role mention, user mention or everyone
+	ph.grantMatcher = *regexp.MustCompile(`^([\w.]+)\s+(?:<@&(\d+)>|<@!?(\d+)>|(@?everyone))$`)
+
+	ph.listen(ctx, ph.GetName(), m, ph.handleMessage)
+}
+
+//GetName returns our name
+func (ph *PermissionHandler) GetName() string {
+	return "Permission Handler"
+}
+
+//Commands returns the commands owned by this handler
+func (ph *PermissionHandler) Commands() []Command {
+	grantOptions := []CommandOption{
+		{Name: "capability", Description: "Capability, from " + permCommand + " list", Type: discordgo.ApplicationCommandOptionString, Required: true, Autocomplete: true},
+		{Name: "who", Description: "Role or user", Type: discordgo.ApplicationCommandOptionMentionable, Required: true},
+	}
+
+	return []Command{{
+		Prefix:      permCommand,
+		Description: "Permissions - Control who can use which commands",
+		Subcommands: []Subcommand{
+			{Name: "grant", Description: "Allow a role or user to use a capability", Options: grantOptions, Capability: "permissions.manage"},
+			{Name: "revoke", Description: "Take a capability away from a role or user", Options: grantOptions, Capability: "permissions.manage"},
+			{Name: "list", Description: "List capabilities and who has them"},
+		},
+	}}
+}
+
+//Autocomplete suggests capabilities declared by any registered command
+func (ph *PermissionHandler) Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	if option == "capability" && commandRouter != nil {
+		for _, capability := range commandRouter.Capabilities() {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: capability, Value: capability})
+		}
+	}
+
+	return matchChoices(choices, partial)
+}
+
+//Help Gets info about this handler
+func (ph *PermissionHandler) Help() string {
+	return permCommand + " : Permissions - Control who can use which commands"
+}
+
+func (ph *PermissionHandler) handleMessage(m *discordgo.MessageCreate) {
+	submatches := ph.matcher.FindStringSubmatch(m.Content)
+	if submatches == nil {
+		return
+	}
+
+	if m.GuildID == "" {
+		ph.session.SendMessage(m.ChannelID, "Permissions can only be managed in a server")
+		return
+	}
+
+	switch submatches[1] {
+	case "grant":
+		ph.grant(m, submatches[2])
+	case "revoke":
+		ph.revoke(m, submatches[2])
+	case "list":
+		ph.list(m.ChannelID, m.GuildID)
+	}
+}
+
+//parseGrant extracts the capability and role or user from grant/revoke arguments
+func (ph *PermissionHandler) parseGrant(guildID string, data string) (capability string, roleID string, userID string, ok bool) {
+	match := ph.grantMatcher.FindStringSubmatch(data)
+	if match == nil {
+		return "", "", "", false
+	}
+
+	capability = match[1]
+	roleID = match[2]
+	userID = match[3]
+	if match[4] != "" {
+		roleID = guildID
+	}
+
+	return capability, roleID, userID, true
+}
+
+func (ph *PermissionHandler) grant(m *discordgo.MessageCreate, data string) {
+	capability, roleID, userID, ok := ph.parseGrant(m.GuildID, data)
+	if !ok {
+		ph.session.SendMessage(m.ChannelID, localizeCommands(m.GuildID, "Usage: "+permCommand+" grant <capability> <@role|@user|everyone>"))
+		return
+	}
+
+	if !ph.knownCapability(capability) {
+		ph.session.SendMessage(m.ChannelID, localizeCommands(m.GuildID, "Unknown capability "+capability+", see "+permCommand+" list"))
+		return
+	}
+
+	if err := ph.permissions.Grant(m.GuildID, capability, roleID, userID); err != nil {
+		ph.session.SendMessage(m.ChannelID, "Error saving permissions: "+err.Error())
+		return
+	}
+
+	ph.audit("grant", nil, capability+": "+ph.describe(m.GuildID, roleID, userID))
+	ph.reply(m.ChannelID, "Granted "+capability+" to "+ph.describe(m.GuildID, roleID, userID))
+}
+
+func (ph *PermissionHandler) revoke(m *discordgo.MessageCreate, data string) {
+	capability, roleID, userID, ok := ph.parseGrant(m.GuildID, data)
+	if !ok {
+		ph.session.SendMessage(m.ChannelID, localizeCommands(m.GuildID, "Usage: "+permCommand+" revoke <capability> <@role|@user|everyone>"))
+		return
+	}
+
+	removed, err := ph.permissions.Revoke(m.GuildID, capability, roleID, userID)
+	if err != nil {
+		ph.session.SendMessage(m.ChannelID, "Error saving permissions: "+err.Error())
+	} else if !removed {
+		ph.reply(m.ChannelID, ph.describe(m.GuildID, roleID, userID)+" didn't have "+capability)
+	} else {
+		ph.audit("revoke", capability+": "+ph.describe(m.GuildID, roleID, userID), nil)
+		ph.reply(m.ChannelID, "Revoked "+capability+" from "+ph.describe(m.GuildID, roleID, userID))
+	}
+}
+
+func (ph *PermissionHandler) list(channelID string, guildID string) {
+	grants := ph.permissions.Grants(guildID)
+	message := "Server admins can use everything. Everyone else needs one of these granted:\n"
+	for _, capability := range commandRouter.Capabilities() {
+		message += capability + ": "
+		holders := make([]string, 0)
+		if grant, ok := grants[capability]; ok {
+			for _, role := range grant.Roles {
+				holders = append(holders, ph.describe(guildID, role, ""))
+			}
+			for _, user := range grant.Users {
+				holders = append(holders, ph.describe(guildID, "", user))
+			}
+		}
+
+		if len(holders) == 0 {
+			message += "<admins only>\n"
+		} else {
+			sort.Strings(holders)
+			message += strings.Join(holders, ", ") + "\n"
+		}
+	}
+
+	ph.reply(channelID, message)
+}
+
+func (ph *PermissionHandler) knownCapability(capability string) bool {
+	return commandRouter != nil && containsString(commandRouter.Capabilities(), capability)
+}
+
+//reply sends a message naming roles and users without pinging them, as a grant to a role would ping all its members
+func (ph *PermissionHandler) reply(channelID string, content string) {
+	for _, chunk := range splitMessage(content, maxMessageLength) {
+		ph.session.SendComplex(channelID, &discordgo.MessageSend{Content: chunk, AllowedMentions: &discordgo.MessageAllowedMentions{}})
+	}
+}
+
+//describe formats the role or user as a mention. The @everyone role can't be mentioned without pinging everyone
+func (ph *PermissionHandler) describe(guildID string, roleID string, userID string) string {
+	if roleID == guildID {
+		return "everyone"
+	} else if roleID != "" {
+		return "<@&" + roleID + ">"
+	}
+
+	return "<@" + userID + ">"
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPermissionRepliesDontPing(t *testing.T) {
+	router, session, _ := newTestRouter(t)
+	commandRouter = router
+	t.Cleanup(func() { commandRouter = nil })
+	handler := NewPermissionHandler(session, router.permissions)
+	channel := make(chan *discordgo.MessageCreate)
+	handler.Init(context.Background(), channel)
+
+	channel <- guildMessage("guild", "/perm grant releases.delete <@&42>")
+	channel <- guildMessage("guild", "/perm list")
+	channel <- nil
+	handler.Stop()
+
+	sent := session.Calls()
+	if len(sent) != 2 {
+		t.Fatalf("expected the grant and list replies, got %+v", sent)
+	}
+	assertContains(t, sent[0].Content, "Granted releases.delete to <@&42>")
+	assertContains(t, sent[1].Content, "releases.delete: <@&42>")
+	for _, call := range sent {
+		if call.Complex == nil || call.Complex.AllowedMentions == nil || len(call.Complex.AllowedMentions.Parse) != 0 {
+			t.Errorf("expected %q to be sent without pinging anyone", call.Content)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//capabilityGrant Lists who has been granted a capability
+type capabilityGrant struct {
+	Roles []string `json:"roles"`
+	Users []string `json:"users"`
+}
+
+type guildPermissions struct {
+	GuildID string                      `json:"guildID"`
+	Grants  map[string]*capabilityGrant `json:"grants"`
+}
+
+//PermissionStore Decides who may use capabilities declared by handler commands
+//Bot owners and guild admins can use everything, anyone else needs a grant for their user or one of their roles
+type PermissionStore struct {
+	mutex   sync.RWMutex
+	session Session
+	store   Store
+	owners  map[string]bool
+	guilds  map[string]*guildPermissions
+}
+
+//NewPermissionStore loads saved grants. Owners bypass all permission checks
+func NewPermissionStore(session Session, store Store, owners []string) *PermissionStore {
+	ps := &PermissionStore{
+		session: session,
+		store:   store,
+		guilds:  make(map[string]*guildPermissions),
+	}
+
+	ps.SetOwners(owners)
+
+	var data []*guildPermissions
+	err := store.Load(permissionsDocument, &data)
+	if err == nil {
+		logger.Info("Reading saved permissions")
+		for _, guild := range data {
+			ps.guilds[guild.GuildID] = guild
+		}
+	} else if err != ErrNotFound {
+		logger.Error("Error loading permissions", "error", err)
+	}
+
+	return ps
+}
+
+//IsOwner checks if the user is listed as a bot owner
+func (ps *PermissionStore) IsOwner(userID string) bool {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+	return ps.owners[userID]
+}
+
+//SetOwners replaces the bot owners
+func (ps *PermissionStore) SetOwners(owners []string) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	ps.owners = make(map[string]bool)
+	for _, owner := range owners {
+		ps.owners[owner] = true
+	}
+}
+
+//Allowed checks if the author of the message may use the capability
+func (ps *PermissionStore) Allowed(m *discordgo.MessageCreate, capability string) bool {
+	if capability == "" || ps.IsOwner(m.Author.ID) {
+		return true
+	}
+
+	//Outside of a guild there's nobody to grant anything, so only owners get through
+	if m.GuildID == "" {
+		return false
+	}
+
+	if isGuildAdmin(ps.session, m) {
+		return true
+	}
+
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	guild, ok := ps.guilds[m.GuildID]
+	if !ok {
+		return false
+	}
+
+	grant, ok := guild.Grants[capability]
+	if !ok {
+		return false
+	}
+
+	if containsString(grant.Users, m.Author.ID) {
+		return true
+	}
+
+	//Everyone implicitly has the guild's @everyone role, which shares its ID
+	if containsString(grant.Roles, m.GuildID) {
+		return true
+	}
+
+	if m.Member != nil {
+		for _, role := range m.Member.Roles {
+			if containsString(grant.Roles, role) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//Grant gives the role or user the capability in the guild
+func (ps *PermissionStore) Grant(guildID string, capability string, roleID string, userID string) error {
+	ps.mutex.Lock()
+	guild, ok := ps.guilds[guildID]
+	if !ok {
+		guild = &guildPermissions{GuildID: guildID, Grants: make(map[string]*capabilityGrant)}
+		ps.guilds[guildID] = guild
+	}
+
+	grant, ok := guild.Grants[capability]
+	if !ok {
+		grant = &capabilityGrant{Roles: make([]string, 0), Users: make([]string, 0)}
+		guild.Grants[capability] = grant
+	}
+
+	if roleID != "" && !containsString(grant.Roles, roleID) {
+		grant.Roles = append(grant.Roles, roleID)
+	}
+	if userID != "" && !containsString(grant.Users, userID) {
+		grant.Users = append(grant.Users, userID)
+	}
+	ps.mutex.Unlock()
+
+	return ps.writeData()
+}
+
+//Revoke removes the capability from the role or user in the guild. Returns false if they didn't have it
+func (ps *PermissionStore) Revoke(guildID string, capability string, roleID string, userID string) (bool, error) {
+	ps.mutex.Lock()
+	removed := false
+	if guild, ok := ps.guilds[guildID]; ok {
+		if grant, ok := guild.Grants[capability]; ok {
+			var found bool
+			if grant.Roles, found = removeString(grant.Roles, roleID); found {
+				removed = true
+			}
+			if grant.Users, found = removeString(grant.Users, userID); found {
+				removed = true
+			}
+			if len(grant.Roles) == 0 && len(grant.Users) == 0 {
+				delete(guild.Grants, capability)
+			}
+		}
+	}
+	ps.mutex.Unlock()
+
+	if !removed {
+		return false, nil
+	}
+
+	return true, ps.writeData()
+}
+
+//Grants returns the guild's grants, keyed by capability
+func (ps *PermissionStore) Grants(guildID string) map[string]capabilityGrant {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	grants := make(map[string]capabilityGrant)
+	if guild, ok := ps.guilds[guildID]; ok {
+		for capability, grant := range guild.Grants {
+			grants[capability] = capabilityGrant{
+				Roles: append([]string(nil), grant.Roles...),
+				Users: append([]string(nil), grant.Users...),
+			}
+		}
+	}
+
+	return grants
+}
+
+func (ps *PermissionStore) writeData() error {
+	ps.mutex.RLock()
+	data := make([]*guildPermissions, 0, len(ps.guilds))
+	for _, guild := range ps.guilds {
+		data = append(data, guild)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].GuildID < data[j].GuildID })
+	//Save while still holding the lock, the store encodes the grants we share with readers
+	defer ps.mutex.RUnlock()
+
+	return ps.store.Save(permissionsDocument, data)
+}
+
+//isGuildAdmin checks if the message author can manage the guild the message was sent in
+func isGuildAdmin(session Session, m *discordgo.MessageCreate) bool {
+	permissions, err := session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return false
+	}
+
+	return permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0
+}
+
+func containsString(slice []string, value string) bool {
+	for _, entry := range slice {
+		if entry == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+//removeString removes the value from the slice, reporting if it was present
+func removeString(slice []string, value string) ([]string, bool) {
+	if value == "" {
+		return slice, false
+	}
+
+	for x, entry := range slice {
+		if entry == value {
+			return append(slice[:x], slice[x+1:]...), true
+		}
+	}
+
+	return slice, false
+}
@@ -1,20 +1,33 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"regexp"
+	"sort"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 //ReactionHandler selectively Reactions on keywords
 type ReactionHandler struct {
-	reactionMap map[*regexp.Regexp]string
+	handlerLoop
+	session Session
+	store   Store
+	//reactionMap holds the reactions by their trigger word
+	reactionMap map[string]*reactionRule
 }
 
-const reactionDataFile = "./reactionData.json"
+type reactionRule struct {
+	reactionData
+	regex *regexp.Regexp
+}
+
+//NewReactionHandler creates a handler which reacts through the provided session, reading keywords from the store
+func NewReactionHandler(session Session, store Store) *ReactionHandler {
+	return &ReactionHandler{session: session, store: store}
+}
 
 type reactionData struct {
 	TriggerWord string `json:"TriggerWord"`
@@ -22,36 +35,58 @@ type reactionData struct {
 }
 
 //Init read in our configured Reaction keywords
-func (rh *ReactionHandler) Init(m chan *discordgo.MessageCreate) {
-	rh.reactionMap = make(map[*regexp.Regexp]string)
-
-	// Get configuration
-	fileData, err := ioutil.ReadFile(reactionDataFile)
+func (rh *ReactionHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	rh.reactionMap = make(map[string]*reactionRule)
 
 	//Load up in-memory cache of this info
+	var data []reactionData
+	err := rh.store.Load(reactionDocument, &data)
 	if err == nil {
-		fmt.Println("Reading Reaction notification data")
-		var data []reactionData
-		err = json.Unmarshal(fileData, &data)
-		if err == nil {
-			for _, reactionDef := range data {
-				regex := regexp.MustCompile(`^.*` + reactionDef.TriggerWord + `.*$`)
-				rh.reactionMap[regex] = reactionDef.Reaction
+		rh.log.Info("Reading Reaction notification data")
+		for _, reactionDef := range data {
+			rule, err := newReactionRule(reactionDef)
+			if err != nil {
+				rh.log.Warn("Skipping invalid trigger word", "trigger", reactionDef.TriggerWord, "error", err)
+				continue
 			}
+			rh.reactionMap[reactionDef.TriggerWord] = rule
 		}
+	} else if err != ErrNotFound {
+		rh.log.Error("Error loading Reaction data", "error", err)
 	}
 
 	//Now, spin up our message handling thread
-	go func() {
-		for {
-			message := <-m
-			if message != nil {
-				rh.handleMessage(message)
-			} else {
-				return
-			}
+	rh.listen(ctx, rh.GetName(), m, rh.handleMessage)
+}
+
+//Reload re-reads and re-compiles the trigger words, keeping the ones we have if any fail to load or compile
+func (rh *ReactionHandler) Reload() (string, error) {
+	var data []reactionData
+	if err := loadStrict(rh.store, reactionDocument, &data); err != nil && err != ErrNotFound {
+		return reactionDocument, err
+	}
+
+	reactionMap, err := compileReactionRules(data)
+	if err != nil {
+		return reactionDocument, err
+	}
+
+	rh.reactionMap = reactionMap
+	return reactionDocument, nil
+}
+
+//compileReactionRules compiles every trigger word, failing if any won't
+func compileReactionRules(data []reactionData) (map[string]*reactionRule, error) {
+	reactionMap := make(map[string]*reactionRule)
+	for _, reactionDef := range data {
+		rule, err := newReactionRule(reactionDef)
+		if err != nil {
+			return nil, fmt.Errorf("trigger word %q: %v", reactionDef.TriggerWord, err)
 		}
-	}()
+		reactionMap[reactionDef.TriggerWord] = rule
+	}
+
+	return reactionMap, nil
 }
 
 //GetName returns name of handler
@@ -61,13 +96,82 @@ func (rh *ReactionHandler) GetName() string {
 
 //HandleMessage echoes the messages seen to stdout
 func (rh *ReactionHandler) handleMessage(m *discordgo.MessageCreate) {
-	for regex, reaction := range rh.reactionMap {
-		if regex.MatchString(m.Content) {
-			MessageSender.React(m.ChannelID, m.ID, reaction)
+	for _, rule := range rh.reactionMap {
+		if rule.regex.MatchString(m.Content) {
+			rh.session.React(m.ChannelID, m.ID, rule.Reaction)
 		}
 	}
 }
 
+func newReactionRule(reactionDef reactionData) (*reactionRule, error) {
+	if reactionDef.TriggerWord == "" || reactionDef.Reaction == "" {
+		return nil, errors.New("reactions need a trigger word and a reaction")
+	}
+
+	regex, err := regexp.Compile(`^.*` + reactionDef.TriggerWord + `.*$`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reactionRule{reactionData: reactionDef, regex: regex}, nil
+}
+
+//reactions returns the reactions sorted by trigger word
+func (rh *ReactionHandler) reactions() []reactionData {
+	data := make([]reactionData, 0, len(rh.reactionMap))
+	for _, rule := range rh.reactionMap {
+		data = append(data, rule.reactionData)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].TriggerWord < data[j].TriggerWord })
+
+	return data
+}
+
+//setReaction reacts to the trigger word with the reaction, replacing any reaction it already had
+func (rh *ReactionHandler) setReaction(reactionDef reactionData) error {
+	rule, err := newReactionRule(reactionDef)
+	if err != nil {
+		return err
+	}
+
+	var before interface{}
+	if existing, ok := rh.reactionMap[reactionDef.TriggerWord]; ok {
+		before = existing.reactionData
+	}
+	rh.reactionMap[reactionDef.TriggerWord] = rule
+	if err := rh.writeData(); err != nil {
+		return err
+	}
+
+	rh.audit("set", before, reactionDef)
+	return nil
+}
+
+//removeReaction stops reacting to the trigger word
+func (rh *ReactionHandler) removeReaction(trigger string) error {
+	existing, ok := rh.reactionMap[trigger]
+	if !ok {
+		return errors.New("no reaction for " + trigger)
+	}
+
+	delete(rh.reactionMap, trigger)
+	if err := rh.writeData(); err != nil {
+		return err
+	}
+
+	rh.audit("remove", existing.reactionData, nil)
+	return nil
+}
+
+func (rh *ReactionHandler) writeData() error {
+	err := rh.store.Save(reactionDocument, rh.reactions())
+	if err != nil {
+		rh.log.Error("Error saving Reaction data", "error", err)
+	}
+
+	return err
+}
+
 //Help Gets info about this release handler
 func (rh *ReactionHandler) Help() string {
 	return "(Reaction Handler Active)"
@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"context"
+	"errors"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -14,25 +14,64 @@ import (
 
 //ReleaseHandler Echoes messages to stdout
 type ReleaseHandler struct {
+	handlerLoop
+	session Session
+	store   Store
+
 	matcher       regexp.Regexp
 	addMatcher    regexp.Regexp
 	editMatcher   regexp.Regexp
 	deleteMatcher regexp.Regexp
 	dateMatcher   regexp.Regexp
 
-	releases map[string]*channelReleaseData
+	releases    map[string]*channelReleaseData
+	completions completionCache
+}
+
+//NewReleaseHandler creates a handler which communicates through the provided session and saves to the store
+func NewReleaseHandler(session Session, store Store) *ReleaseHandler {
+	return &ReleaseHandler{session: session, store: store}
 }
 
 type releaseData struct {
-	Name        string `json:"name"`
-	ReleaseDate string `json:"releasedate"`
-	ParsedDate  *time.Time
+	Name        string     `json:"name"`
+	ReleaseDate string     `json:"releasedate"`
+	ParsedDate  *time.Time `json:"parsedDate"`
 }
 
 type channelReleaseData struct {
-	ChannelID       string        `json:"channelID"`
-	PinnedMessageID string        `json:"pinnedMessageID"`
-	Releases        []releaseData `json:"releaseData"`
+	ChannelID string `json:"channelID"`
+	//PinnedMessageIDs hold the pinned summary, which takes more than one message once there are enough releases
+	PinnedMessageIDs []string      `json:"pinnedMessageIDs"`
+	Releases         []releaseData `json:"releaseData"`
+	GuildID          string        `json:"guildID,omitempty"`
+	//TimeZone overrides the guild's, deciding when release dates roll over
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+//migrateReleaseV1 tags ParsedDate like the other release fields
+func migrateReleaseV1(data interface{}) (interface{}, error) {
+	for _, channelData := range jsonObjects(data) {
+		for _, release := range jsonObjects(channelData["releaseData"]) {
+			renameKeys(release, map[string]string{"ParsedDate": "parsedDate"})
+		}
+	}
+
+	return data, nil
+}
+
+//migrateReleaseV2 turns the single pinned summary into a list of them
+func migrateReleaseV2(data interface{}) (interface{}, error) {
+	for _, channelData := range jsonObjects(data) {
+		pinned := make([]interface{}, 0)
+		if id, ok := channelData["pinnedMessageID"].(string); ok && id != "" {
+			pinned = append(pinned, id)
+		}
+		delete(channelData, "pinnedMessageID")
+		channelData["pinnedMessageIDs"] = pinned
+	}
+
+	return data, nil
 }
 
 type byReleaseDate []releaseData
@@ -91,62 +130,84 @@ func extractQuarterInfo(quarter, year string) (int, int, error) {
 	return -1, -1, err
 }
 
+var errNoReleases = errors.New("channel does not have any releases")
+var errInvalidRelease = errors.New("no release with that ID")
+
 const rwCommand string = "/rw"
-const dataFile = "./releaseData.json"
 
 //Init compiles regexp and loads in saved information
-func (rh *ReleaseHandler) Init(m chan *discordgo.MessageCreate) {
+func (rh *ReleaseHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
 	rh.matcher = *regexp.MustCompile(`^\` + rwCommand + `\s+(\w+)\s*(.*)`)
 	rh.addMatcher = *regexp.MustCompile(`^([\w-/]+) (.*)`)
 	rh.editMatcher = *regexp.MustCompile(`^(\d+) ([\w-/]+)`)
 	rh.deleteMatcher = *regexp.MustCompile(`^(\d+)`)
 	rh.dateMatcher = *regexp.MustCompile(`(\d+)[-\/](\d+)[-\/](\d+)`)
-	rh.releases = make(map[string]*channelReleaseData)
 
 	//Need to read in stored json info as well!
 	var data []channelReleaseData
 
-	// Get configuration
-	fileData, err := ioutil.ReadFile(dataFile)
-
 	//Load up in-memory cache of this info
+	err := rh.store.Load(releaseDocument, &data)
 	if err == nil {
-		fmt.Println("Reading saved release data")
-		err = json.Unmarshal(fileData, &data)
-		if err == nil {
-			for _, channelData := range data {
-				for _, release := range channelData.Releases {
-					//Try to update this release's ParsedDate
-					//This will ensure we convert any releases missing parsed times
-					rh.updateReleaseTime(&release)
-				}
+		rh.log.Info("Reading saved release data")
+	} else if err != ErrNotFound {
+		rh.log.Error("Error loading release data", "error", err)
+	}
+	rh.releases = rh.indexReleases(data)
+	rh.updateCompletions()
+
+	rh.listen(ctx, rh.GetName(), m, rh.handleMessage)
+}
 
-				//Sort our slices now, in case the ordering changed by updating
-				//parsed dates above
-				sort.Stable(byReleaseDate(channelData.Releases))
-				channelCopy := channelData
-				rh.releases[channelData.ChannelID] = &channelCopy
+//validateReleases checks saved releases belong to a channel and have a name
+func validateReleases(data []channelReleaseData) error {
+	for _, channelData := range data {
+		if channelData.ChannelID == "" {
+			return errors.New("releases are missing their channel ID")
+		}
+		for _, release := range channelData.Releases {
+			if release.Name == "" {
+				return errors.New("a release in channel " + channelData.ChannelID + " has no name")
 			}
 		}
 	}
 
-	go func() {
-		//Now, get our schedule ready
-		minuteSchedule := time.NewTicker(time.Minute)
-		defer minuteSchedule.Stop()
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					rh.handleMessage(message)
-				} else {
-					return
-				}
-			case <-minuteSchedule.C:
-				rh.scheduledTask()
-			}
+	return nil
+}
+
+//indexReleases sorts each channel's releases and keys them by channel
+func (rh *ReleaseHandler) indexReleases(data []channelReleaseData) map[string]*channelReleaseData {
+	releases := make(map[string]*channelReleaseData)
+	for _, channelData := range data {
+		for _, release := range channelData.Releases {
+			//Try to update this release's ParsedDate
+			//This will ensure we convert any releases missing parsed times
+			rh.updateReleaseTime(&release)
 		}
-	}()
+
+		//Sort our slices now, in case the ordering changed by updating
+		//parsed dates above
+		sort.Stable(byReleaseDate(channelData.Releases))
+		channelCopy := channelData
+		releases[channelData.ChannelID] = &channelCopy
+	}
+
+	return releases
+}
+
+//Reload re-reads the saved releases, keeping the ones we have if the file can't be loaded or doesn't make sense
+func (rh *ReleaseHandler) Reload() (string, error) {
+	var data []channelReleaseData
+	if err := loadStrict(rh.store, releaseDocument, &data); err != nil && err != ErrNotFound {
+		return releaseDocument, err
+	}
+	if err := validateReleases(data); err != nil {
+		return releaseDocument, err
+	}
+
+	rh.releases = rh.indexReleases(data)
+	rh.updateCompletions()
+	return releaseDocument, nil
 }
 
 //GetName returns our name
@@ -160,60 +221,76 @@ func (rh *ReleaseHandler) handleMessage(m *discordgo.MessageCreate) {
 		command := submatches[1]
 		switch command {
 		case "add":
-			rh.add(m.ChannelID, submatches[2])
+			rh.add(m.ChannelID, m.GuildID, submatches[2])
 		case "list":
 			rh.list(m.ChannelID)
 		case "edit":
 			rh.edit(m.ChannelID, submatches[2])
 		case "delete":
 			rh.delete(m.ChannelID, submatches[2])
+		case "tz":
+			rh.setTimeZone(m.ChannelID, m.GuildID, submatches[2])
 		case "help":
-			rh.help(m.ChannelID)
+			rh.help(m.ChannelID, m.GuildID)
 		default:
-			rh.help(m.ChannelID)
+			rh.help(m.ChannelID, m.GuildID)
 		}
 
-		MessageSender.DeleteMessage(m.ChannelID, m.ID)
+		rh.session.DeleteMessage(m.ChannelID, m.ID)
 	}
 }
 
-func (rh *ReleaseHandler) scheduledTask() {
-	currentTime := time.Now()
-	cdate := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 0, 0, 0, 0, time.Local)
+//Jobs announces releases each morning. Channels can be in any time zone, so check every minute for whose morning it is, whatever their offset
+func (rh *ReleaseHandler) Jobs() []Job {
+	return []Job{{Name: "release announcements", Schedule: Every(time.Minute), Run: rh.scheduledTask, Queue: rh.tasks}}
+}
+
+func (rh *ReleaseHandler) scheduledTask(run JobRun) {
+	//Alerts go out ahead of anything bulky waiting in the channel
+	session := WithPriority(run.Session, HighPriority)
 	changed := false
-	if currentTime.Hour() == 11 && currentTime.Minute() == 0 {
-		for _, channelData := range rh.releases {
-			tempChannelReleases := channelData.Releases[:0]
-			for _, release := range channelData.Releases {
-				//Does this release have a notifiable release date specified?
-				if release.ParsedDate != nil {
+	for _, channelData := range rh.releases {
+		location := rh.location(channelData.ChannelID, "")
+		if !dueAt(run.Occurrence, location, 11, 0).Equal(run.Occurrence) {
+			continue
+		}
 
-					nextWeek := cdate.AddDate(0, 0, 7)
-					tomorrow := cdate.AddDate(0, 0, 1)
-
-					if cdate == *release.ParsedDate {
-						MessageSender.SendMessage(channelData.ChannelID, release.Name+" released today!")
-					} else {
-						//Regardless if we notify, add to the new list
-						tempChannelReleases = append(tempChannelReleases, release)
-
-						//Notify if appropriate!
-						if nextWeek == *release.ParsedDate {
-							MessageSender.SendMessage(channelData.ChannelID, release.Name+" is releasing next week!")
-						} else if tomorrow == *release.ParsedDate {
-							MessageSender.SendMessage(channelData.ChannelID, release.Name+" is releasing tomorrow!")
-						}
+		//Releases are still cleared out in channels we've been switched off in, just not announced
+		announce := handlerActive(rh.GetName(), rh.channelGuild(channelData.ChannelID), channelData.ChannelID)
+		local := run.Occurrence.In(location)
+		cdate := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+		tempChannelReleases := channelData.Releases[:0]
+		for _, release := range channelData.Releases {
+			//Does this release have a notifiable release date specified?
+			if release.ParsedDate != nil {
+
+				nextWeek := cdate.AddDate(0, 0, 7)
+				tomorrow := cdate.AddDate(0, 0, 1)
+
+				if sameDay(cdate, *release.ParsedDate) {
+					if announce {
+						session.SendMessage(channelData.ChannelID, release.Name+" released today!")
 					}
 				} else {
+					//Regardless if we notify, add to the new list
 					tempChannelReleases = append(tempChannelReleases, release)
+
+					//Notify if appropriate!
+					if announce && sameDay(nextWeek, *release.ParsedDate) {
+						session.SendMessage(channelData.ChannelID, release.Name+" is releasing next week!")
+					} else if announce && sameDay(tomorrow, *release.ParsedDate) {
+						session.SendMessage(channelData.ChannelID, release.Name+" is releasing tomorrow!")
+					}
 				}
+			} else {
+				tempChannelReleases = append(tempChannelReleases, release)
 			}
+		}
 
-			if len(tempChannelReleases) != len(channelData.Releases) {
-				channelData.Releases = tempChannelReleases
-				rh.updateChannelPin(channelData.ChannelID)
-				changed = true
-			}
+		if len(tempChannelReleases) != len(channelData.Releases) {
+			channelData.Releases = tempChannelReleases
+			rh.updateChannelPin(channelData.ChannelID)
+			changed = true
 		}
 	}
 
@@ -222,6 +299,39 @@ func (rh *ReleaseHandler) scheduledTask() {
 	}
 }
 
+//Commands returns the commands owned by this handler
+func (rh *ReleaseHandler) Commands() []Command {
+	releaseID := CommandOption{Name: "id", Description: "Release ID, from /rw list", Type: discordgo.ApplicationCommandOptionInteger, Required: true, Autocomplete: true}
+	date := CommandOption{Name: "date", Description: "Release date, eg 10/20/35 or Q32025", Type: discordgo.ApplicationCommandOptionString, Required: true}
+
+	return []Command{{
+		Prefix:      rwCommand,
+		Description: "Release Watch - Tracks upcoming releases",
+		Subcommands: []Subcommand{
+			{Name: "add", Description: "Track a new release", Options: []CommandOption{
+				date,
+				{Name: "release", Description: "What's releasing", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "list", Description: "List all tracked releases"},
+			{Name: "edit", Description: "Change a release's date", Options: []CommandOption{releaseID, date}, Capability: "releases.edit"},
+			{Name: "delete", Description: "Stop tracking a release", Options: []CommandOption{releaseID}, Capability: "releases.delete"},
+			{Name: "tz", Description: "Change the time zone release dates roll over in", Capability: "releases.edit", Options: []CommandOption{
+				{Name: "zone", Description: "Time zone, eg America/New_York, or default to use the server's", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "help", Description: "Show release watch help"},
+		},
+	}}
+}
+
+//Autocomplete suggests release IDs for the channel
+func (rh *ReleaseHandler) Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	if option == "id" {
+		return rh.completions.Match(channelID, partial)
+	}
+
+	return nil
+}
+
 //Help Gets info about this release handler
 func (rh *ReleaseHandler) Help() string {
 	return "/rw : Release Watch - Tracks upcoming releases and notifies when they've arrived"
@@ -234,51 +344,98 @@ func (rh *ReleaseHandler) writeData() {
 	for _, channelData := range rh.releases {
 		channelDataSlice = append(channelDataSlice, *channelData)
 	}
-	//..which we then byte-ify and write to disk
-	jsonBytes, err := json.Marshal(channelDataSlice)
-	if err == nil {
-		ioutil.WriteFile(dataFile, jsonBytes, 0644)
+	//..which we then save
+	if err := rh.store.Save(releaseDocument, channelDataSlice); err != nil {
+		rh.log.Error("Error saving release data", "error", err)
+	}
+
+	rh.updateCompletions()
+}
+
+//updateCompletions publishes our release IDs for slash command autocomplete
+func (rh *ReleaseHandler) updateCompletions() {
+	for channelID, channelData := range rh.releases {
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(channelData.Releases))
+		for x, release := range channelData.Releases {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  strconv.Itoa(x) + ": " + release.Name + " (" + release.ReleaseDate + ")",
+				Value: x,
+			})
+		}
+		rh.completions.Set(channelID, choices)
 	}
 }
 
-func (rh *ReleaseHandler) add(channelID string, data string) {
+func (rh *ReleaseHandler) add(channelID string, guildID string, data string) {
 	match := rh.addMatcher.FindStringSubmatch(data)
 	if match != nil {
-		releaseInfo := releaseData{}
-		releaseInfo.ReleaseDate = match[1]
-		releaseInfo.Name = match[2]
-		rh.updateReleaseTime(&releaseInfo)
-
-		if releaseInfo.ParsedDate != nil {
-			now := time.Now()
-			if !now.Before(*releaseInfo.ParsedDate) {
-				MessageSender.SendMessage(channelID, "Error: Specified date \""+match[1]+"\" is in the past!")
-				return
-			}
+		releaseInfo, err := rh.addRelease(channelID, guildID, match[1], match[2])
+		if err != nil {
+			rh.session.SendMessage(channelID, "Error: "+err.Error()+"!")
+			return
 		}
 
-		channel, ok := rh.releases[channelID]
-		if !ok {
-			channel = rh.initChannel(channelID)
-		}
+		rh.session.SendMessage(channelID, "Added "+releaseInfo.Name+" to releases, releasing "+releaseInfo.ReleaseDate)
+	} else {
+		rh.session.SendMessage(channelID, "Invalid add syntax")
+	}
 
-		channel.Releases = append(channel.Releases, releaseInfo)
-		sort.Stable(byReleaseDate(channel.Releases))
+}
 
-		rh.writeData()
-		rh.updateChannelPin(channelID)
-		MessageSender.SendMessage(channelID, "Added "+releaseInfo.Name+" to releases, releasing "+releaseInfo.ReleaseDate)
-	} else {
-		MessageSender.SendMessage(channelID, "Invalid add syntax")
+//addRelease tracks a new release in the channel, saving and updating the pins
+func (rh *ReleaseHandler) addRelease(channelID string, guildID string, releaseDate string, name string) (releaseData, error) {
+	releaseInfo := releaseData{}
+	releaseInfo.ReleaseDate = releaseDate
+	releaseInfo.Name = name
+	rh.updateReleaseTime(&releaseInfo)
+
+	if releaseInfo.ParsedDate != nil {
+		//The release date starts at midnight in the channel's time zone
+		parsed := *releaseInfo.ParsedDate
+		released := time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, rh.location(channelID, guildID))
+		if !time.Now().Before(released) {
+			return releaseInfo, errors.New("Specified date \"" + releaseDate + "\" is in the past")
+		}
 	}
 
+	channel, ok := rh.releases[channelID]
+	if !ok {
+		channel = rh.initChannel(channelID)
+	}
+	//Messages without a guild, eg from the console, don't know better than what we stored
+	if guildID != "" {
+		channel.GuildID = guildID
+	}
+
+	channel.Releases = append(channel.Releases, releaseInfo)
+	sort.Stable(byReleaseDate(channel.Releases))
+
+	//Pin first, so the save includes any message it had to pin
+	rh.updateChannelPin(channelID)
+	rh.writeData()
+	rh.audit("add", nil, releaseInfo)
+	return releaseInfo, nil
 }
 
 func (rh *ReleaseHandler) list(channelID string) {
-	formattedChannelRelease := rh.formatChannelReleases(channelID)
-	MessageSender.SendMessage(channelID, formattedChannelRelease)
+	embed := newEmbed("Tracked releases")
+	if channelData, ok := rh.releases[channelID]; ok {
+		for x, release := range channelData.Releases {
+			releaseDate := release.ReleaseDate
+			if release.ParsedDate != nil {
+				releaseDate = release.ParsedDate.Format("01-02-2006")
+			}
+			embed.Fields = append(embed.Fields, embedField("["+strconv.Itoa(x)+"] "+release.Name, releaseDate, true))
+		}
+	}
+	if len(embed.Fields) == 0 {
+		embed.Description = "<No tracked releases>"
+	}
+
+	sendPaged(rh.session, channelID, embed)
 }
 
+//formatChannelReleases lists the channel's releases as text, for the pinned summary
 func (rh *ReleaseHandler) formatChannelReleases(channelID string) string {
 	list := "Here are my currently tracked releases:\n"
 
@@ -307,71 +464,92 @@ func (rh *ReleaseHandler) edit(channelID string, data string) {
 	if match != nil {
 		index, err := strconv.Atoi(match[1])
 		if err == nil {
-			newReleaseDate := match[2]
-			if channelData, ok := rh.releases[channelID]; ok {
-
-				slice := channelData.Releases
-				if slice != nil {
-					if len(slice) > index || index < 0 {
-						entry := &slice[index]
-						entryName := entry.Name
-						entry.ReleaseDate = newReleaseDate
-						rh.updateReleaseTime(entry)
-						sort.Stable(byReleaseDate(slice))
-
-						rh.updateChannelPin(channelData.ChannelID)
-						rh.writeData()
-						MessageSender.SendMessage(channelID, "Successfully updated release date for "+entryName)
-					} else {
-						//invalid index provided
-						MessageSender.SendMessage(channelID, "Invalid ID specified")
-					}
-				} else {
-					//Channel has no releases?
-					MessageSender.SendMessage(channelID, "No releases currently available to edit")
-				}
+			entry, err := rh.editRelease(channelID, index, match[2])
+			if err == errNoReleases {
+				rh.session.SendMessage(channelID, "No releases currently available to edit")
+			} else if err != nil {
+				rh.session.SendMessage(channelID, "Invalid ID specified")
 			} else {
-				//Channel data doesn't exist (yet), hence no releases to edit
-				MessageSender.SendMessage(channelID, "No releases currently available to edit")
+				rh.session.SendMessage(channelID, "Successfully updated release date for "+entry.Name)
 			}
 		} else {
 			//error parsing index
-			MessageSender.SendMessage(channelID, "Could not parse ID: "+match[1])
+			rh.session.SendMessage(channelID, "Could not parse ID: "+match[1])
 		}
 	} else {
 		//Invalid command format!
-		MessageSender.SendMessage(channelID, "Invalid parameters for /rw edit, please see help")
+		rh.session.SendMessage(channelID, "Invalid parameters for edit, please see help")
 	}
 }
 
+//editRelease changes when a release comes out, saving and updating the pins
+func (rh *ReleaseHandler) editRelease(channelID string, index int, releaseDate string) (releaseData, error) {
+	channelData, err := rh.release(channelID, index)
+	if err != nil {
+		return releaseData{}, err
+	}
+
+	slice := channelData.Releases
+	entry := &slice[index]
+	before := *entry
+	entry.ReleaseDate = releaseDate
+	rh.updateReleaseTime(entry)
+	edited := *entry
+	sort.Stable(byReleaseDate(slice))
+
+	rh.updateChannelPin(channelData.ChannelID)
+	rh.writeData()
+	rh.audit("edit", before, edited)
+	return edited, nil
+}
+
 func (rh *ReleaseHandler) delete(channelID string, data string) {
 	match := rh.deleteMatcher.FindStringSubmatch(data)
 	if match != nil {
 		index, err := strconv.Atoi(match[1])
 		if err == nil {
-			if channelData, ok := rh.releases[channelID]; ok {
-				if channelData.Releases != nil {
-					if len(channelData.Releases) > index || index < 0 {
-						removedRelease := channelData.Releases[index]
-						fmt.Println("Removing " + removedRelease.Name + " (" + removedRelease.ReleaseDate + ") from releases")
-						channelData.Releases = append(channelData.Releases[:index], channelData.Releases[index+1:]...)
-						rh.updateChannelPin(channelData.ChannelID)
-						rh.writeData()
-						MessageSender.SendMessage(channelID, "Removed "+removedRelease.Name+" from releases")
-					} else {
-						MessageSender.SendMessage(channelID, "Error: Invalid ID specified")
-					}
-				} else {
-					MessageSender.SendMessage(channelID, "Error: Channel does not have any releases to delete!")
-				}
+			removedRelease, err := rh.deleteRelease(channelID, index)
+			if err == errNoReleases {
+				rh.session.SendMessage(channelID, "Error: Channel does not have any releases to delete!")
+			} else if err != nil {
+				rh.session.SendMessage(channelID, "Error: Invalid ID specified")
 			} else {
-				MessageSender.SendMessage(channelID, "Error: Channel does not have any releases to delete!")
+				rh.session.SendMessage(channelID, "Removed "+removedRelease.Name+" from releases")
 			}
 		}
 	}
 }
 
-func (rh *ReleaseHandler) help(channelID string) {
+//deleteRelease stops tracking a release, saving and updating the pins
+func (rh *ReleaseHandler) deleteRelease(channelID string, index int) (releaseData, error) {
+	channelData, err := rh.release(channelID, index)
+	if err != nil {
+		return releaseData{}, err
+	}
+
+	removedRelease := channelData.Releases[index]
+	rh.log.Info("Removing release", "release", removedRelease.Name, "date", removedRelease.ReleaseDate)
+	channelData.Releases = append(channelData.Releases[:index], channelData.Releases[index+1:]...)
+	rh.updateChannelPin(channelData.ChannelID)
+	rh.writeData()
+	rh.audit("delete", removedRelease, nil)
+	return removedRelease, nil
+}
+
+//release finds the channel holding the release, checking the index is one of its releases
+func (rh *ReleaseHandler) release(channelID string, index int) (*channelReleaseData, error) {
+	channelData, ok := rh.releases[channelID]
+	if !ok || len(channelData.Releases) == 0 {
+		return nil, errNoReleases
+	}
+	if index < 0 || index >= len(channelData.Releases) {
+		return nil, errInvalidRelease
+	}
+
+	return channelData, nil
+}
+
+func (rh *ReleaseHandler) help(channelID string, guildID string) {
 	helpMessage := "The following commands are supported by /rw:\n"
 	helpMessage += "/rw add <date> <release> - Adds the following release for tracking.\n"
 	helpMessage += "\t<date> can be in the following formats: MM/DD/YYYY MM-DD-YY\n"
@@ -381,9 +559,10 @@ func (rh *ReleaseHandler) help(channelID string) {
 	helpMessage += "\tID can be obtained from /rw list\n"
 	helpMessage += "\teg: /rw edit 12 5/16/2024\n"
 	helpMessage += "/rw delete <id> - Delete the specified release!\n\teg: /rw delete 5\n"
+	helpMessage += "/rw tz <zone> - Change the time zone this channel's release dates roll over in\n\teg: /rw tz America/New_York, or /rw tz default to use the server's\n"
 	helpMessage += "/rw help - This output here!"
 
-	MessageSender.SendMessage(channelID, helpMessage)
+	rh.session.SendMessage(channelID, localizeCommands(guildID, helpMessage))
 }
 
 func (rh *ReleaseHandler) updateReleaseTime(rel *releaseData) {
@@ -396,7 +575,7 @@ func (rh *ReleaseHandler) updateReleaseTime(rel *releaseData) {
 		year, err3 := strconv.Atoi(dateMatch[3])
 
 		if err1 != nil || err2 != nil || err3 != nil {
-			fmt.Println("Error parsing dates for release notification")
+			rh.log.Error("Error parsing dates for release notification")
 			return
 		}
 
@@ -410,30 +589,90 @@ func (rh *ReleaseHandler) updateReleaseTime(rel *releaseData) {
 	}
 }
 
+//updateChannelPin rewrites the pinned summary, pinning more messages as it grows and removing ones it no longer needs
 func (rh *ReleaseHandler) updateChannelPin(channelID string) {
-	message := rh.formatChannelReleases(channelID)
+	chunks := splitMessage(rh.formatChannelReleases(channelID), maxMessageLength)
 
 	channel, ok := rh.releases[channelID]
 	if !ok {
 		channel = rh.initChannel(channelID)
 	}
 
-	if channel.PinnedMessageID != "" {
-		MessageSender.EditMessage(channel.ChannelID, channel.PinnedMessageID, message)
-	} else {
-		//We need to blast out our release entries and then set our message id for this channel
-		//If we error, do *not* set our pin message id
-		sentMessage, error := MessageSender.SendMessage(channelID, message)
-		if error == nil {
-			id := sentMessage.ID
-			pinError := MessageSender.PinMessage(channel.ChannelID, id)
-			if pinError == nil {
-				channel.PinnedMessageID = id
-			}
-		} else {
-			fmt.Println("Error sending message: " + error.Error())
+	pinned := channel.PinnedMessageIDs
+	for i, chunk := range chunks {
+		if i < len(pinned) {
+			rh.session.EditMessage(channel.ChannelID, pinned[i], chunk)
+			continue
+		}
+
+		//We need to blast out our release entries and then add the message id for this channel
+		//If we error, do *not* add it, and try again next update
+		sentMessage, error := rh.session.SendMessage(channelID, chunk)
+		if error != nil {
+			rh.log.Error("Error sending release summary", "channel", channelID, "error", error)
+			return
+		}
+		if pinError := rh.session.PinMessage(channel.ChannelID, sentMessage.ID); pinError != nil {
+			return
+		}
+		channel.PinnedMessageIDs = append(channel.PinnedMessageIDs, sentMessage.ID)
+	}
+
+	if len(chunks) < len(pinned) {
+		for _, id := range pinned[len(chunks):] {
+			rh.session.DeleteMessage(channel.ChannelID, id)
+		}
+		channel.PinnedMessageIDs = pinned[:len(chunks)]
+	}
+}
+
+func (rh *ReleaseHandler) setTimeZone(channelID string, guildID string, data string) {
+	zone, err := validateTimeZone(strings.TrimSpace(data))
+	if err != nil {
+		rh.session.SendMessage(channelID, "Can't use \""+data+"\": "+err.Error())
+		return
+	}
+
+	channel, ok := rh.releases[channelID]
+	if !ok {
+		channel = rh.initChannel(channelID)
+	}
+	before := channel.TimeZone
+	if guildID != "" {
+		channel.GuildID = guildID
+	}
+	channel.TimeZone = zone
+
+	rh.writeData()
+	rh.audit("tz", before, zone)
+	rh.session.SendMessage(channelID, "Release dates in this channel now roll over at midnight "+rh.location(channelID, guildID).String())
+}
+
+//channelGuild finds the channel's guild from its releases, asking discord about channels without any
+func (rh *ReleaseHandler) channelGuild(channelID string) string {
+	if channel, ok := rh.releases[channelID]; ok && channel.GuildID != "" {
+		return channel.GuildID
+	}
+
+	return channelGuild(rh.session, channelID)
+}
+
+//location returns the channel's time zone, falling back to its guild's
+func (rh *ReleaseHandler) location(channelID string, guildID string) *time.Location {
+	if channel, ok := rh.releases[channelID]; ok {
+		if channel.GuildID != "" {
+			guildID = channel.GuildID
 		}
+
+		return itemLocation(channel.TimeZone, userLocation(guildID, ""))
 	}
+
+	return userLocation(guildID, "")
+}
+
+//sameDay checks if both times fall on the same calendar date, each in their own time zone
+func sameDay(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
 }
 
 func (rh *ReleaseHandler) initChannel(channelID string) *channelReleaseData {
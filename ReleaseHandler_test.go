@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//daysAhead is midnight the given number of days from now, so releases added in tests are never already out
+func daysAhead(days int) time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day()+days, 0, 0, 0, 0, time.Local)
+}
+
+func TestReleaseAddPinsAndConfirms(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	release := daysAhead(30)
+	harness.Say("chan", "user", "/rw add "+release.Format("01/02/06")+" Persona 8")
+
+	calls := session.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 calls, got %+v", calls)
+	}
+
+	//Pinned summary first, then the confirmation, then the command is cleaned up
+	if calls[0].Action != "send" || calls[1].Action != "pin" || calls[1].MessageID != calls[0].MessageID {
+		t.Errorf("expected summary to be sent and pinned, got %+v", calls[:2])
+	}
+	assertContains(t, calls[0].Content, release.Format("01-02-2006")+" Persona 8 [0]")
+	assertContains(t, calls[2].Content, "Added Persona 8 to releases, releasing "+release.Format("01/02/06"))
+	if calls[3].Action != "delete" || calls[3].MessageID != "in1" {
+		t.Errorf("expected invoking message to be deleted, got %+v", calls[3])
+	}
+}
+
+func TestReleaseAddRejectsPastDates(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/rw add 01/01/2001 Old News")
+
+	assertContains(t, session.LastSent("chan"), "is in the past")
+	if session.Count("pin") != 0 {
+		t.Error("past release should not be pinned")
+	}
+}
+
+func TestReleaseEditResortsAndUpdatesPin(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	first, second, edited := daysAhead(30), daysAhead(60), daysAhead(90)
+	harness.Say("chan", "user", "/rw add "+first.Format("01/02/06")+" First")
+	harness.Say("chan", "user", "/rw add "+second.Format("01/02/06")+" Second")
+	session.Reset()
+
+	harness.Say("chan", "user", "/rw edit 0 "+edited.Format("01/02/06"))
+
+	edits := 0
+	for _, call := range session.Calls() {
+		if call.Action == "edit" {
+			edits++
+			assertContains(t, call.Content, second.Format("01-02-2006")+" Second [0]\n"+edited.Format("01-02-2006")+" First [1]")
+		}
+	}
+	if edits != 1 {
+		t.Errorf("expected pinned message to be edited once, got %d", edits)
+	}
+	assertContains(t, session.LastSent("chan"), "Successfully updated release date for First")
+
+	harness.Say("chan", "user", "/rw edit 5 "+edited.Format("01/02/06"))
+	assertContains(t, session.LastSent("chan"), "Invalid ID specified")
+}
+
+func TestReleaseDelete(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/rw delete 0")
+	assertContains(t, session.LastSent("chan"), "does not have any releases")
+
+	harness.Say("chan", "user", "/rw add Q1"+strconv.Itoa(time.Now().Year()+1)+" Someday")
+	harness.Say("chan", "user", "/rw delete 3")
+	assertContains(t, session.LastSent("chan"), "Invalid ID specified")
+
+	harness.Say("chan", "user", "/rw delete 0")
+	assertContains(t, session.LastSent("chan"), "Removed Someday from releases")
+
+	harness.Say("chan", "user", "/rw list")
+	assertContains(t, session.LastSent("chan"), "<No tracked releases>")
+}
+
+func TestReleaseDataPersists(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+	release := daysAhead(30)
+	harness.Say("chan", "user", "/rw add "+release.Format("01/02/06")+" Persona 8")
+
+	reloaded := &FakeSession{}
+	reloadedHandler := NewReleaseHandler(reloaded, NewJSONFileStore("."))
+	reloadedHarness := newHandlerHarness(t, reloadedHandler)
+	reloadedHarness.Say("chan", "user", "/rw list")
+	assertContains(t, reloaded.LastSent("chan"), "[0] Persona 8: "+release.Format("01-02-2006"))
+
+	//The pinned summary is saved along with the release, so it's edited rather than pinned again
+	if pinned := reloadedHandler.releases["chan"].PinnedMessageIDs; len(pinned) != 1 || pinned[0] != session.Calls()[0].MessageID {
+		t.Errorf("expected the summary's pin to be saved, got %v", pinned)
+	}
+
+	reloadedHarness.Say("other", "user", "/rw list")
+	assertContains(t, reloaded.LastSent("other"), "<No tracked releases>")
+}
+
+func TestReleaseAddKeepsTheChannelsGuild(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	handler := NewReleaseHandler(session, NewJSONFileStore("."))
+	harness := newHandlerHarness(t, handler)
+	release := daysAhead(30).Format("01/02/06")
+
+	harness.channel <- guildMessage("guild", "/rw add "+release+" Persona 8")
+	harness.Say("chan", "user", "/rw add "+release+" Persona 9")
+	if guildID := handler.releases["chan"].GuildID; guildID != "guild" {
+		t.Errorf("expected an add without a guild to keep the channel's, got %q", guildID)
+	}
+}
+
+func TestReleaseAnnouncementsRunOnSchedule(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	handler := NewReleaseHandler(session, NewJSONFileStore("."))
+	harness := newHandlerHarness(t, handler)
+
+	today := daysAhead(30)
+	harness.Say("chan", "user", "/rw add "+today.Format("01/02/06")+" Persona 8")
+	harness.Say("chan", "user", "/rw add "+today.AddDate(0, 0, 7).Format("01/02/06")+" Persona 9")
+
+	job := handler.Jobs()[0]
+	job.Queue <- func() {
+		job.Run(JobRun{Occurrence: time.Date(today.Year(), today.Month(), today.Day(), 11, 0, 0, 0, time.Local), Session: session})
+	}
+	harness.Say("chan", "user", "")
+
+	sent := strings.Join(session.Sent("chan"), "\n")
+	assertContains(t, sent, "Persona 8 released today!")
+	assertContains(t, sent, "Persona 9 is releasing next week!")
+}
+
+func TestReleaseEditAndDeleteRejectOutOfRangeIDs(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "user", "/rw add "+daysAhead(30).Format("01/02/06")+" Persona 8")
+
+	harness.Say("chan", "user", "/rw edit 1 "+daysAhead(31).Format("01/02/06"))
+	assertContains(t, session.LastSent("chan"), "Invalid ID specified")
+
+	harness.Say("chan", "user", "/rw delete 5")
+	assertContains(t, session.LastSent("chan"), "Invalid ID specified")
+}
+
+func TestReleasePinsGrowAndShrinkWithTheSummary(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	handler := NewReleaseHandler(session, NewJSONFileStore("."))
+	harness := newHandlerHarness(t, handler)
+
+	//Long names, so the summary spills over into a second message
+	for i := 0; i < 20; i++ {
+		harness.Say("chan", "user", "/rw add "+daysAhead(30).Format("01/02/06")+" Game "+strconv.Itoa(i)+" "+strings.Repeat("x", 100))
+	}
+	pinned := append([]string{}, handler.releases["chan"].PinnedMessageIDs...)
+	if len(pinned) != 2 || session.Count("pin") != 2 {
+		t.Fatalf("expected the summary to be pinned across two messages, got %v", pinned)
+	}
+
+	session.Reset()
+	for i := 0; i < 15; i++ {
+		harness.Say("chan", "user", "/rw delete 0")
+	}
+	if ids := handler.releases["chan"].PinnedMessageIDs; len(ids) != 1 || ids[0] != pinned[0] {
+		t.Errorf("expected to be back down to the first pin, got %v", ids)
+	}
+	deleted := false
+	for _, call := range session.Calls() {
+		deleted = deleted || (call.Action == "delete" && call.MessageID == pinned[1])
+	}
+	if !deleted {
+		t.Error("expected the second pinned message to be removed")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+//liveSettings Are the configuration settings a reload applies. Changing any others needs a restart
+var liveSettings = map[string]bool{"Name": true, "Owners": true, "Shutdown": true, "Log": true, "TimeZone": true}
+
+//reloadableHandler Is implemented by handlers which can re-read their saved data while running
+type reloadableHandler interface {
+	MessageHandler
+	//Reload re-reads the handler's document, returning its name. If that fails the handler keeps what it had
+	//It's run on the handler's goroutine
+	Reload() (string, error)
+	do(work func()) error
+}
+
+//reloadResult Is how reloading one file went
+type reloadResult struct {
+	File string
+	Err  error
+	//Restart lists settings which changed, but won't take effect until we restart
+	Restart []string
+}
+
+//Reloader Re-reads configuration and handler data without restarting, so we stay connected and don't miss any jobs
+type Reloader struct {
+	mutex         sync.Mutex
+	path          string
+	configuration Configuration
+	permissions   *PermissionStore
+	//handlers are the running handlers, whose data is reloaded after the configuration
+	handlers []MessageHandler
+}
+
+//NewReloader creates a reloader for the configuration file, which was last read as configuration
+func NewReloader(path string, configuration Configuration, permissions *PermissionStore) *Reloader {
+	return &Reloader{path: path, configuration: configuration, permissions: permissions}
+}
+
+//Configuration returns the configuration we're running with
+func (r *Reloader) Configuration() Configuration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.configuration
+}
+
+//Reload re-reads the configuration, then each handler's data, reporting how each file went
+func (r *Reloader) Reload() []reloadResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	results := []reloadResult{r.reloadConfiguration()}
+	for _, handler := range r.handlers {
+		reloadable, ok := handler.(reloadableHandler)
+		if !ok {
+			continue
+		}
+
+		result := reloadResult{File: handler.GetName()}
+		if err := reloadable.do(func() { result.File, result.Err = reloadable.Reload() }); err != nil {
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			logger.Error("Error reloading, keeping what we had", "file", result.File, "error", result.Err)
+		} else if len(result.Restart) > 0 {
+			logger.Warn("Reloaded, but some changes need a restart", "file", result.File, "settings", strings.Join(result.Restart, ","))
+		} else {
+			logger.Info("Reloaded", "file", result.File)
+		}
+	}
+
+	return results
+}
+
+//reloadConfiguration applies the live settings from the configuration file, if they're all valid
+func (r *Reloader) reloadConfiguration() reloadResult {
+	result := reloadResult{File: r.path}
+	configuration, err := loadConfiguration(r.path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	//Check everything before changing anything, so a mistake doesn't leave us half reconfigured
+	if problems := validateConfiguration(configuration, false); len(problems) > 0 {
+		result.Err = problems[0]
+		return result
+	}
+	location, err := configuredLocation(configuration.TimeZone)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	configured, err := NewConfiguredLogger(configuration.Log)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	logger.Reconfigure(configured)
+	r.permissions.SetOwners(configuration.Owners)
+	if guildSettingsStore != nil {
+		guildSettingsStore.SetDefaults("!"+configuration.Name, location)
+	}
+	result.Restart = applyLiveSettings(&r.configuration, configuration)
+
+	return result
+}
+
+//applyLiveSettings copies the live settings from updated, returning the names of any others which changed
+func applyLiveSettings(current *Configuration, updated Configuration) []string {
+	restart := make([]string, 0)
+	currentValue := reflect.ValueOf(current).Elem()
+	updatedValue := reflect.ValueOf(updated)
+	for x := 0; x < currentValue.NumField(); x++ {
+		name := currentValue.Type().Field(x).Name
+		if liveSettings[name] {
+			currentValue.Field(x).Set(updatedValue.Field(x))
+		} else if !reflect.DeepEqual(currentValue.Field(x).Interface(), updatedValue.Field(x).Interface()) {
+			restart = append(restart, name)
+		}
+	}
+
+	return restart
+}
+
+//formatReloadResults describes how the reload went, a line per file
+func formatReloadResults(results []reloadResult) string {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			lines = append(lines, "Kept the old "+result.File+", it failed to load: "+result.Err.Error())
+			continue
+		}
+
+		line := "Reloaded " + result.File
+		if len(result.Restart) > 0 {
+			line += ", restart to apply changes to " + strings.Join(result.Restart, ", ")
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+//watchReloads reloads whenever we're sent SIGHUP, until the returned function is called
+func watchReloads(reloader *Reloader) func() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				logger.Info("Reloading")
+				reloader.Reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//ReloadHandler lets bot owners reload configuration and saved data without restarting
+type ReloadHandler struct {
+	handlerLoop
+	session     Session
+	reloader    *Reloader
+	permissions *PermissionStore
+	matcher     regexp.Regexp
+}
+
+const reloadCommand = "/reload"
+
+//NewReloadHandler creates a handler which reloads through the provided reloader, for bot owners in the permissions
+func NewReloadHandler(session Session, reloader *Reloader, permissions *PermissionStore) *ReloadHandler {
+	return &ReloadHandler{session: session, reloader: reloader, permissions: permissions}
+}
+
+//Init compiles our regexp and spins up our channel handling
+func (rh *ReloadHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	rh.matcher = *regexp.MustCompile(`^` + reloadCommand + `\s*$`)
+
+	rh.listen(ctx, rh.GetName(), m, rh.handleMessage)
+}
+
+//GetName returns our name
+func (rh *ReloadHandler) GetName() string {
+	return "Reload Handler"
+}
+
+//Commands returns the commands owned by this handler
+//Guild admins can use every capability, so owners are checked when the command is handled instead
+func (rh *ReloadHandler) Commands() []Command {
+	return []Command{{Prefix: reloadCommand, Description: "Reload configuration and saved data (bot owners only)"}}
+}
+
+//Help Gets info about this handler
+func (rh *ReloadHandler) Help() string {
+	return reloadCommand + " : Reload - Re-read configuration and saved data (bot owners only)"
+}
+
+func (rh *ReloadHandler) handleMessage(m *discordgo.MessageCreate) {
+	if !rh.matcher.MatchString(m.Content) {
+		return
+	}
+
+	if !rh.permissions.IsOwner(m.Author.ID) {
+		rh.session.SendMessage(m.ChannelID, "Only bot owners can reload")
+		return
+	}
+
+	results := formatReloadResults(rh.reloader.Reload())
+	rh.audit("reload", nil, results)
+	rh.session.SendMessage(m.ChannelID, results)
+}
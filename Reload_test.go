@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadKeepsDataWhichFailsToLoad(t *testing.T) {
+	useTempDir(t)
+	writeTestFile(t, reactionDocument+".json", `[{"TriggerWord": "hello", "Reaction": "👋"}]`)
+	handler := NewReactionHandler(&FakeSession{}, NewJSONFileStore("."))
+	newHandlerHarness(t, handler)
+	reloader := NewReloader("diskhard.json", Configuration{}, NewPermissionStore(&FakeSession{}, NewJSONFileStore("."), nil))
+	reloader.handlers = []MessageHandler{handler}
+
+	//A backup exists, but a reload should report the broken edit rather than quietly use it
+	NewJSONFileStore(".").Save(reactionDocument, []reactionData{{TriggerWord: "hello", Reaction: "👋"}})
+	writeTestFile(t, reactionDocument+".json", "[\n{\"TriggerWord\": \"bye\",\n}]")
+
+	results := reloader.Reload()
+	if len(results) != 2 || results[0].Err == nil || results[1].File != reactionDocument || results[1].Err == nil {
+		t.Fatalf("expected the missing configuration and broken reactions to be reported, got %+v", results)
+	}
+	assertContains(t, results[1].Err.Error(), "line 3")
+
+	var triggers []reactionData
+	handler.do(func() { triggers = handler.reactions() })
+	if len(triggers) != 1 || triggers[0].TriggerWord != "hello" {
+		t.Errorf("expected the loaded reactions to be kept, got %v", triggers)
+	}
+
+	writeTestFile(t, reactionDocument+".json", `[{"TriggerWord": "bye", "Reaction": "👋"}, {"TriggerWord": "(", "Reaction": "😕"}]`)
+	if results = reloader.Reload(); results[1].Err == nil {
+		t.Error("expected a trigger word which won't compile to fail the reload")
+	}
+
+	writeTestFile(t, reactionDocument+".json", `[{"TriggerWord": "bye", "Reaction": "👋"}]`)
+	if results = reloader.Reload(); results[1].Err != nil {
+		t.Fatalf("expected the reactions to reload, got %v", results[1].Err)
+	}
+	handler.do(func() { triggers = handler.reactions() })
+	if len(triggers) != 1 || triggers[0].TriggerWord != "bye" {
+		t.Errorf("expected the new reactions to be used, got %v", triggers)
+	}
+}
+
+func TestReloadValidatesHandlerData(t *testing.T) {
+	useTempDir(t)
+	writeTestFile(t, reminderDocument+".json", `[{"channelID": "chan", "reminders": [{"name": "Standup", "hour": 9, "minute": 0, "days": [1]}]}]`)
+	handler := NewReminderHandler(&FakeSession{}, NewJSONFileStore("."))
+	newHandlerHarness(t, handler)
+	reloader := NewReloader("diskhard.json", Configuration{}, NewPermissionStore(&FakeSession{}, NewJSONFileStore("."), nil))
+	reloader.handlers = []MessageHandler{handler}
+
+	writeTestFile(t, reminderDocument+".json", `[{"channelID": "chan", "reminders": [{"name": "Standup", "hour": 29, "minute": 0, "days": [1]}]}]`)
+	results := reloader.Reload()
+	if results[1].Err == nil {
+		t.Fatal("expected the invalid hour to fail the reload")
+	}
+	assertContains(t, results[1].Err.Error(), "reminder 0 in channel chan")
+
+	var reminder *Reminder
+	handler.do(func() { reminder, _ = handler.reminder("chan", 0) })
+	if reminder == nil || reminder.Hour != 9 {
+		t.Errorf("expected the loaded reminder to be kept, got %+v", reminder)
+	}
+}
+
+func TestReloadAppliesLiveSettings(t *testing.T) {
+	useTempDir(t)
+	settings := NewGuildSettingsStore(NewJSONFileStore("."), "!old", time.UTC)
+	guildSettingsStore = settings
+	t.Cleanup(func() { guildSettingsStore = nil })
+
+	permissions := NewPermissionStore(&FakeSession{}, NewJSONFileStore("."), []string{"old"})
+	reloader := NewReloader("diskhard.json", Configuration{Token: "abc", Name: "old"}, permissions)
+	writeTestFile(t, "diskhard.json", `{"Token": "def", "Name": "new", "Owners": ["new"], "TimeZone": "Asia/Tokyo", "Shutdown": {"Message": "Back soon"}}`)
+
+	results := reloader.Reload()
+	if results[0].Err != nil {
+		t.Fatal(results[0].Err)
+	}
+	if len(results[0].Restart) != 1 || results[0].Restart[0] != "Token" {
+		t.Errorf("expected only the token to need a restart, got %v", results[0].Restart)
+	}
+	if permissions.IsOwner("old") || !permissions.IsOwner("new") {
+		t.Error("expected the owners to be replaced")
+	}
+	if settings.HelpTrigger("guild") != "!new" || settings.TimeZone("guild").String() != "Asia/Tokyo" {
+		t.Errorf("expected the guild defaults to change, got %s %s", settings.HelpTrigger("guild"), settings.TimeZone("guild"))
+	}
+	if current := reloader.Configuration(); current.Token != "abc" || current.Shutdown.Message != "Back soon" {
+		t.Errorf("expected the token to be kept and the shutdown message replaced, got %+v", current)
+	}
+
+	writeTestFile(t, "diskhard.json", `{"Owners": ["broken"], "TimeZone": "Nowhere/Special"}`)
+	if results = reloader.Reload(); results[0].Err == nil || permissions.IsOwner("broken") {
+		t.Error("expected a configuration with an invalid zone not to be applied")
+	}
+}
+
+func TestReloadIsOwnerOnly(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	permissions := NewPermissionStore(session, NewJSONFileStore("."), []string{"owner"})
+	h := newHandlerHarness(t, NewReloadHandler(session, NewReloader("diskhard.json", Configuration{}, permissions), permissions))
+
+	h.Say("chan", "someone", "/reload")
+	assertContains(t, session.LastSent("chan"), "Only bot owners can reload")
+
+	writeTestFile(t, "diskhard.json", `{"Owners": ["owner"]}`)
+	h.Say("chan", "owner", "/reload")
+	assertContains(t, session.LastSent("chan"), "Reloaded diskhard.json")
+}
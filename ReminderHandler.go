@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -13,39 +14,64 @@ import (
 
 //ReminderHandler Echoes messages to stdout
 type ReminderHandler struct {
+	handlerLoop
+	session Session
+	store   Store
+
 	matcher              regexp.Regexp
 	addMatcher           regexp.Regexp
 	addRemoveUserMatcher regexp.Regexp
 	editMatcher          regexp.Regexp
 	deleteMatcher        regexp.Regexp
+	timeZoneMatcher      regexp.Regexp
 
 	channelReminders map[string]*channelReminderData
 	dayMap           map[rune]time.Weekday
+	completions      completionCache
+}
+
+//NewReminderHandler creates a handler which communicates through the provided session and saves to the store
+func NewReminderHandler(session Session, store Store) *ReminderHandler {
+	return &ReminderHandler{session: session, store: store}
 }
 
 type Reminder struct {
-	Name      string   `json:"n"`
-	Hour      int      `json:"h"`
-	Minute    int      `json:"m"`
-	Days      []int    `json:"d"`
+	Name      string   `json:"name"`
+	Hour      int      `json:"hour"`
+	Minute    int      `json:"minute"`
+	Days      []int    `json:"days"`
 	Notifyees []string `json:"notifyees"`
+	//TimeZone is the zone the reminder's time is in, usually whoever added it's
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type channelReminderData struct {
-	ChannelID string
-	Reminders []*Reminder
+	ChannelID string      `json:"channelID"`
+	Reminders []*Reminder `json:"reminders"`
+}
+
+//migrateReminderV1 replaces the single letter reminder keys with full names, and tags the channel fields
+func migrateReminderV1(data interface{}) (interface{}, error) {
+	for _, channelData := range jsonObjects(data) {
+		renameKeys(channelData, map[string]string{"ChannelID": "channelID", "Reminders": "reminders"})
+		for _, reminder := range jsonObjects(channelData["reminders"]) {
+			renameKeys(reminder, map[string]string{"n": "name", "h": "hour", "m": "minute", "d": "days"})
+		}
+	}
+
+	return data, nil
 }
 
 const remindCommand string = "/remind"
-const reminderDataFile = "./ReminderData.json"
 
 //Init compiles regexp and loads in saved information
-func (rh *ReminderHandler) Init(m chan *discordgo.MessageCreate) {
+func (rh *ReminderHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
 	rh.matcher = *regexp.MustCompile(`^\` + remindCommand + `\s+(\w+)\s*(.*)$`)
 	rh.addMatcher = *regexp.MustCompile(`^(\d{1,2}):(\d\d) ([U日]?[M月]?[T火]?[W水]?[R木]?[F金]?[S土]?) (.*)$`)
 	rh.addRemoveUserMatcher = *regexp.MustCompile(`^(\d+)$`)
 	rh.editMatcher = *regexp.MustCompile(`^(\d+) ([\w-/]+)`)
 	rh.deleteMatcher = *regexp.MustCompile(`^(\d+)`)
+	rh.timeZoneMatcher = *regexp.MustCompile(`^(\d+)\s+(\S+)$`)
 
 	rh.channelReminders = make(map[string]*channelReminderData)
 	rh.dayMap = make(map[rune]time.Weekday)
@@ -69,37 +95,55 @@ func (rh *ReminderHandler) Init(m chan *discordgo.MessageCreate) {
 	//Need to read in stored json info as well!
 	var data []channelReminderData
 
-	// Get configuration
-	fileData, err := ioutil.ReadFile(reminderDataFile)
-
 	//Load up in-memory cache of this info
+	err := rh.store.Load(reminderDocument, &data)
 	if err == nil {
-		fmt.Println("Reading saved Reminder data")
-		err = json.Unmarshal(fileData, &data)
-		if err == nil {
-			for _, channelData := range data {
-				channelCopy := channelData
-				rh.channelReminders[channelData.ChannelID] = &channelCopy
-			}
+		rh.log.Info("Reading saved Reminder data")
+		for _, channelData := range data {
+			channelCopy := channelData
+			rh.channelReminders[channelData.ChannelID] = &channelCopy
 		}
+	} else if err != ErrNotFound {
+		rh.log.Error("Error loading Reminder data", "error", err)
 	}
+	rh.updateCompletions()
 
-	go func() {
-		minuteSchedule := time.NewTicker(time.Minute)
-		defer minuteSchedule.Stop()
-		for {
-			select {
-			case message := <-m:
-				if message != nil {
-					rh.handleMessage(message)
-				} else {
-					return
-				}
-			case <-minuteSchedule.C:
-				rh.scheduledTask()
+	rh.listen(ctx, rh.GetName(), m, rh.handleMessage)
+}
+
+//Reload re-reads the saved reminders, keeping the ones we have if the file can't be loaded or any reminder is invalid
+func (rh *ReminderHandler) Reload() (string, error) {
+	var data []channelReminderData
+	if err := loadStrict(rh.store, reminderDocument, &data); err != nil && err != ErrNotFound {
+		return reminderDocument, err
+	}
+
+	if err := validateReminders(data); err != nil {
+		return reminderDocument, err
+	}
+
+	channelReminders := make(map[string]*channelReminderData)
+	for _, channelData := range data {
+		channelCopy := channelData
+		channelReminders[channelData.ChannelID] = &channelCopy
+	}
+
+	rh.channelReminders = channelReminders
+	rh.updateCompletions()
+	return reminderDocument, nil
+}
+
+//validateReminders checks every saved reminder, see validateReminder
+func validateReminders(data []channelReminderData) error {
+	for _, channelData := range data {
+		for x, reminder := range channelData.Reminders {
+			if err := validateReminder(reminder); err != nil {
+				return fmt.Errorf("reminder %d in channel %s: %v", x, channelData.ChannelID, err)
 			}
 		}
-	}()
+	}
+
+	return nil
 }
 
 //GetName returns our name
@@ -114,40 +158,48 @@ func (rh *ReminderHandler) handleMessage(m *discordgo.MessageCreate) {
 		command := submatches[1]
 		switch command {
 		case "add":
-			rh.add(m.ChannelID, m.Author.ID, submatches[2])
+			rh.add(m.ChannelID, m.GuildID, m.Author.ID, submatches[2])
 		case "addme":
 			rh.addUser(m.ChannelID, m.Author.ID, submatches[2])
 		case "removeme":
 			rh.removeUser(m.ChannelID, m.Author.ID, submatches[2])
 		case "list":
 			rh.list(m.ChannelID)
+		case "tz":
+			rh.setTimeZone(m.ChannelID, submatches[2])
 		case "help":
-			rh.help(m.ChannelID)
+			rh.help(m.ChannelID, m.GuildID)
 		default:
-			rh.help(m.ChannelID)
+			rh.help(m.ChannelID, m.GuildID)
 		}
 
-		MessageSender.DeleteMessage(m.ChannelID, m.ID)
+		rh.session.DeleteMessage(m.ChannelID, m.ID)
 	}
 }
 
-func (rh *ReminderHandler) scheduledTask() {
-	currentTime := time.Now()
+//Jobs checks for due reminders every minute
+func (rh *ReminderHandler) Jobs() []Job {
+	return []Job{{Name: "reminders", Schedule: Every(time.Minute), Run: rh.scheduledTask, Queue: rh.tasks}}
+}
 
+func (rh *ReminderHandler) scheduledTask(run JobRun) {
+	//Pings go out ahead of anything bulky waiting in the channel
+	session := WithPriority(run.Session, HighPriority)
 	for _, channelData := range rh.channelReminders {
 		for _, rem := range channelData.Reminders {
 
-			//Are we at the allotted time?
-			if currentTime.Hour() == rem.Hour && currentTime.Minute() == rem.Minute {
+			//Are we at the allotted time, in the reminder's time zone?
+			due := dueAt(run.Occurrence, channelItemLocation(rh.session, channelData.ChannelID, rem.TimeZone), rem.Hour, rem.Minute)
+			if due.Equal(run.Occurrence) {
 				//Correct day?
 				for _, weekday := range rem.Days {
-					if weekday == (int)(currentTime.Weekday()) {
+					if weekday == (int)(due.Weekday()) && channelActive(rh.session, rh.GetName(), channelData.ChannelID) {
 						//Send it out!
 						message := rem.Name
 						for _, user := range rem.Notifyees {
 							message += " " + rh.userPingString(user)
 						}
-						MessageSender.SendMessage(channelData.ChannelID, message)
+						session.SendMessage(channelData.ChannelID, message)
 					}
 				}
 			}
@@ -155,6 +207,40 @@ func (rh *ReminderHandler) scheduledTask() {
 	}
 }
 
+//Commands returns the commands owned by this handler
+func (rh *ReminderHandler) Commands() []Command {
+	reminderID := CommandOption{Name: "id", Description: "Reminder ID, from " + remindCommand + " list", Type: discordgo.ApplicationCommandOptionInteger, Required: true, Autocomplete: true}
+
+	return []Command{{
+		Prefix:      remindCommand,
+		Description: "Reminder - Set alarms to ping users",
+		Subcommands: []Subcommand{
+			{Name: "add", Description: "Add a new reminder", Options: []CommandOption{
+				{Name: "time", Description: "HH:MM in 24-hour time", Type: discordgo.ApplicationCommandOptionString, Required: true},
+				{Name: "days", Description: "Any of UMTWRFS, eg TWRF", Type: discordgo.ApplicationCommandOptionString, Required: true},
+				{Name: "reminder", Description: "What to remind about", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "addme", Description: "Get pinged by a reminder", Options: []CommandOption{reminderID}},
+			{Name: "removeme", Description: "Stop getting pinged by a reminder", Options: []CommandOption{reminderID}},
+			{Name: "list", Description: "List all channel reminders"},
+			{Name: "tz", Description: "Change the time zone a reminder's time is in", Options: []CommandOption{
+				reminderID,
+				{Name: "zone", Description: "Time zone, eg America/New_York, or default to use the server's", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "help", Description: "Show reminder help"},
+		},
+	}}
+}
+
+//Autocomplete suggests reminder IDs for the channel
+func (rh *ReminderHandler) Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	if option == "id" {
+		return rh.completions.Match(channelID, partial)
+	}
+
+	return nil
+}
+
 //Help Gets info about this Reminder handler
 func (rh *ReminderHandler) Help() string {
 	return "/remind : Reminder - Set alarms to ping users!"
@@ -167,67 +253,165 @@ func (rh *ReminderHandler) writeData() {
 	for _, channelData := range rh.channelReminders {
 		channelDataSlice = append(channelDataSlice, channelData)
 	}
-	//..which we then byte-ify and write to disk
-	jsonBytes, err := json.Marshal(channelDataSlice)
-	if err == nil {
-		ioutil.WriteFile(reminderDataFile, jsonBytes, 0644)
+	//..which we then save
+	if err := rh.store.Save(reminderDocument, channelDataSlice); err != nil {
+		rh.log.Error("Error saving Reminder data", "error", err)
 	}
+
+	rh.updateCompletions()
 }
 
-func (rh *ReminderHandler) add(channelID string, user string, data string) {
+//updateCompletions publishes our reminder IDs for slash command autocomplete
+func (rh *ReminderHandler) updateCompletions() {
+	for channelID, channelData := range rh.channelReminders {
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(channelData.Reminders))
+		for x, reminder := range channelData.Reminders {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  strconv.Itoa(x) + ": " + reminder.Name,
+				Value: x,
+			})
+		}
+		rh.completions.Set(channelID, choices)
+	}
+}
+
+func (rh *ReminderHandler) add(channelID string, guildID string, user string, data string) {
 	match := rh.addMatcher.FindStringSubmatch(data)
 	if match != nil {
 		if hour, err := strconv.Atoi(match[1]); err == nil {
 			if minute, err := strconv.Atoi(match[2]); err == nil {
+				reminder := Reminder{Hour: hour, Minute: minute, Name: match[4], Days: rh.parseDays(match[3])}
+				//The time means the time for whoever added it, so keep their zone even if it's only the guild's or bot's
+				reminder.TimeZone = userLocation(guildID, user).String()
 
-				//Let's validate these hour/minute values
-				if hour < 0 || hour > 23 {
-					MessageSender.SendMessage(channelID, "Hour must be between 0 and 23")
+				if err := rh.addReminder(channelID, user, &reminder); err != nil {
+					rh.session.SendMessage(channelID, err.Error())
 					return
 				}
 
-				if minute < 0 || minute > 59 {
-					MessageSender.SendMessage(channelID, "Minutes must be between 0 and 59")
-					return
-				}
+				message := rh.userPingString(user) + " added " + reminder.Name + " reminder"
+				rh.session.SendMessage(channelID, message)
+			}
+		}
+	} else {
+		rh.session.SendMessage(channelID, "Invalid add syntax")
+	}
 
-				channel, ok := rh.channelReminders[channelID]
-				if !ok {
-					channel = rh.initChannel(channelID)
-				}
+}
 
-				reminder := Reminder{}
-				reminder.Hour = hour
-				reminder.Minute = minute
-				reminder.Name = match[4]
-				reminder.Days = make([]int, 0)
+//parseDays turns day letters, eg MTWRF, into weekdays
+func (rh *ReminderHandler) parseDays(letters string) []int {
+	days := make([]int, 0)
+	for _, letter := range letters {
+		if day, ok := rh.dayMap[letter]; ok {
+			days = append(days, (int)(day))
+		}
+	}
 
-				for _, letter := range match[3] {
-					if day, ok := rh.dayMap[letter]; ok {
-						reminder.Days = append(reminder.Days, (int)(day))
-					}
-				}
+	return days
+}
 
-				reminder.Notifyees = make([]string, 0)
-				reminder.Notifyees = append(reminder.Notifyees, user)
+//validateReminder checks the reminder's time and zone make sense
+func validateReminder(reminder *Reminder) error {
+	//Let's validate these hour/minute values
+	if reminder.Hour < 0 || reminder.Hour > 23 {
+		return errors.New("Hour must be between 0 and 23")
+	}
+	if reminder.Minute < 0 || reminder.Minute > 59 {
+		return errors.New("Minutes must be between 0 and 59")
+	}
+	for _, day := range reminder.Days {
+		if day < int(time.Sunday) || day > int(time.Saturday) {
+			return errors.New("Days must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if reminder.TimeZone != "" {
+		zone, err := validateTimeZone(reminder.TimeZone)
+		if err != nil {
+			return err
+		}
+		reminder.TimeZone = zone
+	}
 
-				channel.Reminders = append(channel.Reminders, &reminder)
+	return nil
+}
 
-				rh.writeData()
+//addReminder adds the reminder to the channel, notifying the user who added it if there is one
+func (rh *ReminderHandler) addReminder(channelID string, user string, reminder *Reminder) error {
+	if err := validateReminder(reminder); err != nil {
+		return err
+	}
 
-				message := rh.userPingString(user) + " added " + reminder.Name + " reminder"
-				MessageSender.SendMessage(channelID, message)
-			}
-		}
-	} else {
-		MessageSender.SendMessage(channelID, "Invalid add syntax")
+	channel, ok := rh.channelReminders[channelID]
+	if !ok {
+		channel = rh.initChannel(channelID)
 	}
 
+	reminder.Notifyees = make([]string, 0)
+	if user != "" {
+		reminder.Notifyees = append(reminder.Notifyees, user)
+	}
+
+	channel.Reminders = append(channel.Reminders, reminder)
+	rh.writeData()
+	rh.audit("add", nil, reminder)
+	return nil
+}
+
+//editReminder changes the reminder's name, time, days and zone, keeping who it notifies
+func (rh *ReminderHandler) editReminder(channelID string, index int, edited Reminder) (*Reminder, error) {
+	reminder, err := rh.reminder(channelID, index)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateReminder(&edited); err != nil {
+		return nil, err
+	}
+
+	before := *reminder
+	edited.Notifyees = reminder.Notifyees
+	*reminder = edited
+	rh.writeData()
+	rh.audit("edit", before, reminder)
+	return reminder, nil
+}
+
+//deleteReminder removes the reminder from the channel
+func (rh *ReminderHandler) deleteReminder(channelID string, index int) (*Reminder, error) {
+	reminder, err := rh.reminder(channelID, index)
+	if err != nil {
+		return nil, err
+	}
+
+	channelData := rh.channelReminders[channelID]
+	channelData.Reminders = append(channelData.Reminders[:index], channelData.Reminders[index+1:]...)
+	rh.writeData()
+	rh.audit("delete", reminder, nil)
+	return reminder, nil
+}
+
+//reminder finds the channel's reminder by its ID
+func (rh *ReminderHandler) reminder(channelID string, index int) (*Reminder, error) {
+	channelData, ok := rh.channelReminders[channelID]
+	if !ok || index < 0 || index >= len(channelData.Reminders) {
+		return nil, errors.New("That's not a valid reminder!")
+	}
+
+	return channelData.Reminders[index], nil
 }
 
 func (rh *ReminderHandler) list(channelID string) {
-	formattedChannelReminder := rh.formatChannelReminders(channelID)
-	MessageSender.SendMessage(channelID, formattedChannelReminder)
+	embed := newEmbed("Reminders")
+	if channelData, ok := rh.channelReminders[channelID]; ok {
+		for x, reminder := range channelData.Reminders {
+			embed.Fields = append(embed.Fields, embedField("["+strconv.Itoa(x)+"] "+reminder.Name, rh.formatReminder(reminder), false))
+		}
+	}
+	if len(embed.Fields) == 0 {
+		embed.Description = "<No reminders>"
+	}
+
+	sendPaged(rh.session, channelID, embed)
 }
 
 func (rh *ReminderHandler) addUser(channelID string, user string, data string) {
@@ -239,26 +423,28 @@ func (rh *ReminderHandler) addUser(channelID string, user string, data string) {
 					for _, notifyee := range reminder.Notifyees {
 						if user == notifyee {
 							//Hey, you're already here!
-							MessageSender.SendMessage(channelID, "You're already a notifyee of this reminder!")
+							rh.session.SendMessage(channelID, "You're already a notifyee of this reminder!")
 							return
 						}
 					}
 
 					//Not here already, lets add you!
+					before := append([]string(nil), reminder.Notifyees...)
 					reminder.Notifyees = append(reminder.Notifyees, user)
 					rh.writeData()
-					MessageSender.SendMessage(channelID, "Added user "+rh.userPingString(user)+" to notification list")
+					rh.audit("addme", before, reminder.Notifyees)
+					rh.session.SendMessage(channelID, "Added user "+rh.userPingString(user)+" to notification list")
 				} else {
-					MessageSender.SendMessage(channelID, "That's not a valid reminder!")
+					rh.session.SendMessage(channelID, "That's not a valid reminder!")
 				}
 			} else {
-				MessageSender.SendMessage(channelID, "Could not convert "+data+" to an integer!")
+				rh.session.SendMessage(channelID, "Could not convert "+data+" to an integer!")
 			}
 		} else {
-			MessageSender.SendMessage(channelID, "No reminders for this channel!")
+			rh.session.SendMessage(channelID, "No reminders for this channel!")
 		}
 	} else {
-		MessageSender.SendMessage(channelID, "Invalid syntax")
+		rh.session.SendMessage(channelID, "Invalid syntax")
 	}
 }
 
@@ -266,7 +452,7 @@ func (rh *ReminderHandler) removeUser(channelID string, user string, data string
 	if match := rh.addRemoveUserMatcher.FindStringSubmatch(data); match != nil {
 		if channelData, ok := rh.channelReminders[channelID]; ok {
 			if index, err := strconv.Atoi(match[1]); err == nil {
-				if len(channelData.Reminders) >= index && index >= 0 {
+				if len(channelData.Reminders) > index && index >= 0 {
 					reminder := channelData.Reminders[index]
 					var removeIndex int = -1
 					for x, notifyee := range reminder.Notifyees {
@@ -278,8 +464,9 @@ func (rh *ReminderHandler) removeUser(channelID string, user string, data string
 
 					if removeIndex == -1 {
 						//You're not in this notification list!
-						MessageSender.SendMessage(channelID, "You're not registered as a notifyee of this reminder!")
+						rh.session.SendMessage(channelID, "You're not registered as a notifyee of this reminder!")
 					} else {
+						before := append([]string(nil), reminder.Notifyees...)
 						currentLength := len(reminder.Notifyees)
 						//Swap the last element to this element's position (may be the same element)
 						//and then set our array to everything but that last element
@@ -287,113 +474,89 @@ func (rh *ReminderHandler) removeUser(channelID string, user string, data string
 						reminder.Notifyees = reminder.Notifyees[:currentLength-1]
 
 						rh.writeData()
-						MessageSender.SendMessage(channelID, "Removed "+rh.userPingString(user)+" from notification list")
+						rh.audit("removeme", before, reminder.Notifyees)
+						rh.session.SendMessage(channelID, "Removed "+rh.userPingString(user)+" from notification list")
 					}
 				} else {
-					MessageSender.SendMessage(channelID, "Invalid reminder specified!")
+					rh.session.SendMessage(channelID, "Invalid reminder specified!")
 				}
 			} else {
-				MessageSender.SendMessage(channelID, "Could not interpret "+data+" as an integer!")
+				rh.session.SendMessage(channelID, "Could not interpret "+data+" as an integer!")
 			}
 		} else {
-			MessageSender.SendMessage(channelID, "This channel does not have reminders!")
+			rh.session.SendMessage(channelID, "This channel does not have reminders!")
 		}
 	} else {
-		MessageSender.SendMessage(channelID, "Invalid parameters provided!")
+		rh.session.SendMessage(channelID, "Invalid parameters provided!")
 	}
 }
 
-func (rh *ReminderHandler) formatChannelReminders(channelID string) string {
-	message := "```"
-	columns := [10]string{"ID", "Name", "Time", "U", "M", "T", "W", "R", "F", "S"}
-	columnRequiredSize := [10]int{2, 4, 5, 1, 1, 1, 1, 1, 1, 1}
-	if channelData, ok := rh.channelReminders[channelID]; ok {
-		if channelData.Reminders != nil && len(channelData.Reminders) > 0 {
-			//First, determine the maximum size name
-			for _, reminder := range channelData.Reminders {
-				nameLength := len(reminder.Name)
-				if columnRequiredSize[1] < nameLength {
-					columnRequiredSize[1] = nameLength
-				}
-			}
-
-			//ID is minimum 2 chars, but potentially more
-			totalEntries := len(channelData.Reminders)
-			columnRequiredSize[0] = len(strconv.FormatInt(int64(totalEntries), 10))
-			if columnRequiredSize[0] < 2 {
-				columnRequiredSize[0] = 2
-			}
-
-			//Build up our column headers
-			//[ ID ][ Name ][ Time  ][ U ][ M ][ T ][ W ][ R ][ F ][ S ]
-			//[ 1  ][ Hmm  ][ 10:30 ][   ][   ][ X ][   ][   ][   ][   ]
-			for x := 0; x < len(columns); x++ {
-				message += rh.formatName(columns[x], columnRequiredSize[x])
-			}
-			message += "\n"
-
-			//Calculations out of the way, let's format this sucker
-			for x, reminder := range channelData.Reminders {
-				message += rh.formatName(strconv.FormatInt(int64(x), 10), columnRequiredSize[0])
-				message += rh.formatName(reminder.Name, columnRequiredSize[1])
-				timeString := strconv.FormatInt(int64(reminder.Hour), 10) + ":" + strconv.FormatInt(int64(reminder.Minute), 10)
-				message += rh.formatName(timeString, columnRequiredSize[2])
-
-				daySlice := make([]bool, 7)
-				for _, weekday := range reminder.Days {
-					daySlice[weekday] = true
-				}
-				for x := 0; x < len(daySlice); x++ {
-					message += rh.formatDayActive(daySlice[x])
-				}
-				message += "\n"
-			}
-			message += "```"
-		} else {
-			return "```<No reminders>```"
-		}
-	} else {
-		return "```<No reminders>```"
+//formatReminder describes when the reminder goes off and who it pings, eg 9:05 on Mon, Wed (Europe/London) for @someone
+func (rh *ReminderHandler) formatReminder(reminder *Reminder) string {
+	days := make([]string, 0, len(reminder.Days))
+	for _, day := range reminder.Days {
+		days = append(days, time.Weekday(day).String()[:3])
 	}
 
-	return message
-}
-
-func (rh *ReminderHandler) formatName(value string, requiredLength int) string {
-	message := "[ " + value
-
-	extraWhitespace := requiredLength - len(value)
-	for x := 0; x < extraWhitespace; x++ {
-		message += " "
+	description := fmt.Sprintf("%d:%02d on %s", reminder.Hour, reminder.Minute, strings.Join(days, ", "))
+	if reminder.TimeZone != "" {
+		description += " (" + reminder.TimeZone + ")"
 	}
-
-	message += " ]"
-
-	return message
-}
-
-func (rh *ReminderHandler) formatDayActive(day bool) string {
-	if day {
-		return "[ X ]"
+	if len(reminder.Notifyees) > 0 {
+		pings := make([]string, 0, len(reminder.Notifyees))
+		for _, user := range reminder.Notifyees {
+			pings = append(pings, rh.userPingString(user))
+		}
+		description += " for " + strings.Join(pings, " ")
 	}
 
-	return "[   ]"
+	return description
 }
 
-func (rh *ReminderHandler) help(channelID string) {
-	helpMessage := "The following commands are supported by /rw:\n"
+func (rh *ReminderHandler) help(channelID string, guildID string) {
+	helpMessage := "The following commands are supported by " + remindCommand + ":\n"
 	helpMessage += remindCommand + " add <time> <days> <Reminder> - Adds the following Reminder for tracking.\n"
 	helpMessage += "\t<time> is in HH:MM format using 24-hour time\n"
 	helpMessage += "\t<days> is a string with any of MTWRF\n"
 	helpMessage += "\teg: " + remindCommand + " add 20:45 TWRF Anime Time\n"
 	helpMessage += remindCommand + " list - Lists all channel reminders\n"
 	helpMessage += remindCommand + " addme <id> - Add yourself as a notifyee of the specified reminder\n"
-	helpMessage += "\t<id> can be obtained from /rw list\n"
+	helpMessage += "\t<id> can be obtained from " + remindCommand + " list\n"
 	helpMessage += "\teg: " + remindCommand + " addme 12\n"
 	helpMessage += remindCommand + " removeme <id> - Remove yourself as a notifyee of the specified reminder\n"
+	helpMessage += remindCommand + " tz <id> <zone> - Change the time zone the reminder's time is in. New reminders use yours, see " + settingsCommand + " mytimezone\n"
+	helpMessage += "\teg: " + remindCommand + " tz 12 Europe/London\n"
 	helpMessage += remindCommand + " help - This output here!"
 
-	MessageSender.SendMessage(channelID, helpMessage)
+	rh.session.SendMessage(channelID, localizeCommands(guildID, helpMessage))
+}
+
+func (rh *ReminderHandler) setTimeZone(channelID string, data string) {
+	match := rh.timeZoneMatcher.FindStringSubmatch(data)
+	if match == nil {
+		rh.session.SendMessage(channelID, "Invalid syntax")
+		return
+	}
+
+	channelData, ok := rh.channelReminders[channelID]
+	index, err := strconv.Atoi(match[1])
+	if !ok || err != nil || index < 0 || index >= len(channelData.Reminders) {
+		rh.session.SendMessage(channelID, "That's not a valid reminder!")
+		return
+	}
+
+	zone, err := validateTimeZone(match[2])
+	if err != nil {
+		rh.session.SendMessage(channelID, "Can't use \""+match[2]+"\": "+err.Error())
+		return
+	}
+
+	reminder := channelData.Reminders[index]
+	before := reminder.TimeZone
+	reminder.TimeZone = zone
+	rh.writeData()
+	rh.audit("tz", before, zone)
+	rh.session.SendMessage(channelID, fmt.Sprintf("%s is now at %d:%02d %s", reminder.Name, reminder.Hour, reminder.Minute, channelItemLocation(rh.session, channelID, zone)))
 }
 
 func (rh *ReminderHandler) initChannel(channelID string) *channelReminderData {
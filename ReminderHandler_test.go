@@ -0,0 +1,135 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReminderAddAndList(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReminderHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "123", "/remind add 20:45 TWRF Anime Time")
+	assertContains(t, session.LastSent("chan"), "<@!123> added Anime Time reminder")
+
+	harness.Say("chan", "123", "/remind list")
+	list := session.LastSent("chan")
+	assertContains(t, list, "**Reminders**")
+	assertContains(t, list, "[0] Anime Time: 20:45 on Tue, Wed, Thu, Fri (Local) for <@!123>")
+
+	if session.Count("delete") != 2 {
+		t.Errorf("expected both commands to be cleaned up, got %d deletes", session.Count("delete"))
+	}
+}
+
+func TestReminderAddValidatesTime(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReminderHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "123", "/remind add 24:00 M Too Late")
+	assertContains(t, session.LastSent("chan"), "Hour must be between 0 and 23")
+
+	harness.Say("chan", "123", "/remind add 12:60 M Too Late")
+	assertContains(t, session.LastSent("chan"), "Minutes must be between 0 and 59")
+
+	harness.Say("chan", "123", "/remind add noon M Too Late")
+	assertContains(t, session.LastSent("chan"), "Invalid add syntax")
+
+	harness.Say("chan", "123", "/remind list")
+	assertContains(t, session.LastSent("chan"), "<No reminders>")
+}
+
+func TestReminderAddAndRemoveNotifyees(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReminderHandler(session, NewJSONFileStore(".")))
+
+	harness.Say("chan", "123", "/remind add 8:00 MTWRF Standup")
+
+	harness.Say("chan", "456", "/remind addme 0")
+	assertContains(t, session.LastSent("chan"), "Added user <@!456> to notification list")
+
+	harness.Say("chan", "456", "/remind addme 0")
+	assertContains(t, session.LastSent("chan"), "already a notifyee")
+
+	harness.Say("chan", "456", "/remind addme 1")
+	assertContains(t, session.LastSent("chan"), "not a valid reminder")
+
+	harness.Say("chan", "123", "/remind removeme 0")
+	assertContains(t, session.LastSent("chan"), "Removed <@!123> from notification list")
+
+	harness.Say("chan", "123", "/remind removeme 0")
+	assertContains(t, session.LastSent("chan"), "not registered as a notifyee")
+
+	harness.Say("chan", "123", "/remind removeme 1")
+	assertContains(t, session.LastSent("chan"), "Invalid reminder specified")
+
+	harness.Say("elsewhere", "123", "/remind addme 0")
+	assertContains(t, session.LastSent("elsewhere"), "No reminders for this channel")
+}
+
+func TestReminderFiresInUsersTimeZone(t *testing.T) {
+	useTempDir(t)
+	settings := NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC)
+	if err := settings.SetUserTimeZone("123", "Asia/Tokyo"); err != nil {
+		t.Fatal(err)
+	}
+	guildSettingsStore = settings
+	t.Cleanup(func() { guildSettingsStore = nil })
+
+	session := &FakeSession{}
+	handler := NewReminderHandler(session, NewJSONFileStore("."))
+	harness := newHandlerHarness(t, handler)
+	harness.Say("chan", "123", "/remind add 20:45 TWRF Anime Time")
+	session.Reset()
+
+	job := handler.Jobs()[0]
+	for _, occurrence := range []time.Time{
+		time.Date(2030, time.March, 5, 20, 45, 0, 0, time.UTC),
+		time.Date(2030, time.March, 5, 11, 45, 0, 0, time.UTC),
+	} {
+		occurrence := occurrence
+		job.Queue <- func() { job.Run(JobRun{Occurrence: occurrence, Session: session}) }
+	}
+	harness.Say("chan", "123", "")
+
+	sent := session.Sent("chan")
+	if len(sent) != 1 {
+		t.Fatalf("expected only the 20:45 Tokyo reminder, got %q", sent)
+	}
+	assertContains(t, sent[0], "Anime Time <@!123>")
+}
+
+func TestReminderWithoutAZoneFollowsItsGuild(t *testing.T) {
+	useTempDir(t)
+	settings := NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC)
+	if err := settings.SetTimeZone("guild", "Asia/Tokyo"); err != nil {
+		t.Fatal(err)
+	}
+	guildSettingsStore = settings
+	t.Cleanup(func() { guildSettingsStore = nil })
+
+	session := &FakeSession{channelGuilds: map[string]string{"chan": "guild"}}
+	handler := NewReminderHandler(session, NewJSONFileStore("."))
+	harness := newHandlerHarness(t, handler)
+	harness.Say("chan", "123", "/remind add 20:45 TWRF Anime Time")
+	harness.Say("chan", "123", "/remind tz 0 default")
+	assertContains(t, session.LastSent("chan"), "Anime Time is now at 20:45 Asia/Tokyo")
+	session.Reset()
+
+	job := handler.Jobs()[0]
+	for _, occurrence := range []time.Time{
+		time.Date(2030, time.March, 5, 20, 45, 0, 0, time.UTC),
+		time.Date(2030, time.March, 5, 11, 45, 0, 0, time.UTC),
+	} {
+		occurrence := occurrence
+		job.Queue <- func() { job.Run(JobRun{Occurrence: occurrence, Session: session}) }
+	}
+	harness.Say("chan", "123", "")
+
+	if sent := session.Sent("chan"); len(sent) != 1 {
+		t.Errorf("expected only the 20:45 Tokyo reminder, got %q", sent)
+	}
+}
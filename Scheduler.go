@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//maxSchedulerSleep bounds how long the scheduler sleeps, so it notices if the wall clock is changed underneath it
+const maxSchedulerSleep = time.Minute
+
+//CatchUpPolicy determines what happens to occurrences missed while the bot was offline or asleep
+type CatchUpPolicy string
+
+const (
+	//CatchUpLate runs each missed occurrence, marking the messages it sends as late
+	CatchUpLate CatchUpPolicy = "late"
+	//CatchUpSummary runs each missed occurrence, collecting the messages they send into one per channel
+	CatchUpSummary CatchUpPolicy = "summary"
+	//CatchUpSkip forgets missed occurrences
+	CatchUpSkip CatchUpPolicy = "skip"
+)
+
+const defaultGraceWindow = time.Hour
+
+//SchedulerConfiguration Configures how missed jobs are caught up on
+type SchedulerConfiguration struct {
+	CatchUp string `json:"CatchUp"`
+	//GraceWindow is how far back missed occurrences are still caught up on, eg 2h
+	GraceWindow string `json:"GraceWindow"`
+}
+
+//Schedule Decides when a job runs
+type Schedule interface {
+	//Next returns the first run strictly after the provided time
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type dailySchedule struct {
+	hour     int
+	minute   int
+	location *time.Location
+}
+
+//DailyAt runs once a day at the provided time in the location
+func DailyAt(hour int, minute int, location *time.Location) Schedule {
+	return dailySchedule{hour: hour, minute: minute, location: location}
+}
+
+func (ds dailySchedule) Next(after time.Time) time.Time {
+	local := after.In(ds.location)
+	next := dueAt(local, ds.location, ds.hour, ds.minute)
+	if !next.After(after) {
+		next = dueAt(local.AddDate(0, 0, 1), ds.location, ds.hour, ds.minute)
+	}
+
+	return next
+}
+
+func (ds dailySchedule) String() string {
+	return fmt.Sprintf("daily at %02d:%02d %s", ds.hour, ds.minute, ds.location)
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+//Every runs at each whole multiple of the interval past the hour, so the interval should divide an hour evenly
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule{interval: interval}
+}
+
+func (is intervalSchedule) Next(after time.Time) time.Time {
+	//Count from the top of the local hour, so hourly jobs run on the hour even in half hour time zones
+	hour := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), 0, 0, 0, after.Location())
+	return hour.Add(after.Sub(hour).Truncate(is.interval) + is.interval)
+}
+
+func (is intervalSchedule) String() string {
+	switch is.interval {
+	case time.Minute:
+		return "every minute"
+	case time.Hour:
+		return "every hour"
+	}
+
+	return "every " + is.interval.String()
+}
+
+//JobRun Describes a single occurrence of a job
+type JobRun struct {
+	//Occurrence is the time the run was scheduled for, which may be a little before now
+	Occurrence time.Time
+	//Late is set when the occurrence was missed while the bot was offline, or asleep for longer than it should have been
+	Late bool
+	//Session is where the job should send its messages, so late runs can be marked or summarized
+	//Only text is affected, files are still uploaded as normal
+	Session Session
+}
+
+//Job Is something a handler wants done on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(run JobRun)
+	//Queue, if set, is sent each run so it happens on the owning handler's goroutine rather than the scheduler's
+	Queue chan<- func()
+	//Owner is filled in with the handler's name when registered
+	Owner string
+}
+
+//ScheduledHandler Is implemented by handlers which have jobs to run
+type ScheduledHandler interface {
+	Jobs() []Job
+}
+
+//JobInfo Describes an upcoming job
+type JobInfo struct {
+	Owner    string
+	Name     string
+	Schedule string
+	Next     time.Time
+}
+
+type scheduledJob struct {
+	job  Job
+	next time.Time
+	//missed are occurrences from while we were offline, still to be caught up on
+	missed []time.Time
+}
+
+func (sj *scheduledJob) key() string {
+	return sj.job.Owner + "/" + sj.job.Name
+}
+
+//Scheduler Runs every handler's jobs, firing each occurrence exactly once
+//The last run of each job is saved, so occurrences missed while the bot was offline can be caught up on
+type Scheduler struct {
+	mutex       sync.Mutex
+	session     Session
+	store       Store
+	catchUp     CatchUpPolicy
+	graceWindow time.Duration
+	lastRuns    map[string]time.Time
+	jobs        []*scheduledJob
+	wake        chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+	started     bool
+}
+
+//NewScheduler creates a scheduler which gives jobs the provided session and saves their last runs to the store
+//It won't run anything until started
+func NewScheduler(session Session, store Store, config SchedulerConfiguration) *Scheduler {
+	s := &Scheduler{
+		session:     session,
+		store:       store,
+		catchUp:     CatchUpPolicy(config.CatchUp),
+		graceWindow: defaultGraceWindow,
+		lastRuns:    make(map[string]time.Time),
+		jobs:        make([]*scheduledJob, 0),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	switch s.catchUp {
+	case CatchUpLate, CatchUpSummary, CatchUpSkip:
+	case "":
+		s.catchUp = CatchUpLate
+	default:
+		logger.Warn("Unknown catch up policy, using "+string(CatchUpLate), "policy", config.CatchUp)
+		s.catchUp = CatchUpLate
+	}
+
+	if config.GraceWindow != "" {
+		if parsed, err := time.ParseDuration(config.GraceWindow); err == nil {
+			s.graceWindow = parsed
+		} else {
+			logger.Warn("Invalid grace window", "window", config.GraceWindow, "error", err)
+		}
+	}
+
+	if err := store.Load(schedulerDocument, &s.lastRuns); err != nil && err != ErrNotFound {
+		logger.Error("Error loading job history", "error", err)
+	}
+
+	return s
+}
+
+//Add registers the job. Its first run is the first occurrence after now,
+//plus any occurrences missed since it last ran which are still within the grace window
+func (s *Scheduler) Add(job Job) {
+	now := time.Now()
+	sj := &scheduledJob{job: job, next: job.Schedule.Next(now), missed: make([]time.Time, 0)}
+
+	s.mutex.Lock()
+	lastRun, ok := s.lastRuns[sj.key()]
+	if !ok {
+		//Never run before, but anything from now on counts as missed
+		s.lastRuns[sj.key()] = now
+	} else if s.catchUp != CatchUpSkip {
+		from := now.Add(-s.graceWindow)
+		if lastRun.After(from) {
+			from = lastRun
+		}
+
+		for occurrence := job.Schedule.Next(from); !occurrence.After(now); occurrence = job.Schedule.Next(occurrence) {
+			sj.missed = append(sj.missed, occurrence)
+		}
+	}
+	s.jobs = append(s.jobs, sj)
+	s.mutex.Unlock()
+
+	//Let the run loop recalculate how long to sleep
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+//Start begins running jobs in the background
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	s.started = true
+	s.mutex.Unlock()
+
+	s.saveLastRuns()
+
+	go s.run()
+}
+
+//Stop stops running jobs, returning once the scheduler has finished
+func (s *Scheduler) Stop() {
+	close(s.done)
+
+	s.mutex.Lock()
+	started := s.started
+	s.mutex.Unlock()
+
+	if started {
+		<-s.stopped
+	}
+}
+
+//Upcoming lists every job, soonest first
+func (s *Scheduler) Upcoming() []JobInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	upcoming := make([]JobInfo, 0, len(s.jobs))
+	for _, sj := range s.jobs {
+		upcoming = append(upcoming, JobInfo{
+			Owner:    sj.job.Owner,
+			Name:     sj.job.Name,
+			Schedule: sj.job.Schedule.String(),
+			Next:     sj.next,
+		})
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].Next.Before(upcoming[j].Next) })
+
+	return upcoming
+}
+
+func (s *Scheduler) run() {
+	defer close(s.stopped)
+
+	s.runMissed()
+	for {
+		s.runDue(time.Now())
+
+		timer := time.NewTimer(s.untilNext(time.Now()))
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		case <-s.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+//untilNext returns how long to sleep before the soonest job is due
+func (s *Scheduler) untilNext(now time.Time) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wait := maxSchedulerSleep
+	for _, sj := range s.jobs {
+		if until := sj.next.Sub(now); until < wait {
+			wait = until
+		}
+	}
+
+	return wait
+}
+
+//runDue fires every occurrence at or before now which hasn't fired yet, oldest first for each job
+//Occurrences we woke up too late for, eg after the host was suspended, are caught up on like ones missed while offline
+func (s *Scheduler) runDue(now time.Time) {
+	s.mutex.Lock()
+	due := make([]dueRun, 0)
+	for _, sj := range s.jobs {
+		for !sj.next.After(now) {
+			late := now.Sub(sj.next)
+			if late <= maxSchedulerSleep {
+				due = append(due, dueRun{job: sj, run: JobRun{Occurrence: sj.next, Session: s.session}})
+			} else if s.catchUp != CatchUpSkip && late <= s.graceWindow {
+				sj.missed = append(sj.missed, sj.next)
+			}
+			sj.next = sj.job.Schedule.Next(sj.next)
+		}
+	}
+	s.mutex.Unlock()
+
+	s.runMissed()
+	s.deliver(due, nil)
+}
+
+//runMissed catches up on occurrences missed while we were offline or asleep, before anything else runs
+func (s *Scheduler) runMissed() {
+	summary := &summarySession{Session: s.session, messages: make(map[string][]string), priorities: make(map[string]Priority)}
+
+	s.mutex.Lock()
+	due := make([]dueRun, 0)
+	for _, sj := range s.jobs {
+		for _, occurrence := range sj.missed {
+			run := JobRun{Occurrence: occurrence, Late: true, Session: &lateSession{Session: s.session, occurrence: occurrence}}
+			if s.catchUp == CatchUpSummary {
+				run.Session = &summaryRun{summarySession: summary, occurrence: occurrence}
+			}
+			due = append(due, dueRun{job: sj, run: run})
+		}
+		sj.missed = nil
+	}
+	s.mutex.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	logger.Info("Catching up on missed job runs", "runs", len(due))
+	var finished sync.WaitGroup
+	finished.Add(len(due))
+	if !s.deliver(due, &finished) {
+		return
+	}
+
+	//Wait for the handlers to finish, so the summary has everything in it
+	allFinished := make(chan struct{})
+	go func() {
+		finished.Wait()
+		close(allFinished)
+	}()
+
+	select {
+	case <-allFinished:
+		if s.catchUp == CatchUpSummary {
+			summary.flush()
+		}
+	case <-s.done:
+	}
+}
+
+type dueRun struct {
+	job *scheduledJob
+	run JobRun
+}
+
+//deliver runs each job on its queue, recording it as the job's last run once it's done. Returns false if we were stopped first
+//If finished is provided it's marked done as each run completes
+func (s *Scheduler) deliver(due []dueRun, finished *sync.WaitGroup) bool {
+	//Deliver without holding the lock, handlers may be busy and are free to ask us for the upcoming jobs
+	for _, entry := range due {
+		job := entry.job.job
+		key := entry.job.key()
+		run := entry.run
+		task := func() {
+			if finished != nil {
+				defer finished.Done()
+			}
+			//Handlers disabled after failing too often don't get to run their jobs
+			if !supervisor.Disabled(job.Owner) {
+				metrics.Inc(metricJobRuns, job.Owner, job.Name, strconv.FormatBool(run.Late))
+				if !run.Late {
+					metrics.Observe(metricJobLateness, time.Since(run.Occurrence).Seconds(), job.Owner, job.Name)
+				}
+				job.Run(run)
+			}
+			//Runs which panicked aren't recorded, so they're caught up on if we go down before the next one
+			s.recordRun(key, run.Occurrence)
+		}
+
+		if job.Queue == nil {
+			task()
+		} else {
+			select {
+			case job.Queue <- task:
+			case <-s.done:
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+//recordRun saves the occurrence as the job's last run, unless a later one already finished
+func (s *Scheduler) recordRun(key string, occurrence time.Time) {
+	s.mutex.Lock()
+	if lastRun, ok := s.lastRuns[key]; ok && !occurrence.After(lastRun) {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastRuns[key] = occurrence
+	s.mutex.Unlock()
+
+	s.saveLastRuns()
+}
+
+func (s *Scheduler) saveLastRuns() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.store.Save(schedulerDocument, s.lastRuns); err != nil {
+		logger.Error("Error saving job history", "error", err)
+	}
+}
+
+//lateSession Marks messages from a late run with when they were due
+type lateSession struct {
+	Session
+	occurrence time.Time
+}
+
+func (ls *lateSession) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	return ls.Session.SendMessage(channelID, ls.mark(message))
+}
+
+func (ls *lateSession) sendAt(channelID string, message string, priority Priority) (*discordgo.Message, error) {
+	return WithPriority(ls.Session, priority).SendMessage(channelID, ls.mark(message))
+}
+
+func (ls *lateSession) mark(message string) string {
+	return "(Late, was due " + ls.occurrence.Format("Mon Jan 2 15:04") + ") " + message
+}
+
+//summarySession Collects the messages from late runs, to send as one message per channel
+//Each channel's summary goes out at the highest priority any of its messages asked for
+type summarySession struct {
+	Session
+	mutex      sync.Mutex
+	channels   []string
+	messages   map[string][]string
+	priorities map[string]Priority
+}
+
+func (ss *summarySession) flush() {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	for _, channelID := range ss.channels {
+		session := ss.Session
+		if priority, ok := ss.priorities[channelID]; ok {
+			session = WithPriority(session, priority)
+		}
+		session.SendMessage(channelID, "While I was offline I missed:\n"+strings.Join(ss.messages[channelID], "\n"))
+	}
+	ss.channels = nil
+	ss.messages = make(map[string][]string)
+	ss.priorities = make(map[string]Priority)
+}
+
+//summaryRun Adds one late run's messages to the summary
+type summaryRun struct {
+	*summarySession
+	occurrence time.Time
+}
+
+func (sr *summaryRun) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if _, ok := sr.messages[channelID]; !ok {
+		sr.channels = append(sr.channels, channelID)
+	}
+	sr.messages[channelID] = append(sr.messages[channelID], sr.occurrence.Format("Mon Jan 2 15:04")+": "+message)
+
+	//Nothing was actually sent, so there's no message ID to give back
+	return &discordgo.Message{ChannelID: channelID, Content: message}, nil
+}
+
+func (sr *summaryRun) sendAt(channelID string, message string, priority Priority) (*discordgo.Message, error) {
+	sr.mutex.Lock()
+	if current, ok := sr.priorities[channelID]; !ok || priority > current {
+		sr.priorities[channelID] = priority
+	}
+	sr.mutex.Unlock()
+
+	return sr.SendMessage(channelID, message)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(hour int, minute int, second int) time.Time {
+	return time.Date(2030, time.March, 4, hour, minute, second, 0, time.Local)
+}
+
+func newTestScheduler(t *testing.T, session Session, config SchedulerConfiguration) *Scheduler {
+	return NewScheduler(session, NewJSONFileStore(useTempDir(t)), config)
+}
+
+func TestScheduleNext(t *testing.T) {
+	cases := []struct {
+		schedule Schedule
+		after    time.Time
+		expected time.Time
+	}{
+		{DailyAt(11, 0, time.Local), at(10, 58, 0), at(11, 0, 0)},
+		{DailyAt(11, 0, time.Local), at(11, 0, 0), at(11, 0, 0).AddDate(0, 0, 1)},
+		{Every(time.Minute), at(10, 0, 59), at(10, 1, 0)},
+		{Every(time.Minute), at(10, 1, 0), at(10, 2, 0)},
+		{Every(time.Hour), at(10, 30, 0), at(11, 0, 0)},
+		{Every(15 * time.Minute), at(10, 16, 0), at(10, 30, 0)},
+	}
+
+	for _, c := range cases {
+		if next := c.schedule.Next(c.after); !next.Equal(c.expected) {
+			t.Errorf("%s after %s: expected %s, got %s", c.schedule, c.after, c.expected, next)
+		}
+	}
+}
+
+func TestSchedulerFiresEachOccurrenceOnce(t *testing.T) {
+	scheduler := newTestScheduler(t, &FakeSession{}, SchedulerConfiguration{})
+	fired := make([]time.Time, 0)
+	scheduler.jobs = append(scheduler.jobs, &scheduledJob{
+		job:  Job{Name: "tick", Schedule: Every(time.Minute), Run: func(run JobRun) { fired = append(fired, run.Occurrence) }},
+		next: at(10, 1, 0),
+	})
+
+	//A late wake up at :00:59 then 10:02:00 used to skip 10:01 entirely
+	scheduler.runDue(at(10, 0, 59))
+	scheduler.runDue(at(10, 2, 0))
+	scheduler.runDue(at(10, 2, 30))
+
+	if len(fired) != 2 || !fired[0].Equal(at(10, 1, 0)) || !fired[1].Equal(at(10, 2, 0)) {
+		t.Errorf("expected 10:01 and 10:02 to fire once each, got %v", fired)
+	}
+}
+
+func TestSchedulerRunsJobsOnTheirQueue(t *testing.T) {
+	scheduler := newTestScheduler(t, &FakeSession{}, SchedulerConfiguration{})
+	queue := make(chan func(), 1)
+	ran := false
+	scheduler.jobs = append(scheduler.jobs, &scheduledJob{
+		job:  Job{Name: "queued", Schedule: DailyAt(9, 0, time.Local), Run: func(JobRun) { ran = true }, Queue: queue},
+		next: at(9, 0, 0),
+	})
+
+	scheduler.runDue(at(9, 0, 0))
+	if ran {
+		t.Fatal("job ran on the scheduler's goroutine instead of its queue")
+	}
+
+	(<-queue)()
+	if !ran {
+		t.Error("queued task didn't run the job")
+	}
+}
+
+func TestSchedulerListsUpcomingJobsSoonestFirst(t *testing.T) {
+	scheduler := newTestScheduler(t, &FakeSession{}, SchedulerConfiguration{})
+	scheduler.Add(Job{Owner: "Release Handler", Name: "release announcements", Schedule: DailyAt(11, 0, time.Local), Run: func(JobRun) {}})
+	scheduler.Add(Job{Owner: "Reminder Handler", Name: "reminders", Schedule: Every(time.Minute), Run: func(JobRun) {}})
+
+	upcoming := scheduler.Upcoming()
+	if len(upcoming) != 2 || upcoming[0].Name != "reminders" || upcoming[0].Schedule != "every minute" {
+		t.Errorf("unexpected upcoming jobs %+v", upcoming)
+	}
+}
+
+//tickJob sends a message to #chan every minute
+func tickJob() Job {
+	return Job{Owner: "Test", Name: "tick", Schedule: Every(time.Minute), Run: func(run JobRun) {
+		run.Session.SendMessage("chan", "tick")
+	}}
+}
+
+func TestSchedulerCatchesUpLate(t *testing.T) {
+	session := &FakeSession{}
+	scheduler := newTestScheduler(t, session, SchedulerConfiguration{GraceWindow: "1h"})
+	scheduler.lastRuns["Test/tick"] = time.Now().Add(-5 * time.Minute)
+	scheduler.Add(tickJob())
+	scheduler.runMissed()
+
+	sent := session.Sent("chan")
+	if len(sent) != 5 {
+		t.Fatalf("expected the 5 missed minutes to run, got %v", sent)
+	}
+	assertContains(t, sent[0], "(Late, was due ")
+	assertContains(t, sent[0], ") tick")
+
+	//Each occurrence is only caught up on once
+	session.Reset()
+	scheduler.runMissed()
+	if len(session.Sent("chan")) != 0 {
+		t.Errorf("missed runs ran twice")
+	}
+}
+
+func TestSchedulerSummarizesMissedRunsWithinGraceWindow(t *testing.T) {
+	session := &FakeSession{}
+	scheduler := newTestScheduler(t, session, SchedulerConfiguration{CatchUp: "summary", GraceWindow: "10m"})
+	scheduler.lastRuns["Test/tick"] = time.Now().Add(-3 * time.Hour)
+	scheduler.Add(tickJob())
+	scheduler.runMissed()
+
+	sent := session.Sent("chan")
+	if len(sent) != 1 {
+		t.Fatalf("expected one summary message, got %v", sent)
+	}
+	assertContains(t, sent[0], "While I was offline I missed:")
+	if lines := strings.Count(sent[0], ": tick"); lines != 10 {
+		t.Errorf("expected only the 10 minutes in the grace window, got %d", lines)
+	}
+
+	//The last run is saved, so a restart won't catch up on them again
+	var saved map[string]time.Time
+	if err := scheduler.store.Load(schedulerDocument, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if lastRun := saved["Test/tick"]; time.Since(lastRun) > time.Minute {
+		t.Errorf("expected the newest missed run to be saved, got %s", lastRun)
+	}
+}
+
+func TestSchedulerRecordsRunsOnceTheyFinish(t *testing.T) {
+	scheduler := newTestScheduler(t, &FakeSession{}, SchedulerConfiguration{})
+	queue := make(chan func(), 1)
+	scheduler.jobs = append(scheduler.jobs, &scheduledJob{
+		job:  Job{Owner: "Test", Name: "queued", Schedule: DailyAt(9, 0, time.Local), Run: func(JobRun) {}, Queue: queue},
+		next: at(9, 0, 0),
+	})
+
+	//Queued isn't run, so going down now should still catch up on it
+	scheduler.runDue(at(9, 0, 0))
+	if _, ok := scheduler.lastRuns["Test/queued"]; ok {
+		t.Fatal("expected the run not to be recorded before it ran")
+	}
+
+	(<-queue)()
+	var saved map[string]time.Time
+	if err := scheduler.store.Load(schedulerDocument, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if lastRun := saved["Test/queued"]; !lastRun.Equal(at(9, 0, 0)) {
+		t.Errorf("expected the finished run to be saved, got %s", lastRun)
+	}
+}
+
+func TestSchedulerCatchesUpAfterOversleeping(t *testing.T) {
+	for _, test := range []struct {
+		policy CatchUpPolicy
+		sent   int
+		late   int
+	}{
+		//10:29 and 10:30 are on time, then 10:20 to 10:28 are within the grace window
+		{CatchUpLate, 11, 9},
+		{CatchUpSkip, 2, 0},
+	} {
+		session := &FakeSession{}
+		scheduler := newTestScheduler(t, session, SchedulerConfiguration{CatchUp: string(test.policy), GraceWindow: "10m"})
+		scheduler.jobs = append(scheduler.jobs, &scheduledJob{job: tickJob(), next: at(10, 0, 0)})
+
+		//Woken half an hour late, eg by the host being suspended
+		scheduler.runDue(at(10, 30, 0))
+		sent := session.Sent("chan")
+		late := strings.Count(strings.Join(sent, "\n"), "(Late, was due ")
+		if len(sent) != test.sent || late != test.late {
+			t.Errorf("%s: expected %d runs with %d late, got %q", test.policy, test.sent, test.late, sent)
+		}
+	}
+}
+
+//priorityRecorder Is a queueing session which remembers the priority of everything queued
+type priorityRecorder struct {
+	*FakeSession
+	priorities []Priority
+}
+
+func (pr *priorityRecorder) Queue(outbound Outbound) {
+	pr.priorities = append(pr.priorities, outbound.Priority)
+	outbound.Callback(pr.FakeSession.SendMessage(outbound.ChannelID, outbound.Content))
+}
+
+func TestSchedulerKeepsPriorityOfMissedRuns(t *testing.T) {
+	for _, policy := range []CatchUpPolicy{CatchUpLate, CatchUpSummary} {
+		session := &priorityRecorder{FakeSession: &FakeSession{}}
+		scheduler := newTestScheduler(t, session, SchedulerConfiguration{CatchUp: string(policy)})
+		scheduler.lastRuns["Test/alert"] = time.Now().Add(-time.Minute)
+		scheduler.Add(Job{Owner: "Test", Name: "alert", Schedule: Every(time.Minute), Run: func(run JobRun) {
+			WithPriority(run.Session, HighPriority).SendMessage("chan", "alert")
+		}})
+		scheduler.runMissed()
+
+		if len(session.priorities) != 1 || session.priorities[0] != HighPriority {
+			t.Errorf("%s: expected the missed alert to keep its priority, got %v", policy, session.priorities)
+		}
+		assertContains(t, session.LastSent("chan"), "alert")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//documentMigration upgrades a decoded document by one schema version
+type documentMigration func(data interface{}) (interface{}, error)
+
+//documentMigrations lists, per document, the migrations which take it from unversioned (version 0) to the current version
+//The current version is the number of migrations, so append a new one whenever a persisted struct changes shape
+//A nil migration bumps the version without touching the data
+var documentMigrations = map[string][]documentMigration{
+	releaseDocument:        {migrateReleaseV1, migrateReleaseV2},
+	reminderDocument:       {migrateReminderV1},
+	imageDocument:          {migrateImageV1},
+	reactionDocument:       {nil},
+	fortuneDocument:        {nil},
+	guildSettingsDocument:  {nil},
+	permissionsDocument:    {nil},
+	schedulerDocument:      {nil},
+	userSettingsDocument:   {nil},
+	handlerTogglesDocument: {nil},
+}
+
+//versionedDocument Is how every document is saved, so we know which migrations it still needs
+type versionedDocument struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+//VersionedStore Wraps a Store, tagging documents with their schema version and upgrading old ones as they're loaded
+type VersionedStore struct {
+	Store
+	migrations map[string][]documentMigration
+}
+
+//NewVersionedStore creates a store which versions documents using the provided migrations
+func NewVersionedStore(store Store, migrations map[string][]documentMigration) *VersionedStore {
+	return &VersionedStore{Store: store, migrations: migrations}
+}
+
+//Load reads the document, migrating and re-saving it first if it was written with an older schema
+func (vs *VersionedStore) Load(name string, v interface{}) error {
+	var raw json.RawMessage
+	if err := vs.Store.Load(name, &raw); err != nil {
+		return err
+	}
+
+	return vs.upgrade(name, raw, v)
+}
+
+//LoadStrict reads the document like Load, but without the underlying store recovering it if it's damaged
+func (vs *VersionedStore) LoadStrict(name string, v interface{}) error {
+	var raw json.RawMessage
+	if err := loadStrict(vs.Store, name, &raw); err != nil {
+		return err
+	}
+
+	return vs.upgrade(name, raw, v)
+}
+
+//upgrade migrates the raw document to the current schema, saving it if anything changed, then decodes it into v
+func (vs *VersionedStore) upgrade(name string, raw json.RawMessage, v interface{}) error {
+
+	version, data := unwrapDocument(raw)
+	migrations := vs.migrations[name]
+	current := len(migrations)
+	if version > current {
+		return fmt.Errorf("%s is schema version %d, but we only understand up to version %d", name, version, current)
+	}
+
+	if version < current {
+		var err error
+		if data, err = migrateDocument(data, migrations[version:]); err != nil {
+			return fmt.Errorf("migrating %s from schema version %d: %v", name, version, err)
+		}
+
+		logger.Info("Upgraded document schema", "document", name, "from", version, "to", current)
+		if err = vs.Store.Save(name, versionedDocument{Version: current, Data: data}); err != nil {
+			logger.Error("Error saving upgraded document", "document", name, "error", err)
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+//Save writes the document tagged with its current schema version
+func (vs *VersionedStore) Save(name string, v interface{}) error {
+	started := time.Now()
+	defer func() { metrics.Observe(metricStoreWrites, time.Since(started).Seconds(), name) }()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return vs.Store.Save(name, versionedDocument{Version: len(vs.migrations[name]), Data: data})
+}
+
+//unwrapDocument splits a saved document into its version and data
+//Plain json, from before versioning or edited by hand, is version 0 and upgraded like any other
+func unwrapDocument(raw json.RawMessage) (int, json.RawMessage) {
+	var document struct {
+		Version *int            `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		if err := json.Unmarshal(raw, &document); err == nil && document.Version != nil {
+			return *document.Version, document.Data
+		}
+	}
+
+	return 0, raw
+}
+
+func migrateDocument(data json.RawMessage, migrations []documentMigration) (json.RawMessage, error) {
+	//Keep numbers as written, rather than round tripping them through float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		if migration == nil {
+			continue
+		}
+
+		var err error
+		if decoded, err = migration(decoded); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(decoded)
+}
+
+//jsonObjects returns the objects in a decoded json array, skipping anything else
+func jsonObjects(data interface{}) []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0)
+	if array, ok := data.([]interface{}); ok {
+		for _, entry := range array {
+			if object, ok := entry.(map[string]interface{}); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return objects
+}
+
+//renameKeys renames fields of a decoded json object, from old name to new
+func renameKeys(object map[string]interface{}, renames map[string]string) {
+	for from, to := range renames {
+		if value, ok := object[from]; ok {
+			delete(object, from)
+			object[to] = value
+		}
+	}
+}
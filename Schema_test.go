@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestVersionedStoreUpgradesLegacyReminders(t *testing.T) {
+	dir := useTempDir(t)
+	legacy := `[{"ChannelID":"chan","Reminders":[{"n":"Standup","h":9,"m":30,"d":[1,5],"notifyees":[]}]}]`
+	if err := ioutil.WriteFile("ReminderData.json", []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReminderHandler(session, NewVersionedStore(NewJSONFileStore(dir), documentMigrations)))
+	harness.Say("chan", "123", "/remind list")
+	assertContains(t, session.LastSent("chan"), "[0] Standup: 9:30 on Mon, Fri")
+
+	//The upgrade is written back, so it only happens once
+	var saved versionedDocument
+	fileData, _ := ioutil.ReadFile("ReminderData.json")
+	if err := json.Unmarshal(fileData, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != len(documentMigrations[reminderDocument]) {
+		t.Errorf("expected saved version %d, got %d", len(documentMigrations[reminderDocument]), saved.Version)
+	}
+	assertContains(t, string(saved.Data), `"name":"Standup"`)
+}
+
+func TestVersionedStoreWrapsEveryDocument(t *testing.T) {
+	dir := useTempDir(t)
+	writeTestFile(t, "fortuneData.json", `["Fortune favours the bold"]`)
+	store := NewVersionedStore(NewJSONFileStore(dir), documentMigrations)
+
+	//Plain files are still read, then saved back wrapped
+	var fortunes []string
+	if err := store.Load(fortuneDocument, &fortunes); err != nil || len(fortunes) != 1 {
+		t.Fatalf("expected the plain fortunes to load, got %v %v", fortunes, err)
+	}
+	if err := store.Save(reactionDocument, []reactionData{{TriggerWord: "hello", Reaction: "👋"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"fortuneData.json", "reactionData.json"} {
+		var saved versionedDocument
+		data, _ := ioutil.ReadFile(file)
+		if err := json.Unmarshal(data, &saved); err != nil || saved.Version != 1 || !bytes.HasPrefix(saved.Data, []byte("[")) {
+			t.Errorf("expected %s to be saved as version 1, got %s", file, data)
+		}
+	}
+}
+
+func TestImageMigrationNamesSchedules(t *testing.T) {
+	legacy := `[{"channelID":"chan","imageData":[
+		{"dir":"a","current":0,"Schedule":0,"Repeat":true,"Hour":9,"Multiplier":1},
+		{"dir":"b","current":2,"Schedule":7,"Repeat":false,"Hour":10,"Multiplier":3},
+		{"dir":"c","current":0,"Schedule":-1,"Repeat":false,"Hour":0,"Multiplier":1}]}]`
+
+	migrated, err := migrateDocument(json.RawMessage(legacy), documentMigrations[imageDocument])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []channelImageData
+	if err := json.Unmarshal(migrated, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := data[0].ImageData
+	if blocks[0].Schedule != "sunday" || blocks[1].Schedule != "daily" || blocks[2].Schedule != "manual" {
+		t.Errorf("unexpected schedules %s, %s, %s", blocks[0].Schedule, blocks[1].Schedule, blocks[2].Schedule)
+	}
+	if !blocks[0].Repeat || blocks[1].Hour != 10 || blocks[1].Multiplier != 3 || blocks[1].Current != 2 {
+		t.Errorf("fields were lost in migration: %+v %+v", blocks[0], blocks[1])
+	}
+}
+
+func TestVersionedStoreRejectsNewerDocuments(t *testing.T) {
+	dir := useTempDir(t)
+	if err := ioutil.WriteFile("releaseData.json", []byte(`{"version":99,"data":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []channelReleaseData
+	store := NewVersionedStore(NewJSONFileStore(dir), documentMigrations)
+	if err := store.Load(releaseDocument, &data); err == nil {
+		t.Error("expected an error loading a document from a newer version")
+	}
+}
+
+func TestReleaseMigrationListsPinnedMessages(t *testing.T) {
+	legacy := `[{"channelID":"a","pinnedMessageID":"123","releaseData":[]},{"channelID":"b","pinnedMessageID":"","releaseData":[]}]`
+
+	migrated, err := migrateDocument(json.RawMessage(legacy), documentMigrations[releaseDocument])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []channelReleaseData
+	if err := json.Unmarshal(migrated, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data[0].PinnedMessageIDs) != 1 || data[0].PinnedMessageIDs[0] != "123" || len(data[1].PinnedMessageIDs) != 0 {
+		t.Errorf("unexpected pinned messages %v and %v", data[0].PinnedMessageIDs, data[1].PinnedMessageIDs)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "github.com/bwmarrin/discordgo"
+
+//Session Defines the discord actions available to handlers
+//Messager implements this against a live discord session
+type Session interface {
+	SendMessage(channelID string, message string) (*discordgo.Message, error)
+	SendFile(channelID string, filePath string) error
+	//SendComplex sends a message with embeds and components
+	SendComplex(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error)
+	EditMessage(channelID string, messageID string, newMessage string) error
+	DeleteMessage(channelID string, messageID string) error
+	PinMessage(channelID string, messageID string) error
+	React(channelID string, messageID string, reaction string) error
+	UserGuilds() ([]*discordgo.UserGuild, error)
+	GuildEmojis(guildID string) ([]*discordgo.Emoji, error)
+	UserChannelPermissions(userID string, channelID string) (int64, error)
+	//ChannelGuild finds which guild the channel is in, or "" for direct messages
+	ChannelGuild(channelID string) (string, error)
+}
+
+//channelGuild looks up the channel's guild, leaving it blank if discord can't tell us
+func channelGuild(session Session, channelID string) string {
+	guildID, err := session.ChannelGuild(channelID)
+	if err != nil {
+		logger.Warn("Error finding channel's guild", "channel", channelID, "error", err)
+	}
+
+	return guildID
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//SettingsHandler lets guild admins change how the bot is invoked in their guild
+type SettingsHandler struct {
+	handlerLoop
+	session  Session
+	settings *GuildSettingsStore
+	matcher  regexp.Regexp
+}
+
+const settingsCommand = "/settings"
+
+//NewSettingsHandler creates a handler which manages the provided settings
+func NewSettingsHandler(session Session, settings *GuildSettingsStore) *SettingsHandler {
+	return &SettingsHandler{session: session, settings: settings}
+}
+
+//Init compiles our regexp and spins up our channel handling
+func (sh *SettingsHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	sh.matcher = *regexp.MustCompile(`^` + settingsCommand + `\s+(\w+)\s*(\S*)`)
+
+	sh.listen(ctx, sh.GetName(), m, sh.handleMessage)
+}
+
+//GetName returns our name
+func (sh *SettingsHandler) GetName() string {
+	return "Settings Handler"
+}
+
+//Commands returns the commands owned by this handler
+func (sh *SettingsHandler) Commands() []Command {
+	return []Command{{
+		Prefix:      settingsCommand,
+		Description: "Change how the bot is invoked in this server",
+		Subcommands: []Subcommand{
+			{Name: "show", Description: "Show this server's settings"},
+			{Name: "prefix", Description: "Change the command prefix", Capability: "settings.manage", Options: []CommandOption{
+				{Name: "prefix", Description: "New prefix, eg ! or dk.", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "helptrigger", Description: "Change the text which lists the bot's commands", Capability: "settings.manage", Options: []CommandOption{
+				{Name: "trigger", Description: "New help trigger, eg !diskhard", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "timezone", Description: "Change this server's time zone", Capability: "settings.manage", Options: []CommandOption{
+				{Name: "zone", Description: "Time zone, eg America/New_York, or default", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+			{Name: "modlog", Description: "Mirror changes made with commands to a channel", Capability: "settings.manage", Options: []CommandOption{
+				{Name: "channel", Description: "Channel to mirror to, or leave empty to stop", Type: discordgo.ApplicationCommandOptionChannel},
+			}},
+			{Name: "mytimezone", Description: "Change your own time zone, wherever you use the bot", Options: []CommandOption{
+				{Name: "zone", Description: "Time zone, eg Europe/London, or default to use the server's", Type: discordgo.ApplicationCommandOptionString, Required: true},
+			}},
+		},
+	}}
+}
+
+//Help Gets info about this handler
+func (sh *SettingsHandler) Help() string {
+	return settingsCommand + " : Settings - Change the command prefix, help trigger and time zone for this server, or your own time zone"
+}
+
+func (sh *SettingsHandler) handleMessage(m *discordgo.MessageCreate) {
+	submatches := sh.matcher.FindStringSubmatch(m.Content)
+	if submatches == nil {
+		return
+	}
+
+	if m.GuildID == "" {
+		sh.session.SendMessage(m.ChannelID, "Settings can only be changed in a server")
+		return
+	}
+
+	command := submatches[1]
+	value := submatches[2]
+	switch command {
+	case "show":
+		sh.show(m.ChannelID, m.GuildID, m.Author.ID)
+	case "prefix", "helptrigger", "timezone", "modlog", "mytimezone":
+		var err error
+		before := sh.setting(command, m.GuildID, m.Author.ID)
+		switch command {
+		case "prefix":
+			err = sh.settings.SetPrefix(m.GuildID, value)
+		case "helptrigger":
+			err = sh.settings.SetHelpTrigger(m.GuildID, value)
+		case "timezone":
+			err = sh.settings.SetTimeZone(m.GuildID, value)
+		case "modlog":
+			err = sh.settings.SetModLog(m.GuildID, value)
+		case "mytimezone":
+			err = sh.settings.SetUserTimeZone(m.Author.ID, value)
+		}
+
+		if err != nil {
+			sh.session.SendMessage(m.ChannelID, "Can't use \""+value+"\": "+err.Error())
+		} else {
+			sh.audit(command, before, sh.setting(command, m.GuildID, m.Author.ID))
+			sh.show(m.ChannelID, m.GuildID, m.Author.ID)
+		}
+	}
+}
+
+//setting describes the current value of the setting, for the audit log
+func (sh *SettingsHandler) setting(command string, guildID string, userID string) string {
+	switch command {
+	case "prefix":
+		return sh.settings.Prefix(guildID)
+	case "helptrigger":
+		return sh.settings.HelpTrigger(guildID)
+	case "timezone":
+		return sh.settings.TimeZone(guildID).String()
+	case "modlog":
+		return sh.settings.ModLog(guildID)
+	}
+
+	if location := sh.settings.UserTimeZone(userID); location != nil {
+		return location.String()
+	}
+	return ""
+}
+
+func (sh *SettingsHandler) show(channelID string, guildID string, userID string) {
+	message := "Command prefix: `" + sh.settings.Prefix(guildID) + "`\n"
+	message += "Help trigger: `" + sh.settings.HelpTrigger(guildID) + "`\n"
+	message += "Time zone: `" + sh.settings.TimeZone(guildID).String() + "`\n"
+	if modLog := sh.settings.ModLog(guildID); modLog != "" {
+		message += "Mod log: <#" + modLog + ">\n"
+	}
+	message += "Your time zone: `" + sh.settings.Location(guildID, userID).String() + "`"
+	sh.session.SendMessage(channelID, message)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+//ShutdownConfiguration Configures how we stop
+type ShutdownConfiguration struct {
+	//Timeout is how long to wait for handlers to finish up before giving up on them
+	Timeout string `json:"Timeout"`
+	//Message is posted to each of Channels as we go down, if set
+	Message  string   `json:"Message"`
+	Channels []string `json:"Channels"`
+}
+
+//stopHandlers posts the maintenance message, then waits for the scheduler, handlers and in-flight sends to finish
+//Handlers still running after the timeout have their context cancelled, and we stop waiting on them
+func stopHandlers(sender Session, config ShutdownConfiguration, cancel context.CancelFunc) {
+	timeout := defaultShutdownTimeout
+	if config.Timeout != "" {
+		if parsed, err := time.ParseDuration(config.Timeout); err == nil {
+			timeout = parsed
+		} else {
+			logger.Warn("Invalid shutdown timeout", "timeout", config.Timeout, "error", err)
+		}
+	}
+
+	if config.Message != "" {
+		for _, channelID := range config.Channels {
+			sender.SendMessage(channelID, config.Message)
+		}
+	}
+
+	//Stop scheduling first, so no job is waiting on a handler which has already gone
+	scheduler.Stop()
+
+	var stopped sync.WaitGroup
+	for i, handler := range handlers {
+		stopped.Add(1)
+		go func(handler MessageHandler, handlerQueue *HandlerQueue) {
+			defer stopped.Done()
+			if !handlerQueue.Close(timeout) {
+				//The handler's context is cancelled once we give up on it, which stops it without the signal
+				logger.Warn("Handler didn't take its stop signal", "handler", handler.GetName(), "timeout", timeout)
+			}
+			handler.Stop()
+			logger.Info("Stopped", "handler", handler.GetName())
+		}(handler, handlerQueues[i])
+	}
+
+	//Once the handlers are done, wait for everything they queued up to be sent
+	done := make(chan struct{})
+	go func() {
+		stopped.Wait()
+		MessageSender.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Warn("Handlers still running, stopping without them", "timeout", timeout)
+		cancel()
+	}
+
+	for _, handlerQueue := range handlerQueues {
+		if dropped := handlerQueue.Dropped(); dropped > 0 {
+			logger.Warn("Dropped events", "handler", handlerQueue.name, "dropped", dropped)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func useTestHandlers(t *testing.T, session Session, handler MessageHandler) *HandlerQueue {
+	queue := NewHandlerQueue(handler.GetName(), QueueConfiguration{})
+	handler.Init(context.Background(), queue.Channel())
+	scheduler = NewScheduler(session, NewJSONFileStore("."), SchedulerConfiguration{})
+	handlers, handlerQueues = []MessageHandler{handler}, []*HandlerQueue{queue}
+	t.Cleanup(func() { handlers, handlerQueues, scheduler = nil, nil, nil })
+
+	return queue
+}
+
+func TestStopHandlersFinishesQueuedWork(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	queue := useTestHandlers(t, session, NewReleaseHandler(session, NewJSONFileStore(".")))
+
+	queue.Push(&discordgo.MessageCreate{Message: &discordgo.Message{ID: "in1", ChannelID: "chan", Content: "/rw add " + daysAhead(30).Format("01/02/06") + " Persona 8", Author: &discordgo.User{ID: "user"}}})
+	stopHandlers(session, ShutdownConfiguration{Message: "Going down for maintenance", Channels: []string{"announcements"}}, func() {})
+
+	assertContains(t, session.LastSent("announcements"), "Going down for maintenance")
+
+	//The add queued before shutdown was handled and saved before we returned
+	var data []channelReleaseData
+	if err := NewJSONFileStore(".").Load(releaseDocument, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || len(data[0].Releases) != 1 || data[0].Releases[0].Name != "Persona 8" {
+		t.Errorf("expected the queued release to be saved, got %+v", data)
+	}
+}
+
+//stuckHandler Never gets around to reading its messages
+type stuckHandler struct {
+	handlerLoop
+	release chan struct{}
+}
+
+func (sh *stuckHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	sh.listen(ctx, sh.GetName(), m, func(*discordgo.MessageCreate) { <-sh.release })
+}
+
+func (sh *stuckHandler) GetName() string { return "Stuck Handler" }
+func (sh *stuckHandler) Help() string    { return "" }
+
+func TestStopHandlersGivesUpAfterTimeout(t *testing.T) {
+	useTempDir(t)
+	session := &FakeSession{}
+	handler := &stuckHandler{release: make(chan struct{})}
+	queue := useTestHandlers(t, session, handler)
+	defer close(handler.release)
+
+	queue.Push(&discordgo.MessageCreate{Message: &discordgo.Message{Content: "hello"}})
+	cancelled := false
+	start := time.Now()
+	stopHandlers(session, ShutdownConfiguration{Timeout: "50ms"}, func() { cancelled = true })
+
+	if !cancelled {
+		t.Error("expected handlers to be cancelled once the timeout passed")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to give up after the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//AutocompleteHandler is implemented by command handlers which can suggest values for their options
+//This is called outside of the handler's goroutine, so implementations should only read from a completionCache
+type AutocompleteHandler interface {
+	Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice
+}
+
+//maxChoices is the most autocomplete results discord will accept
+const maxChoices = 25
+
+//completionCache Holds autocomplete choices, keyed by channel, that handlers publish from their own goroutine
+type completionCache struct {
+	mutex  sync.RWMutex
+	values map[string][]*discordgo.ApplicationCommandOptionChoice
+}
+
+//Set replaces the choices available for the key
+func (cc *completionCache) Set(key string, choices []*discordgo.ApplicationCommandOptionChoice) {
+	cc.mutex.Lock()
+	if cc.values == nil {
+		cc.values = make(map[string][]*discordgo.ApplicationCommandOptionChoice)
+	}
+	cc.values[key] = choices
+	cc.mutex.Unlock()
+}
+
+//Match returns the choices for the key whose name contains the partial input
+func (cc *completionCache) Match(key string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	cc.mutex.RLock()
+	defer cc.mutex.RUnlock()
+	return matchChoices(cc.values[key], partial)
+}
+
+func matchChoices(choices []*discordgo.ApplicationCommandOptionChoice, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	partial = strings.ToLower(partial)
+	matches := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, choice := range choices {
+		if len(matches) == maxChoices {
+			break
+		}
+		if strings.Contains(strings.ToLower(choice.Name), partial) {
+			matches = append(matches, choice)
+		}
+	}
+
+	return matches
+}
+
+//buildApplicationCommands converts our text command definitions into discord application commands
+func buildApplicationCommands(commands []*Command) []*discordgo.ApplicationCommand {
+	appCommands := make([]*discordgo.ApplicationCommand, 0, len(commands))
+	for _, command := range commands {
+		appCommand := &discordgo.ApplicationCommand{
+			Name:        strings.TrimPrefix(command.Prefix, commandPrefix),
+			Description: command.Description,
+		}
+
+		if len(command.Subcommands) > 0 {
+			for _, subcommand := range command.Subcommands {
+				appCommand.Options = append(appCommand.Options, &discordgo.ApplicationCommandOption{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        subcommand.Name,
+					Description: subcommand.Description,
+					Options:     buildOptions(subcommand.Options),
+				})
+			}
+		} else {
+			appCommand.Options = buildOptions(command.Options)
+		}
+
+		appCommands = append(appCommands, appCommand)
+	}
+
+	return appCommands
+}
+
+func buildOptions(options []CommandOption) []*discordgo.ApplicationCommandOption {
+	appOptions := make([]*discordgo.ApplicationCommandOption, 0, len(options))
+	for _, option := range options {
+		appOption := &discordgo.ApplicationCommandOption{
+			Type:         option.Type,
+			Name:         option.Name,
+			Description:  option.Description,
+			Required:     option.Required,
+			Autocomplete: option.Autocomplete,
+			MinValue:     option.MinValue,
+			MaxValue:     option.MaxValue,
+		}
+		for _, choice := range option.Choices {
+			appOption.Choices = append(appOption.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+		}
+		appOptions = append(appOptions, appOption)
+	}
+
+	return appOptions
+}
+
+//interactionContent rebuilds the text command equivalent of an application command,
+//so it can take the same path through our handlers as a typed message would
+func interactionContent(command *Command, data discordgo.ApplicationCommandInteractionData) string {
+	parts := []string{command.Prefix}
+	declared := command.Options
+	provided := data.Options
+
+	if len(command.Subcommands) > 0 && len(provided) > 0 {
+		parts = append(parts, provided[0].Name)
+		if subcommand := command.subcommand(provided[0].Name); subcommand != nil {
+			declared = subcommand.Options
+		}
+		provided = provided[0].Options
+	}
+
+	//Text commands are positional, so write values out in the order we declared them
+	for _, option := range declared {
+		for _, value := range provided {
+			if value.Name == option.Name {
+				parts = append(parts, optionText(value, data.Resolved))
+			}
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func optionText(option *discordgo.ApplicationCommandInteractionDataOption, resolved *discordgo.ApplicationCommandInteractionDataResolved) string {
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.FormatInt(option.IntValue(), 10)
+	case discordgo.ApplicationCommandOptionBoolean:
+		return strconv.FormatBool(option.BoolValue())
+	case discordgo.ApplicationCommandOptionUser:
+		return "<@" + fmt.Sprint(option.Value) + ">"
+	case discordgo.ApplicationCommandOptionRole:
+		return "<@&" + fmt.Sprint(option.Value) + ">"
+	case discordgo.ApplicationCommandOptionChannel:
+		return "<#" + fmt.Sprint(option.Value) + ">"
+	case discordgo.ApplicationCommandOptionMentionable:
+		//Roles and users share an ID space, so check which this resolved to
+		id := fmt.Sprint(option.Value)
+		if resolved != nil {
+			if _, isRole := resolved.Roles[id]; isRole {
+				return "<@&" + id + ">"
+			}
+		}
+		return "<@" + id + ">"
+	default:
+		return fmt.Sprint(option.Value)
+	}
+}
+
+//focusedOption finds the subcommand and option currently being autocompleted
+func focusedOption(data discordgo.ApplicationCommandInteractionData) (string, *discordgo.ApplicationCommandInteractionDataOption) {
+	subcommand := ""
+	options := data.Options
+	if len(options) > 0 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		subcommand = options[0].Name
+		options = options[0].Options
+	}
+
+	for _, option := range options {
+		if option.Focused {
+			return subcommand, option
+		}
+	}
+
+	return subcommand, nil
+}
+
+//registerSlashCommands publishes all of our commands to discord as global application commands
+func registerSlashCommands(s *discordgo.Session) {
+	appCommands := buildApplicationCommands(commandRouter.Commands())
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, "", appCommands); err != nil {
+		logger.Error("Error registering slash commands", "error", err)
+	} else {
+		logger.Info("Registered slash commands", "commands", len(appCommands))
+	}
+}
+
+//interactionCreate handles slash commands, their autocomplete requests, and our message controls
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type == discordgo.InteractionMessageComponent {
+		if strings.HasPrefix(i.MessageComponentData().CustomID, pageComponentPrefix) {
+			turnPage(s, i)
+		}
+		return
+	}
+
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	command, handler, ok := commandRouter.Lookup(commandPrefix + data.Name)
+	if !ok {
+		return
+	}
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+		subcommand, focused := focusedOption(data)
+		if completer, ok := handler.(AutocompleteHandler); ok && focused != nil {
+			choices = completer.Autocomplete(i.ChannelID, command.Prefix, subcommand, focused.Name, fmt.Sprint(focused.Value))
+		}
+
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{Choices: choices},
+		})
+		if err != nil {
+			logger.Error("Error responding to autocomplete", "channel", i.ChannelID, "error", err)
+		}
+		return
+	}
+
+	author := i.User
+	var member *discordgo.Member
+	if i.Member != nil {
+		member = i.Member
+		author = i.Member.User
+	}
+
+	content := interactionContent(command, data)
+
+	//Handlers reply in the channel as they would for text commands, so just acknowledge privately
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Running `" + localizeCommands(i.GuildID, content) + "`",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		logger.Error("Error responding to interaction", "channel", i.ChannelID, "error", err)
+	}
+
+	//No ID, as there's no message of the user's for handlers to clean up
+	commandRouter.RouteCommand(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Content:   content,
+		Author:    author,
+		Member:    member,
+	}})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInteractionContentOrdersOptions(t *testing.T) {
+	commands := NewImageHandler(&FakeSession{}, NewJSONFileStore(".")).Commands()
+
+	//Discord doesn't promise option order, so provide them shuffled
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "i",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "start",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "repeat", Type: discordgo.ApplicationCommandOptionBoolean, Value: true},
+				{Name: "hour", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(9)},
+				{Name: "dir", Type: discordgo.ApplicationCommandOptionString, Value: "comic"},
+				{Name: "pages", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(2)},
+				{Name: "frequency", Type: discordgo.ApplicationCommandOptionString, Value: "daily"},
+			},
+		}},
+	}
+
+	content := interactionContent(&commands[0], data)
+	if content != "/i start comic daily 9 2 true" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestInteractionContentWithoutSubcommands(t *testing.T) {
+	commands := NewAlternatingCaseHandler(&FakeSession{}).Commands()
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "ac",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{Name: "text", Type: discordgo.ApplicationCommandOptionString, Value: "hello there"},
+		},
+	}
+
+	content := interactionContent(&commands[0], data)
+	if content != "/ac hello there" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestBuildApplicationCommands(t *testing.T) {
+	router := NewCommandRouter(&FakeSession{}, NewGuildSettingsStore(NewJSONFileStore("."), "!test", time.UTC), NewPermissionStore(&FakeSession{}, NewJSONFileStore("."), nil))
+	router.Register(NewReleaseHandler(&FakeSession{}, NewJSONFileStore(".")), NewHandlerQueue("Release Handler", QueueConfiguration{}))
+
+	appCommands := buildApplicationCommands(router.Commands())
+	if len(appCommands) != 1 || appCommands[0].Name != "rw" {
+		t.Fatalf("expected rw command, got %+v", appCommands)
+	}
+
+	options := appCommands[0].Options
+	if len(options) != 6 || options[2].Name != "edit" || options[2].Type != discordgo.ApplicationCommandOptionSubCommand {
+		t.Fatalf("unexpected subcommands %+v", options)
+	}
+	if !options[2].Options[0].Autocomplete || options[2].Options[0].Type != discordgo.ApplicationCommandOptionInteger {
+		t.Errorf("expected autocompleted integer release id, got %+v", options[2].Options[0])
+	}
+}
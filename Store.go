@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//ErrNotFound is returned by Store.Load when a document has never been saved
+var ErrNotFound = errors.New("document not found")
+
+//Store Persists named documents, each holding one handler's saved data as JSON
+type Store interface {
+	//Load reads the named document into v, returning ErrNotFound if it doesn't exist yet
+	Load(name string, v interface{}) error
+	//Save replaces the named document with v
+	Save(name string, v interface{}) error
+	Close() error
+}
+
+//strictStore Is implemented by stores which recover damaged documents as they're loaded
+//Reloads skip the recovery, so a broken edit is reported rather than quietly swapped for a backup
+type strictStore interface {
+	LoadStrict(name string, v interface{}) error
+}
+
+//loadStrict loads the document without any recovery the store would otherwise attempt
+func loadStrict(store Store, name string, v interface{}) error {
+	if strict, ok := store.(strictStore); ok {
+		return strict.LoadStrict(name, v)
+	}
+
+	return store.Load(name, v)
+}
+
+//describeJSONError adds the line a syntax error is on, which is far more use than its byte offset
+func describeJSONError(data []byte, err error) error {
+	if syntax, ok := err.(*json.SyntaxError); ok {
+		line := 1 + strings.Count(string(data[:syntax.Offset]), "\n")
+		return fmt.Errorf("line %d: %v", line, err)
+	}
+
+	return err
+}
+
+//StorageConfiguration Selects where handler data is kept
+type StorageConfiguration struct {
+	//Backend is json (default) or bolt
+	Backend string `json:"Backend"`
+	//Path is the directory for json files, or the database file for bolt
+	Path string `json:"Path"`
+	//Backups is how many previous versions of each json file to keep, default 5. Use -1 to keep none
+	Backups int `json:"Backups"`
+	//BackupEvery is how long to wait between backups of a json file, default 1h, so a run of bad saves can't replace them all
+	//It's also how much can be lost: a file recovered from its newest backup loses anything saved in up to that long since
+	BackupEvery string `json:"BackupEvery"`
+}
+
+//Document names used by our handlers. The json backend stores each as <name>.json
+const (
+	releaseDocument        = "releaseData"
+	reminderDocument       = "ReminderData"
+	imageDocument          = "imageData"
+	reactionDocument       = "reactionData"
+	fortuneDocument        = "fortuneData"
+	guildSettingsDocument  = "guildSettings"
+	permissionsDocument    = "permissions"
+	schedulerDocument      = "schedulerState"
+	userSettingsDocument   = "userSettings"
+	handlerTogglesDocument = "handlerToggles"
+)
+
+//migratedDocuments are imported from json files by --migrate
+var migratedDocuments = []string{
+	releaseDocument,
+	reminderDocument,
+	imageDocument,
+	reactionDocument,
+	fortuneDocument,
+	guildSettingsDocument,
+	permissionsDocument,
+	schedulerDocument,
+	userSettingsDocument,
+	handlerTogglesDocument,
+}
+
+//OpenStore creates the store selected by the configuration
+func OpenStore(configuration StorageConfiguration) (Store, error) {
+	switch configuration.Backend {
+	case "", "json":
+		store := NewJSONFileStore(dataPath(configuration.Path))
+		if configuration.Backups != 0 {
+			store.backups = configuration.Backups
+		}
+		if configuration.BackupEvery != "" {
+			every, err := time.ParseDuration(configuration.BackupEvery)
+			if err != nil {
+				return nil, fmt.Errorf("Storage.BackupEvery: %v", err)
+			}
+			store.backupEvery = every
+		}
+		return store, nil
+	case "bolt":
+		path := configuration.Path
+		if path == "" {
+			path = "diskhard.db"
+		}
+		return NewBoltStore(dataPath(path))
+	}
+
+	return nil, fmt.Errorf("unknown storage backend %q", configuration.Backend)
+}
+
+//migrateStore copies each named document from one store to another, skipping any the destination already has
+func migrateStore(from Store, to Store, names []string) error {
+	for _, name := range names {
+		var existing json.RawMessage
+		if err := to.Load(name, &existing); err == nil {
+			logger.Info("Skipping document, it has already been migrated", "document", name)
+			continue
+		} else if err != ErrNotFound {
+			return err
+		}
+
+		var document json.RawMessage
+		err := from.Load(name, &document)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("reading %s: %v", name, err)
+		}
+
+		if err = to.Save(name, document); err != nil {
+			return fmt.Errorf("saving %s: %v", name, err)
+		}
+		logger.Info("Migrated document", "document", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoresRoundTrip(t *testing.T) {
+	dir := useTempDir(t)
+	bolt, err := NewBoltStore(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	stores := map[string]Store{"json": NewJSONFileStore(dir), "bolt": bolt}
+	for name, store := range stores {
+		var missing []string
+		if err := store.Load("channels", &missing); err != ErrNotFound {
+			t.Errorf("%s: expected ErrNotFound for a new document, got %v", name, err)
+		}
+
+		if err := store.Save("channels", []string{"a", "b"}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var loaded []string
+		if err := store.Load("channels", &loaded); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(loaded) != 2 || loaded[0] != "a" || loaded[1] != "b" {
+			t.Errorf("%s: loaded %v", name, loaded)
+		}
+	}
+}
+
+func TestMigrateStoreImportsJSONFiles(t *testing.T) {
+	dir := useTempDir(t)
+	session := &FakeSession{}
+	harness := newHandlerHarness(t, NewReleaseHandler(session, NewJSONFileStore(dir)))
+	harness.Say("releases", "alice", "/rw add "+daysAhead(30).Format("01/02/06")+" Some Game")
+
+	bolt, err := NewBoltStore(filepath.Join(dir, "diskhard.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	if err := migrateStore(NewJSONFileStore(dir), bolt, migratedDocuments); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []channelReleaseData
+	if err := bolt.Load(releaseDocument, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || len(data[0].Releases) != 1 || data[0].Releases[0].Name != "Some Game" {
+		t.Errorf("unexpected migrated data %+v", data)
+	}
+
+	var reminders []channelReminderData
+	if err := bolt.Load(reminderDocument, &reminders); err != ErrNotFound {
+		t.Errorf("expected documents without a json file to stay missing, got %v", err)
+	}
+}
+
+func TestJSONFileStoreKeepsRotatingBackups(t *testing.T) {
+	dir := useTempDir(t)
+	store := NewJSONFileStore(dir)
+	store.backups = 2
+	store.backupEvery = 0
+
+	for x := 1; x <= 4; x++ {
+		if err := store.Save("counter", x); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := store.listBackups("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+
+	//The newest backup holds the version before the current one
+	var previous int
+	data, _ := ioutil.ReadFile(backups[1])
+	if err := json.Unmarshal(data, &previous); err != nil || previous != 3 {
+		t.Errorf("expected newest backup to hold 3, got %d (%v)", previous, err)
+	}
+}
+
+func TestJSONFileStoreFallsBackToNewestValidBackup(t *testing.T) {
+	dir := useTempDir(t)
+	store := NewJSONFileStore(dir)
+	store.backupEvery = 0
+	store.Save("counter", 1)
+	store.Save("counter", 2)
+	store.Save("counter", 3)
+
+	//Simulate a crash mid-write from before writes were atomic
+	if err := ioutil.WriteFile(store.path("counter"), []byte(`[{"trunc`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := store.listBackups("counter")
+	taken := time.Now().Add(-2 * time.Hour)
+	for _, backup := range backups {
+		os.Chtimes(backup, taken, taken)
+	}
+
+	var out bytes.Buffer
+	previous := logger
+	logger = NewLogger(&out, InfoLevel, false)
+	t.Cleanup(func() { logger = previous })
+
+	var loaded int
+	if err := store.Load("counter", &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 {
+		t.Errorf("expected the newest backup, 2, got %d", loaded)
+	}
+	//Two hours of changes could be gone, which is worth more than an info line
+	assertContains(t, out.String(), `level=error msg="Recovered document from backup, losing any changes since" document=counter`)
+	assertContains(t, out.String(), "age=2h0m0s")
+
+	//Saving over the corrupt file must not rotate it into the backups
+	store.Save("counter", 4)
+	backups, _ = store.listBackups("counter")
+	for _, backup := range backups {
+		data, _ := ioutil.ReadFile(backup)
+		if !json.Valid(data) {
+			t.Errorf("corrupt file was backed up as %s", backup)
+		}
+	}
+}
+
+func TestJSONFileStoreFallsBackOnEmptyAndMismatchedFiles(t *testing.T) {
+	dir := useTempDir(t)
+	store := NewJSONFileStore(dir)
+	store.backupEvery = 0
+	store.Save("channels", []string{"a"})
+	store.Save("channels", []string{"b"})
+
+	for _, contents := range []string{"", `{"not":"a list"}`} {
+		writeTestFile(t, store.path("channels"), contents)
+
+		var loaded []string
+		if err := store.Load("channels", &loaded); err != nil {
+			t.Fatalf("%q: %v", contents, err)
+		}
+		if len(loaded) != 1 || loaded[0] != "a" {
+			t.Errorf("%q: expected the backup, [a], got %v", contents, loaded)
+		}
+	}
+}
+
+func TestJSONFileStoreSpacesOutBackups(t *testing.T) {
+	dir := useTempDir(t)
+	store := NewJSONFileStore(dir)
+	store.backupEvery = time.Hour
+
+	for x := 1; x <= 4; x++ {
+		store.Save("counter", x)
+	}
+
+	backups, _ := store.listBackups("counter")
+	if len(backups) != 1 {
+		t.Fatalf("expected a single backup within the hour, got %v", backups)
+	}
+
+	//Once the backup is old enough, the next save takes another
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(backups[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	store.Save("counter", 5)
+
+	if backups, _ = store.listBackups("counter"); len(backups) != 2 {
+		t.Fatalf("expected a second backup after the hour, got %v", backups)
+	}
+	var previous int
+	data, _ := ioutil.ReadFile(backups[1])
+	if err := json.Unmarshal(data, &previous); err != nil || previous != 4 {
+		t.Errorf("expected newest backup to hold 4, got %d (%v)", previous, err)
+	}
+}
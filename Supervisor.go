@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const defaultMaxRestarts = 5
+const defaultRestartBackoff = time.Second
+const maxRestartBackoff = time.Minute
+
+//restartResetAfter is how long a handler has to go without panicking before its failures are forgotten
+const restartResetAfter = 10 * time.Minute
+
+//SupervisorConfiguration Configures how handlers are restarted after a panic
+type SupervisorConfiguration struct {
+	//AdminChannel is told whenever a handler panics or is disabled, if set
+	AdminChannel string `json:"AdminChannel"`
+	//MaxRestarts is how many times a handler may be restarted before it's disabled
+	MaxRestarts int `json:"MaxRestarts"`
+	//Backoff is how long to wait before the first restart, doubling with each one after
+	Backoff string `json:"Backoff"`
+}
+
+//Supervisor Recovers handlers which panic, restarting them with backoff and disabling those which keep failing
+type Supervisor struct {
+	mutex        sync.Mutex
+	session      Session
+	adminChannel string
+	maxRestarts  int
+	backoff      time.Duration
+	failures     map[string]int
+	lastFailure  map[string]time.Time
+	disabled     map[string]bool
+}
+
+//NewSupervisor builds a supervisor from configuration, falling back to defaults for anything unset or invalid
+func NewSupervisor(session Session, config SupervisorConfiguration) *Supervisor {
+	maxRestarts := config.MaxRestarts
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMaxRestarts
+	}
+
+	backoff := defaultRestartBackoff
+	if config.Backoff != "" {
+		if parsed, err := time.ParseDuration(config.Backoff); err == nil && parsed > 0 {
+			backoff = parsed
+		} else {
+			logger.Warn("Invalid restart backoff", "backoff", config.Backoff, "error", err)
+		}
+	}
+
+	return &Supervisor{
+		session:      session,
+		adminChannel: config.AdminChannel,
+		maxRestarts:  maxRestarts,
+		backoff:      backoff,
+		failures:     make(map[string]int),
+		lastFailure:  make(map[string]time.Time),
+		disabled:     make(map[string]bool),
+	}
+}
+
+//Supervise runs the handler's loop until it returns, restarting it after a backoff whenever it panics
+//current describes what the loop is working on, for reporting. Returns false if the handler was disabled
+//Tasks sent while waiting to restart are still run, so the scheduler and anything waiting on the handler aren't held up
+func (s *Supervisor) Supervise(ctx context.Context, name string, current *string, loop func(), tasks <-chan func()) bool {
+	if s == nil {
+		loop()
+		return true
+	}
+
+	for s.runOnce(name, current, loop) {
+		backoff, disabled := s.failed(name)
+		if disabled {
+			return false
+		}
+
+		logger.Info("Restarting", "handler", name, "backoff", backoff)
+		if !s.wait(ctx, name, current, backoff, tasks) {
+			return true
+		}
+	}
+
+	return true
+}
+
+//wait runs tasks until the backoff is over, returning false if ctx was cancelled first
+//A task which panics is reported, but doesn't count against the handler as it isn't running
+func (s *Supervisor) wait(ctx context.Context, name string, current *string, backoff time.Duration, tasks <-chan func()) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case task := <-tasks:
+			*current = "a scheduled job"
+			s.runOnce(name, current, task)
+			*current = ""
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+//Start runs the handler's Init, returning false (and disabling the handler) if it panics
+//There's no restarting a handler which couldn't set itself up
+func (s *Supervisor) Start(name string, init func()) (started bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.report(name, "starting up", r)
+			s.disable(name, "it failed to start")
+			started = false
+		}
+	}()
+
+	init()
+	return true
+}
+
+//Disabled reports whether the handler failed too often and has been switched off
+func (s *Supervisor) Disabled(name string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.disabled[name]
+}
+
+//runOnce runs the loop, returning true if it panicked
+func (s *Supervisor) runOnce(name string, current *string, loop func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.report(name, *current, r)
+			panicked = true
+		}
+	}()
+
+	loop()
+	return false
+}
+
+//failed counts a failure of the handler, returning how long to wait before restarting it or if it's now disabled
+func (s *Supervisor) failed(name string) (time.Duration, bool) {
+	s.mutex.Lock()
+	now := time.Now()
+	if now.Sub(s.lastFailure[name]) > restartResetAfter {
+		s.failures[name] = 0
+	}
+	s.failures[name]++
+	s.lastFailure[name] = now
+	failures := s.failures[name]
+	s.mutex.Unlock()
+
+	if failures > s.maxRestarts {
+		s.disable(name, fmt.Sprintf("it failed %d times", failures))
+		return 0, true
+	}
+
+	backoff := s.backoff
+	for i := 1; i < failures && backoff < maxRestartBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRestartBackoff {
+		backoff = maxRestartBackoff
+	}
+
+	s.notify(fmt.Sprintf("%s crashed, restarting in %s (%d of %d restarts)", name, backoff, failures, s.maxRestarts))
+	return backoff, false
+}
+
+func (s *Supervisor) disable(name string, reason string) {
+	s.mutex.Lock()
+	s.disabled[name] = true
+	s.mutex.Unlock()
+
+	logger.Error("Disabled", "handler", name, "reason", reason)
+	s.notify(name + " has been disabled as " + reason + ". It'll be back when the bot restarts")
+}
+
+func (s *Supervisor) report(name string, current string, r interface{}) {
+	if current == "" {
+		current = "nothing in particular"
+	}
+	logger.Error("Panicked", "handler", name, "handling", current, "panic", r, "stack", string(debug.Stack()))
+}
+
+func (s *Supervisor) notify(message string) {
+	if s.adminChannel != "" {
+		s.session.SendMessage(s.adminChannel, message)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//panickyHandler Panics on every message which says so
+type panickyHandler struct {
+	handlerLoop
+	handled chan string
+}
+
+func (ph *panickyHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	ph.listen(ctx, ph.GetName(), m, func(message *discordgo.MessageCreate) {
+		if message.Content == "panic" {
+			panic("told to")
+		}
+		ph.handled <- message.Content
+	})
+}
+
+func (ph *panickyHandler) GetName() string { return "Panicky Handler" }
+func (ph *panickyHandler) Help() string    { return "" }
+
+func useTestSupervisor(t *testing.T, session Session, config SupervisorConfiguration) {
+	supervisor = NewSupervisor(session, config)
+	t.Cleanup(func() { supervisor = nil })
+}
+
+func TestSupervisorRestartsThenDisables(t *testing.T) {
+	session := &FakeSession{}
+	useTestSupervisor(t, session, SupervisorConfiguration{AdminChannel: "admin", MaxRestarts: 2, Backoff: "1ms"})
+
+	handler := &panickyHandler{handled: make(chan string)}
+	channel := make(chan *discordgo.MessageCreate)
+	handler.Init(context.Background(), channel)
+
+	//Restarted after the first panic, so it carries on with the next message
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "panic"}}
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "hello"}}
+	if handled := <-handler.handled; handled != "hello" {
+		t.Errorf("expected hello to be handled after the restart, got %q", handled)
+	}
+
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "panic"}}
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "panic"}}
+	//Disabled handlers still take their messages, so nothing feeding them gets stuck, but ignore them
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "ignored"}}
+	channel <- nil
+	handler.Stop()
+
+	if !supervisor.Disabled(handler.GetName()) {
+		t.Error("expected the handler to be disabled after its third panic")
+	}
+	admin := strings.Join(session.Sent("admin"), "\n")
+	assertContains(t, admin, "Panicky Handler crashed, restarting in 1ms (1 of 2 restarts)")
+	assertContains(t, admin, "Panicky Handler crashed, restarting in 2ms (2 of 2 restarts)")
+	assertContains(t, admin, "Panicky Handler has been disabled as it failed 3 times")
+}
+
+func TestSupervisorDisablesHandlersWhichFailToStart(t *testing.T) {
+	session := &FakeSession{}
+	useTestSupervisor(t, session, SupervisorConfiguration{AdminChannel: "admin"})
+
+	if supervisor.Start("Broken Handler", func() { panic("bad trigger word") }) {
+		t.Error("expected start to fail")
+	}
+	if !supervisor.Disabled("Broken Handler") {
+		t.Error("expected the handler to be disabled")
+	}
+	assertContains(t, session.LastSent("admin"), "Broken Handler has been disabled as it failed to start")
+}
+
+func TestHandlersRunTasksWhileWaitingToRestart(t *testing.T) {
+	useTestSupervisor(t, &FakeSession{}, SupervisorConfiguration{Backoff: "1m"})
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &panickyHandler{handled: make(chan string)}
+	channel := make(chan *discordgo.MessageCreate)
+	handler.Init(ctx, channel)
+	t.Cleanup(func() {
+		cancel()
+		handler.Stop()
+	})
+
+	channel <- &discordgo.MessageCreate{Message: &discordgo.Message{Content: "panic"}}
+	done := make(chan error)
+	go func() { done <- handler.do(func() {}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the task to run, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the task to run during the backoff rather than wait for the restart")
+	}
+}
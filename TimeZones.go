@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	//Embed the zone database, so IANA names work wherever the bot runs
+	_ "time/tzdata"
+)
+
+//defaultTimeZone clears a time zone override
+const defaultTimeZone = "default"
+
+//validateTimeZone checks the IANA zone name, eg America/New_York, returning what should be saved for it
+//default is saved as empty, meaning no override
+func validateTimeZone(zone string) (string, error) {
+	if zone == "" {
+		return "", errors.New("it can't be empty")
+	}
+	if zone == defaultTimeZone {
+		return "", nil
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", fmt.Errorf("%s isn't a time zone, use a name like America/New_York", zone)
+	}
+
+	return location.String(), nil
+}
+
+//itemLocation loads a saved time zone, using the fallback if it's unset or no longer valid
+func itemLocation(zone string, fallback *time.Location) *time.Location {
+	if zone == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		logger.Error("Error loading time zone", "zone", zone, "error", err)
+		return fallback
+	}
+
+	return location
+}
+
+//channelItemLocation loads the saved time zone of an item posted to the channel
+//Items without one, eg saved before zones always were or reset to the default, follow the channel's guild
+func channelItemLocation(session Session, channelID string, zone string) *time.Location {
+	if location := itemLocation(zone, nil); location != nil {
+		return location
+	}
+
+	return userLocation(channelGuild(session, channelID), "")
+}
+
+//userLocation returns the user's time zone in the guild, falling back to the guild's and then the bot's
+//Either ID may be empty
+func userLocation(guildID string, userID string) *time.Location {
+	if guildSettingsStore == nil {
+		return time.Local
+	}
+
+	return guildSettingsStore.Location(guildID, userID)
+}
+
+//dueAt returns when something due at the local hour and minute happens on occurrence's local day
+//Times skipped by a DST change move forward, and repeated ones only happen once
+func dueAt(occurrence time.Time, location *time.Location, hour int, minute int) time.Time {
+	local := occurrence.In(location)
+	due := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, location)
+
+	//time.Date may resolve a skipped time to before the gap, so push it past by however far short it fell
+	wanted := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, time.UTC)
+	got := time.Date(due.Year(), due.Month(), due.Day(), due.Hour(), due.Minute(), 0, 0, time.UTC)
+	if got.Before(wanted) {
+		due = due.Add(wanted.Sub(got))
+	}
+
+	return due
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueAtAcrossDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//09:00 stays 09:00 local on either side of the change, so moves an hour in UTC
+	before := dueAt(time.Date(2030, time.March, 9, 12, 0, 0, 0, newYork), newYork, 9, 0)
+	after := dueAt(time.Date(2030, time.March, 10, 12, 0, 0, 0, newYork), newYork, 9, 0)
+	if before.UTC().Hour() != 14 || after.UTC().Hour() != 13 {
+		t.Errorf("expected 14:00 then 13:00 UTC, got %v and %v", before.UTC(), after.UTC())
+	}
+
+	//02:30 doesn't exist on the day clocks go forward, so it happens at 03:30 instead
+	skipped := dueAt(time.Date(2030, time.March, 10, 12, 0, 0, 0, newYork), newYork, 2, 30)
+	if skipped.Hour() != 3 || skipped.Minute() != 30 {
+		t.Errorf("expected skipped time to move to 03:30, got %v", skipped)
+	}
+
+	//The day is the location's, not the occurrence's
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	due := dueAt(time.Date(2030, time.March, 5, 20, 0, 0, 0, time.UTC), tokyo, 9, 0)
+	if due.Day() != 6 {
+		t.Errorf("expected 09:00 on the 6th in Tokyo, got %v", due)
+	}
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	if zone, err := validateTimeZone("Europe/London"); err != nil || zone != "Europe/London" {
+		t.Errorf("expected Europe/London, got %q %v", zone, err)
+	}
+	if zone, err := validateTimeZone(defaultTimeZone); err != nil || zone != "" {
+		t.Errorf("expected default to clear the zone, got %q %v", zone, err)
+	}
+	if _, err := validateTimeZone("Mars/Olympus_Mons"); err == nil {
+		t.Error("expected an unknown zone to be rejected")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+//ToggleHandler lets guild admins switch handlers on and off in their guild, or in particular channels
+type ToggleHandler struct {
+	handlerLoop
+	session Session
+	toggles *HandlerToggleStore
+	matcher regexp.Regexp
+}
+
+const handlersCommand = "/handlers"
+
+//NewToggleHandler creates a handler which manages the provided toggles
+func NewToggleHandler(session Session, toggles *HandlerToggleStore) *ToggleHandler {
+	return &ToggleHandler{session: session, toggles: toggles}
+}
+
+//Init compiles our regexp and spins up our channel handling
+func (th *ToggleHandler) Init(ctx context.Context, m chan *discordgo.MessageCreate) {
+	//subcommand, then the handler and where, both optional
+	th.matcher = *regexp.MustCompile(`^` + handlersCommand + `\s+(\w+)\s*(\S*)\s*(\w*)\s*$`)
+
+	th.listen(ctx, th.GetName(), m, th.handleMessage)
+}
+
+//GetName returns our name
+func (th *ToggleHandler) GetName() string {
+	return "Toggle Handler"
+}
+
+//Commands returns the commands owned by this handler
+func (th *ToggleHandler) Commands() []Command {
+	toggleOptions := []CommandOption{
+		{Name: "handler", Description: "Handler, from " + handlersCommand + " list", Type: discordgo.ApplicationCommandOptionString, Required: true, Autocomplete: true},
+		{Name: "where", Description: "Just this channel, or the whole server", Type: discordgo.ApplicationCommandOptionString, Choices: []string{"channel", "server"}},
+	}
+
+	return []Command{{
+		Prefix:      handlersCommand,
+		Description: "Handlers - Switch handlers on and off in this server or channel",
+		Subcommands: []Subcommand{
+			{Name: "list", Description: "List handlers and whether they're on in this channel"},
+			{Name: "enable", Description: "Switch a handler on", Options: toggleOptions, Capability: "handlers.manage"},
+			{Name: "disable", Description: "Switch a handler off", Options: toggleOptions, Capability: "handlers.manage"},
+			{Name: "reset", Description: "Go back to the server's setting, or the handler's default", Options: toggleOptions, Capability: "handlers.manage"},
+		},
+	}}
+}
+
+//Autocomplete suggests the handlers which can be switched off
+func (th *ToggleHandler) Autocomplete(channelID string, command string, subcommand string, option string, partial string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	if option == "handler" {
+		for _, state := range th.toggles.States("", channelID) {
+			if state.Source != "core" {
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: state.Name, Value: handlerKey(state.Name)})
+			}
+		}
+	}
+
+	return matchChoices(choices, partial)
+}
+
+//Help Gets info about this handler
+func (th *ToggleHandler) Help() string {
+	return handlersCommand + " : Handlers - Switch handlers on and off in this server or channel"
+}
+
+func (th *ToggleHandler) handleMessage(m *discordgo.MessageCreate) {
+	submatches := th.matcher.FindStringSubmatch(m.Content)
+	if submatches == nil {
+		return
+	}
+
+	var on *bool
+	switch submatches[1] {
+	case "list":
+		th.list(m.ChannelID, m.GuildID)
+		return
+	case "enable":
+		enabled := true
+		on = &enabled
+	case "disable":
+		disabled := false
+		on = &disabled
+	case "reset":
+	default:
+		return
+	}
+
+	th.toggle(m, submatches[2], submatches[3], on)
+}
+
+//toggle switches the handler on, off or back to what it was by default in the channel or server
+func (th *ToggleHandler) toggle(m *discordgo.MessageCreate, handler string, where string, on *bool) {
+	name, ok := th.toggles.Lookup(handler)
+	if handler == "" || !ok {
+		th.session.SendMessage(m.ChannelID, localizeCommands(m.GuildID, "Usage: "+handlersCommand+" <enable|disable|reset> <handler> [channel|server], see "+handlersCommand+" list for handlers"))
+		return
+	}
+	if th.toggles.Core(name) {
+		th.session.SendMessage(m.ChannelID, name+" manages the bot, so it can't be switched off")
+		return
+	}
+
+	channelID := m.ChannelID
+	place := "this channel"
+	switch where {
+	case "", "channel":
+	case "server":
+		if m.GuildID == "" {
+			th.session.SendMessage(m.ChannelID, "Handlers can only be switched on and off for a server from within it")
+			return
+		}
+		channelID = ""
+		place = "this server"
+	default:
+		th.session.SendMessage(m.ChannelID, "Handlers can be switched on and off for a channel or server, not "+where)
+		return
+	}
+
+	before := "off"
+	if th.toggles.Active(name, m.GuildID, m.ChannelID) {
+		before = "on"
+	}
+	if err := th.toggles.Set(m.GuildID, channelID, name, on); err != nil {
+		th.log.Error("Error saving handler toggles", "error", err)
+		th.session.SendMessage(m.ChannelID, "Error saving handler toggles: "+err.Error())
+		return
+	}
+
+	state := "off"
+	if th.toggles.Active(name, m.GuildID, m.ChannelID) {
+		state = "on"
+	}
+	th.audit("toggle "+handlerKey(name), before, state)
+	if on == nil {
+		th.session.SendMessage(m.ChannelID, "Reset "+name+" in "+place+", it's now "+state+" here")
+	} else {
+		th.session.SendMessage(m.ChannelID, "Switched "+name+" "+state+" in "+place)
+	}
+}
+
+func (th *ToggleHandler) list(channelID string, guildID string) {
+	embed := newEmbed("Handlers")
+	embed.Description = localizeCommands(guildID, "Switch handlers on and off with "+handlersCommand+" enable or disable")
+	for _, state := range th.toggles.States(guildID, channelID) {
+		status := "Off"
+		if state.Active {
+			status = "On"
+		}
+		switch state.Source {
+		case "core":
+			status += ", always"
+		case "channel":
+			status += " in this channel"
+		case "server":
+			status += " in this server"
+		default:
+			status += " by default"
+		}
+		embed.Fields = append(embed.Fields, embedField(state.Name, handlerKey(state.Name)+": "+status, false))
+	}
+
+	_, _ = sendPaged(th.session, channelID, embed)
+}
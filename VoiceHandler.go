@@ -52,7 +52,7 @@ func (vh *VoiceHandler) HandleMessage(s *discordgo.Session, m *discordgo.Message
 						//nothin!
 						vc.Speaking(false)
 					} else {
-						fmt.Println("Error joining channel", err)
+						logger.Error("Error joining channel", "error", err)
 					}
 				*/
 			}
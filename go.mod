@@ -3,7 +3,8 @@ module JonMartel/Diskhard
 go 1.13
 
 require (
-	github.com/bwmarrin/discordgo v0.22.1
+	github.com/bwmarrin/discordgo v0.27.1
 	github.com/sqs/goreturns v0.0.0-20181028201513-538ac6014518 // indirect
+	go.etcd.io/bbolt v1.3.6
 	google.golang.org/genproto v0.0.0-20210114201628-6edceaf6022f // indirect
 )
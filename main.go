@@ -1,129 +1,302 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
-	"regexp"
+	"strings"
 	"syscall"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-//Configuration Struct used to store config info from json
-type Configuration struct {
-	Token string `json:"Token"`
-	Name  string `json:"Name"`
-}
-
 var handlers []MessageHandler
-var handlerChannels []chan *discordgo.MessageCreate
-var nameRegex regexp.Regexp
+var handlerQueues []*HandlerQueue
+var commandRouter *CommandRouter
+var scheduler *Scheduler
+var supervisor *Supervisor
+var guildSettingsStore *GuildSettingsStore
+var reloader *Reloader
 
 //var session *discordgo.Session
 
 var MessageSender Messager
+var slashCommands bool
 
 func main() {
+	console := flag.Bool("console", false, "Run handlers against stdin/stdout instead of connecting to discord")
+	migrate := flag.Bool("migrate", false, "Import existing json data files into the configured storage backend, then exit")
+	flag.StringVar(&configurationFile, "config", configurationDefault(), "Configuration file to read, also set by DISKHARD_CONFIG")
+	flag.Parse()
 
-	configuration := Init()
-	session, err := discordgo.New("Bot " + configuration.Token)
+	//diskhard config check [--config file] validates the configuration and data files, then exits
+	if flag.Arg(0) == "config" {
+		if flag.Arg(1) != "check" {
+			fmt.Fprintln(os.Stderr, "Usage: diskhard config check [--config file]")
+			os.Exit(2)
+		}
+		flag.CommandLine.Parse(flag.Args()[2:])
+		if !checkConfiguration(configurationFile, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	configuration, err := loadConfiguration(configurationFile)
+	if os.IsNotExist(err) && *console {
+		logger.Warn("No configuration file, using defaults", "path", configurationFile)
+	} else if err != nil {
+		logger.Error("Error reading configuration", "error", err)
+		os.Exit(1)
+	} else {
+		logger.Info("Read configuration", "path", configurationFile)
+	}
+	if problems := validateConfiguration(configuration, !*console); len(problems) > 0 {
+		for _, problem := range problems {
+			logger.Error("Invalid configuration", "path", configurationFile, "error", problem)
+		}
+		os.Exit(1)
+	}
+
+	if configured, err := NewConfiguredLogger(configuration.Log); err == nil {
+		logger = configured
+	} else {
+		logger.Error("Invalid log configuration, logging to stdout", "error", err)
+	}
+	logger.Redact(configuration.Token)
+	discordgo.Logger = discordLog
+
+	dataDir = dataDirectory(configuration)
+	if err = os.MkdirAll(dataDir, 0755); err != nil {
+		logger.Error("Error creating data directory", "path", dataDir, "error", err)
+		os.Exit(1)
+	}
+
+	backend, err := OpenStore(configuration.Storage)
 	if err != nil {
-		fmt.Println("Error creating Discord session: ", err)
+		logger.Error("Error opening storage", "error", err)
 		os.Exit(1)
 	}
+	defer backend.Close()
 
-	fmt.Println("Using token: " + configuration.Token)
+	//Documents are copied as-is, their schemas are upgraded the first time they're loaded
+	if *migrate {
+		if _, ok := backend.(*JSONFileStore); ok {
+			logger.Info("Storage backend is already json, nothing to migrate")
+		} else if err = migrateStore(NewJSONFileStore(dataDir), backend, migratedDocuments); err != nil {
+			logger.Error("Error migrating data", "error", err)
+		}
+		return
+	}
+
+	store := NewVersionedStore(backend, documentMigrations)
 
-	handlers, handlerChannels = setupHandlers()
-	regexPattern := "\\!" + configuration.Name
-	nameRegex = *regexp.MustCompile(regexPattern)
+	defaultLocation, err := configuredLocation(configuration.TimeZone)
+	if err != nil {
+		logger.Warn("Invalid time zone, using the server's", "zone", configuration.TimeZone, "error", err)
+	}
+	guildSettingsStore = NewGuildSettingsStore(store, "!"+configuration.Name, defaultLocation)
 
-	session.AddHandler(ready)
-	session.AddHandler(messageCreate)
+	defer closeServer(serveMetrics(configuration.Metrics))
+
+	//Cancelled if handlers don't finish up in time when we're stopping
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *console {
+		consoleSession := NewConsoleSession(os.Stdout)
+		handlers, handlerQueues = setupHandlers(ctx, configuration, consoleSession, store)
+		scheduler.Start()
+		adminServer := serveAdmin(configuration.Admin, handlers)
+		stopReloads := watchReloads(reloader)
+		runConsole(consoleSession, os.Stdin)
+		stopReloads()
+		closeServer(adminServer)
+		stopHandlers(consoleSession, reloader.Configuration().Shutdown, cancel)
+		return
+	}
+
+	session, err := discordgo.New("Bot " + configuration.Token)
+	if err != nil {
+		logger.Error("Error creating Discord session", "error", err)
+		os.Exit(1)
+	}
+
+	handlers, handlerQueues = setupHandlers(ctx, configuration, &MessageSender, store)
+	slashCommands = configuration.SlashCommands
+	adminServer := serveAdmin(configuration.Admin, handlers)
+	stopReloads := watchReloads(reloader)
+
+	removeHandlers := []func(){
+		session.AddHandler(ready),
+		session.AddHandler(messageCreate),
+		session.AddHandler(interactionCreate),
+	}
+	removeHandlers = append(removeHandlers, trackGateway(session)...)
 
 	session.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsAllWithoutPrivileged)
 	MessageSender.Init(session)
 	err = session.Open()
 	defer session.Close()
 	if err != nil {
-		fmt.Println("Error opening Discord session: ", err)
+		logger.Error("Error opening Discord session", "error", err)
 		return
 	}
 
-	//Diagnostic info dump
-	guilds, err := session.UserGuilds(100, "", "")
-	if err == nil {
-		for _, guild := range guilds {
-			fmt.Println("Guild: " + guild.Name)
-			emojis, err := session.GuildEmojis(guild.ID)
-			if err == nil {
-				for _, emoji := range emojis {
-					fmt.Println(emoji.Name + " : " + emoji.ID)
+	//Jobs send as soon as they start, catching up on any missed, so only once we can
+	scheduler.Start()
+
+	//Diagnostic info dump, which costs a request per guild so only when it'll be seen
+	if logger.Enabled(DebugLevel) {
+		guilds, err := MessageSender.UserGuilds()
+		if err == nil {
+			for _, guild := range guilds {
+				logger.Debug("Guild", "guild", guild.ID, "name", guild.Name)
+				emojis, err := MessageSender.GuildEmojis(guild.ID)
+				if err == nil {
+					for _, emoji := range emojis {
+						logger.Debug("Emoji", "guild", guild.ID, "name", emoji.Name, "emoji", emoji.ID)
+					}
 				}
 			}
 		}
 	}
 
 	// Wait here until CTRL-C or other term signal is received.
-	fmt.Println("DisKhard is now running. Press CTRL-C to exit.")
+	logger.Info("DisKhard is now running. Press CTRL-C to exit, or send SIGHUP to reload.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 
 	//Run until we're done!
 	<-sc
-	for _, handlerChannel := range handlerChannels {
-		handlerChannel <- nil
+	logger.Info("Shutting down")
+
+	//Stop taking new events, then let the handlers finish what they already have before the session closes
+	for _, remove := range removeHandlers {
+		remove()
 	}
+	stopReloads()
+	closeServer(adminServer)
+	stopHandlers(&MessageSender, reloader.Configuration().Shutdown, cancel)
 }
 
-//Init Reads in the configuration
-func Init() Configuration {
+//closeServer stops an optional server, if it was started
+func closeServer(server *http.Server) {
+	if server != nil {
+		server.Close()
+	}
+}
 
-	var configuration Configuration
+func setupHandlers(ctx context.Context, configuration Configuration, sender Session, store Store) ([]MessageHandler, []*HandlerQueue) {
+	permissions := NewPermissionStore(sender, store, configuration.Owners)
+	reloader = NewReloader(configurationFile, configuration, permissions)
+	scheduler = NewScheduler(sender, store, configuration.Scheduler)
+	supervisor = NewSupervisor(sender, configuration.Supervisor)
+	handlerToggleStore = NewHandlerToggleStore(store)
+	auditLog = NewAuditLog(sender, dataPath(auditFile))
+	slices := []availableHandler{
+		{handler: &EchoHandler{}},
+		{handler: NewSettingsHandler(sender, guildSettingsStore), core: true},
+		{handler: NewPermissionHandler(sender, permissions), core: true},
+		{handler: NewToggleHandler(sender, handlerToggleStore), core: true},
+		{handler: NewAlternatingCaseHandler(sender), enabled: true},
+		{handler: NewReleaseHandler(sender, store), enabled: true},
+		{handler: NewReactionHandler(sender, store), enabled: true},
+		{handler: NewImageHandler(sender, store), enabled: true},
+		{handler: NewReminderHandler(sender, store), enabled: true},
+		{handler: NewFortuneHandler(sender, store)},
+		//&VoiceHandler{},
+		{handler: NewIPHandler(sender), enabled: true},
+		{handler: NewJobsHandler(sender, scheduler), enabled: true},
+		{handler: NewAuditHandler(sender, auditLog), core: true},
+		{handler: NewReloadHandler(sender, reloader, permissions), core: true},
+	}
+	warnUnknownHandlers(configuration.Handlers, slices)
 
-	// Get configuration
-	config, err := ioutil.ReadFile("./diskhard.json")
-	// if we os.Open returns an error then handle it
-	if err != nil {
-		fmt.Println("Error reading configuration:")
-		fmt.Println(err)
-	} else {
-		fmt.Println("Read configuration")
-		json.Unmarshal(config, &configuration)
+	started := make([]MessageHandler, 0)
+	handlerQueues := make([]*HandlerQueue, 0)
+	commandRouter = NewCommandRouter(sender, guildSettingsStore, permissions)
+	for _, available := range slices {
+		handler := available.handler
+		handlerConfig, _ := handlerConfiguration(configuration.Handlers, handler.GetName())
+		if !available.core && !available.runs(handlerConfig) {
+			continue
+		}
+
+		handlerQueue := NewHandlerQueue(handler.GetName(), configuration.Queue)
+		if logged, ok := handler.(loggedHandler); ok {
+			logged.useLogger(logger.With("handler", handler.GetName()))
+		}
+		if !supervisor.Start(handler.GetName(), func() { handler.Init(ctx, handlerQueue.Channel()) }) {
+			continue
+		}
+		started = append(started, handler)
+		handlerQueues = append(handlerQueues, handlerQueue)
+		handlerToggleStore.Register(handler.GetName(), handlerConfig.OptIn && !available.core, available.core)
+		if err := commandRouter.Register(handler, handlerQueue); err != nil {
+			logger.Error("Error registering commands", "handler", handler.GetName(), "error", err)
+		}
+		if scheduled, ok := handler.(ScheduledHandler); ok {
+			for _, job := range scheduled.Jobs() {
+				job.Owner = handler.GetName()
+				scheduler.Add(job)
+			}
+		}
+		logger.Info("Initialized", "handler", handler.GetName())
 	}
 
-	return configuration
+	trackQueues(handlerQueues)
+	reloader.handlers = started
+
+	return started, handlerQueues
 }
 
-func setupHandlers() ([]MessageHandler, []chan *discordgo.MessageCreate) {
-	slices := []MessageHandler{
-		//&EchoHandler{},
-		&AlternatingCaseHandler{},
-		&ReleaseHandler{},
-		&ReactionHandler{},
-		&ImageHandler{},
-		&ReminderHandler{},
-		//&FortuneHandler{},
-		//&VoiceHandler{},
-		&IPHandler{},
+//availableHandler Is a handler we can run, and whether it runs when it isn't configured
+type availableHandler struct {
+	handler MessageHandler
+	enabled bool
+	//core handlers manage the bot, so always run and can't be switched off
+	core bool
+}
+
+func (ah availableHandler) runs(config HandlerConfiguration) bool {
+	if config.Enabled != nil {
+		return *config.Enabled
 	}
 
-	handlerChannels := make([]chan *discordgo.MessageCreate, 0)
-	for _, handler := range slices {
-		handlerChannel := make(chan *discordgo.MessageCreate)
-		handler.Init(handlerChannel)
-		handlerChannels = append(handlerChannels, handlerChannel)
-		fmt.Println("Initialized ", handler.GetName())
+	return ah.enabled
+}
+
+//warnUnknownHandlers points out configuration for handlers we don't have, which is probably a typo
+func warnUnknownHandlers(configurations map[string]HandlerConfiguration, available []availableHandler) {
+	for configured := range configurations {
+		known := false
+		for _, handler := range available {
+			known = known || handlerKey(handler.handler.GetName()) == handlerKey(configured)
+		}
+		if !known {
+			logger.Warn("Configuration for unknown handler", "handler", configured)
+		}
+	}
+}
+
+//localizeCommands rewrites mentions of our commands to use the guild's prefix
+func localizeCommands(guildID string, text string) string {
+	if commandRouter == nil {
+		return text
 	}
 
-	return slices, handlerChannels
+	return commandRouter.Localize(guildID, text)
 }
 
 func ready(s *discordgo.Session, event *discordgo.Ready) {
-	s.UpdateStatus(0, "Soul Eater Hungers")
+	s.UpdateGameStatus(0, "Soul Eater Hungers")
+	if slashCommands {
+		registerSlashCommands(s)
+	}
 }
 
 //messageCreate handles passing messages to our interested handlers
@@ -134,24 +307,30 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
-	if nameRegex.MatchString(m.Content) {
-		showHandlerInfo(s, m.ChannelID)
+	dispatchMessage(&MessageSender, m)
+}
+
+//dispatchMessage hands a message from any source to the help output or our handlers
+func dispatchMessage(sender Session, m *discordgo.MessageCreate) {
+	metrics.Inc(metricMessagesReceived)
+	//Only the trigger on its own, so it can be mentioned in passing without the whole help output
+	if strings.TrimSpace(m.Content) == guildSettingsStore.HelpTrigger(m.GuildID) {
+		showHandlerInfo(sender, m.ChannelID, m.GuildID)
 	} else {
-		for _, handlerChannel := range handlerChannels {
-			handlerChannel <- m
-		}
+		commandRouter.Route(m)
 	}
 }
 
-func showHandlerInfo(s *discordgo.Session, channelID string) {
-	helpMessage := "Hey there! I currently support the following options:\n"
+func showHandlerInfo(sender Session, channelID string, guildID string) {
+	embed := newEmbed("Help")
+	embed.Description = "Hey there! I currently support the following options:"
 
 	for _, handler := range handlers {
 		handlerHelp := handler.Help()
-		if len(handlerHelp) > 0 {
-			helpMessage += handlerHelp + "\n"
+		if len(handlerHelp) > 0 && handlerActive(handler.GetName(), guildID, channelID) {
+			embed.Fields = append(embed.Fields, embedField(handler.GetName(), localizeCommands(guildID, handlerHelp), false))
 		}
 	}
 
-	_, _ = s.ChannelMessageSend(channelID, helpMessage)
+	_, _ = sendPaged(sender, channelID, embed)
 }